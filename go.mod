@@ -5,6 +5,7 @@ go 1.21.1
 replace github.com/aesthetic-factory/code_assistant => ./src
 
 require (
-	github.com/joho/godotenv v1.5.1 // indirect
-	github.com/mattn/go-sqlite3 v1.14.22 // indirect
+	github.com/joho/godotenv v1.5.1
+	github.com/mattn/go-sqlite3 v1.14.22
+	github.com/santhosh-tekuri/jsonschema/v5 v5.3.1
 )
@@ -4,6 +4,7 @@ import (
 	"code_assistant/src/cmd"
 	"code_assistant/src/config"
 	"code_assistant/src/db"
+	"flag"
 	"log"
 )
 
@@ -26,7 +27,8 @@ func main() {
 		file_path TEXT NOT NULL UNIQUE,
 		sha256 TEXT NOT NULL,
 		last_update_datetime DATETIME NOT NULL,
-		rescan_required INT NOT NULL`)
+		rescan_required INT NOT NULL,
+		commit_sha TEXT NOT NULL DEFAULT ''`)
 
 	if err != nil {
 		log.Panic(err)
@@ -43,12 +45,286 @@ func main() {
 		file_id INT NOT NULL,
 		line_start INT NOT NULL,
 		line_end INT NOT NULL,
+		body_sha256 TEXT NOT NULL DEFAULT '',
+		bounds_verified INT NOT NULL DEFAULT 0,
+		confidence REAL NOT NULL DEFAULT 1,
+		attempts INT NOT NULL DEFAULT 0,
+		prompt_version TEXT NOT NULL DEFAULT '',
 		FOREIGN KEY(file_id) REFERENCES files(id)`)
 
 	if err != nil {
 		log.Panic(err)
 	}
 
+	err = database.CreateTable("audit_findings",
+		`id INTEGER PRIMARY KEY AUTOINCREMENT,
+		fingerprint TEXT NOT NULL UNIQUE,
+		rule_id TEXT NOT NULL,
+		file_id INT NOT NULL,
+		function_name TEXT NOT NULL,
+		line_start INT NOT NULL,
+		line_end INT NOT NULL,
+		severity TEXT NOT NULL,
+		confidence REAL NOT NULL,
+		message TEXT NOT NULL,
+		suggestion TEXT NOT NULL,
+		suppressed INT NOT NULL DEFAULT 0,
+		first_seen_datetime DATETIME NOT NULL,
+		last_seen_datetime DATETIME NOT NULL,
+		prompt_version TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY(file_id) REFERENCES files(id)`)
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// What was masked before leaving the process, the original values are never stored
+	err = database.CreateTable("redactions",
+		`id INTEGER PRIMARY KEY AUTOINCREMENT,
+		endpoint TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		placeholder TEXT NOT NULL,
+		occurrences INT NOT NULL,
+		created_datetime DATETIME NOT NULL`)
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Files found while scanning that were not read, e.g. binary or too large
+	err = database.CreateTable("skipped_files",
+		`file_path TEXT PRIMARY KEY,
+		reason TEXT NOT NULL,
+		detail TEXT NOT NULL,
+		skipped_datetime DATETIME NOT NULL`)
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Answers of the runs of ensemble mode, to compare models by their agreement with the majority
+	err = database.CreateTable("ensemble_votes",
+		`id INTEGER PRIMARY KEY AUTOINCREMENT,
+		file_path TEXT NOT NULL,
+		function_name TEXT NOT NULL,
+		step TEXT NOT NULL,
+		model TEXT NOT NULL,
+		seed INT NOT NULL,
+		answer TEXT NOT NULL,
+		agreed INT NOT NULL,
+		created_datetime DATETIME NOT NULL`)
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = database.CreateTable("snapshots",
+		`id INTEGER PRIMARY KEY AUTOINCREMENT,
+		revision TEXT NOT NULL,
+		commit_sha TEXT NOT NULL UNIQUE,
+		commit_datetime DATETIME NOT NULL,
+		repo_root TEXT NOT NULL,
+		created_datetime DATETIME NOT NULL,
+		complete INT NOT NULL DEFAULT 0`)
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = database.CreateTable("snapshot_files",
+		`snapshot_id INT NOT NULL,
+		file_path TEXT NOT NULL,
+		sha256 TEXT NOT NULL,
+		PRIMARY KEY(snapshot_id, file_path),
+		FOREIGN KEY(snapshot_id) REFERENCES snapshots(id)`)
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// File versions analyzed for snapshots, shared by all snapshots containing the same content
+	err = database.CreateTable("file_analyses",
+		`sha256 TEXT PRIMARY KEY,
+		analyzed_datetime DATETIME NOT NULL`)
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = database.CreateTable("snapshot_functions",
+		`id INTEGER PRIMARY KEY AUTOINCREMENT,
+		file_sha256 TEXT NOT NULL,
+		function_name TEXT NOT NULL,
+		signature TEXT NOT NULL,
+		arguments TEXT NOT NULL,
+		return TEXT NOT NULL,
+		description TEXT NOT NULL,
+		line_start INT NOT NULL,
+		line_end INT NOT NULL,
+		body_sha256 TEXT NOT NULL,
+		prompt_version TEXT NOT NULL DEFAULT '',
+		UNIQUE(file_sha256, function_name)`)
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Add columns to databases created by older versions
+	err = database.AddColumn("functions", "body_sha256", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = database.AddColumn("files", "commit_sha", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = database.AddColumn("functions", "bounds_verified", "INT NOT NULL DEFAULT 0")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = database.AddColumn("functions", "confidence", "REAL NOT NULL DEFAULT 1")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Whether every file of a snapshot was indexed, an incomplete snapshot is processed again
+	err = database.AddColumn("snapshots", "complete", "INT NOT NULL DEFAULT 0")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Number of scans that analyzed a function again because the ensemble runs did not agree on it
+	err = database.AddColumn("functions", "attempts", "INT NOT NULL DEFAULT 0")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Version of the prompt templates a row was generated with
+	for _, table := range []string{"functions", "audit_findings", "snapshot_functions"} {
+		err = database.AddColumn(table, "prompt_version", "TEXT NOT NULL DEFAULT ''")
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+
+	err = database.CreateTable("doc_drift",
+		`id INTEGER PRIMARY KEY AUTOINCREMENT,
+		function_id INT NOT NULL,
+		file_id INT NOT NULL,
+		comment TEXT NOT NULL,
+		consistent INT NOT NULL,
+		severity TEXT NOT NULL,
+		explanation TEXT NOT NULL,
+		checked_datetime DATETIME NOT NULL,
+		prompt_version TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY(function_id) REFERENCES functions(id),
+		FOREIGN KEY(file_id) REFERENCES files(id)`)
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = database.AddColumn("doc_drift", "prompt_version", "TEXT NOT NULL DEFAULT ''")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	err = database.CreateTable("function_embeddings",
+		`function_id INTEGER PRIMARY KEY,
+		model TEXT NOT NULL,
+		text_sha256 TEXT NOT NULL,
+		vector TEXT NOT NULL,
+		last_update_datetime DATETIME NOT NULL,
+		FOREIGN KEY(function_id) REFERENCES functions(id)`)
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Every request to the model and its reply, with sensitive values masked as they were sent
+	err = database.CreateTable("llm_calls",
+		`id INTEGER PRIMARY KEY AUTOINCREMENT,
+		endpoint TEXT NOT NULL,
+		model TEXT NOT NULL,
+		options TEXT NOT NULL,
+		request TEXT NOT NULL,
+		reply TEXT NOT NULL,
+		raw_response TEXT NOT NULL,
+		prompt_tokens INT NOT NULL,
+		completion_tokens INT NOT NULL,
+		load_duration INT NOT NULL DEFAULT 0,
+		prompt_eval_duration INT NOT NULL DEFAULT 0,
+		eval_duration INT NOT NULL DEFAULT 0,
+		duration_ms INT NOT NULL,
+		error TEXT NOT NULL,
+		file_path TEXT NOT NULL,
+		function_name TEXT NOT NULL,
+		step TEXT NOT NULL,
+		scan_id INT NOT NULL DEFAULT 0,
+		replay_of INT NOT NULL DEFAULT 0,
+		cached INT NOT NULL DEFAULT 0,
+		created_datetime DATETIME NOT NULL`)
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Columns added to llm_calls later, the durations are in nanoseconds as reported by the server
+	for _, column := range []string{"cached", "load_duration", "prompt_eval_duration", "eval_duration", "scan_id"} {
+		err = database.AddColumn("llm_calls", column, "INT NOT NULL DEFAULT 0")
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+	// The files a prompt was built from, newline separated, replays are checked against the policy with them
+	for _, column := range []string{"source_paths", "metadata_paths"} {
+		err = database.AddColumn("llm_calls", column, "TEXT NOT NULL DEFAULT ''")
+		if err != nil {
+			log.Panic(err)
+		}
+	}
+	err = database.AddColumn("llm_calls", "no_files", "INT NOT NULL DEFAULT 0")
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Scans of a directory, a git range or a watched file, the calls they made refer to them
+	err = database.CreateTable("scans",
+		`id INTEGER PRIMARY KEY AUTOINCREMENT,
+		kind TEXT NOT NULL,
+		target TEXT NOT NULL,
+		started_datetime DATETIME NOT NULL,
+		finished_datetime DATETIME`)
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Answers of the model by a hash of the request, to answer identical requests without asking again
+	err = database.CreateTable("llm_cache",
+		`key TEXT PRIMARY KEY,
+		provider TEXT NOT NULL,
+		endpoint TEXT NOT NULL,
+		model TEXT NOT NULL,
+		response TEXT NOT NULL,
+		size INT NOT NULL,
+		hits INT NOT NULL,
+		created_datetime DATETIME NOT NULL,
+		last_used_datetime DATETIME NOT NULL`)
+
+	if err != nil {
+		log.Panic(err)
+	}
+
+	// Run a single command if one is given, e.g. "code_assistant serve"
+	if args := flag.Args(); len(args) > 0 {
+		cmd.RunCommand(args)
+		return
+	}
+
 	// Start the command-line interface
 	cmd.StartCLI()
 }
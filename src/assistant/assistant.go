@@ -0,0 +1,104 @@
+package assistant
+
+import (
+	"code_assistant/src/fileutil"
+	"code_assistant/src/http_client"
+	"code_assistant/src/index"
+	"code_assistant/src/lang"
+	"code_assistant/src/llm_prompt"
+	"code_assistant/src/policy"
+	"code_assistant/src/search"
+	"code_assistant/src/util"
+	"fmt"
+	"strings"
+)
+
+const askContextSize = 5
+
+// Answer is the reply to a question together with the functions used as context
+type Answer struct {
+	Answer  string           `json:"answer"`
+	Sources []index.Function `json:"sources"`
+}
+
+// Ask answers a question about the code base using the closest indexed functions as context.
+func Ask(question string) (*Answer, error) {
+	if strings.TrimSpace(question) == "" {
+		return nil, fmt.Errorf("question cannot be empty")
+	}
+
+	results, err := search.Search(question, askContextSize)
+	if err != nil {
+		return nil, err
+	}
+
+	var context strings.Builder
+	var metadataPaths []string
+	answer := &Answer{Sources: []index.Function{}}
+	for _, r := range results {
+		fn := r.Function
+		if policy.ModeFor(fn.FilePath) == policy.ModeExclude {
+			continue
+		}
+		answer.Sources = append(answer.Sources, fn)
+		metadataPaths = append(metadataPaths, fn.FilePath)
+		fmt.Fprintf(&context, "Function: %s\nFile: %s (lines %d-%d)\nSignature: %s\nDescription: %s\nArguments: %s\nReturn: %s\n\n",
+			fn.FunctionName, fn.FilePath, fn.LineStart, fn.LineEnd, fn.Signature, fn.Description, fn.Arguments, fn.Return)
+	}
+
+	content, err := chat(llm_prompt.AskQuestion(question, context.String()), nil, metadataPaths, http_client.Trace{Step: "AskQuestion"})
+	if err != nil {
+		return nil, err
+	}
+	answer.Answer = content
+	return answer, nil
+}
+
+// ExplainFunction asks the chat model for a detailed explanation of an indexed function.
+func ExplainFunction(fn index.Function) (string, error) {
+	lines, err := fileutil.ReadFileLines(fn.FilePath)
+	if err != nil {
+		return "", err
+	}
+	language := "unkown type"
+	if l, ok := lang.ForFile(fn.FilePath); ok {
+		language = l.Name
+	}
+
+	lineStart := fn.LineStart - 1
+	if lineStart < 0 {
+		lineStart = 0
+	}
+	lineEnd := fn.LineEnd
+	if lineEnd > len(lines) || lineEnd < lineStart {
+		lineEnd = len(lines)
+	}
+
+	return chat(llm_prompt.ExplainFunction(fn.FunctionName, language, lines, lineStart, lineEnd), []string{fn.FilePath}, nil,
+		http_client.Trace{FunctionName: fn.FunctionName, Step: "ExplainFunction"})
+}
+
+// chat sends a single prompt and returns the "answer" field of the reply.
+// sourcePaths and metadataPaths name the files the prompt was built from, for the policy check.
+// trace is recorded with the call.
+func chat(prompt string, sourcePaths []string, metadataPaths []string, trace http_client.Trace) (string, error) {
+	chatReq := http_client.NewChatRequest()
+	chatReq.SourcePaths = sourcePaths
+	chatReq.MetadataPaths = metadataPaths
+	// the prompt is built from these files only, a question without indexed context names none
+	chatReq.NoFiles = len(sourcePaths) == 0 && len(metadataPaths) == 0
+	chatReq.Trace = trace
+	chatReq.Messages = append(chatReq.Messages, http_client.Chat{Role: "user", Content: prompt})
+
+	resp, err := http_client.ChatGenerateRemote(chatReq)
+	if err != nil {
+		return "", fmt.Errorf("error calling ChatGenerateRemote: %v", err)
+	}
+
+	res, err := util.ParseJsonObject[llm_prompt.AnswerItem](resp.Result.Content)
+	if err != nil {
+		// the model ignored the format, return the raw reply rather than nothing
+		return resp.Result.Content, nil
+	}
+	return res.Answer, nil
+}
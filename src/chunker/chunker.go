@@ -0,0 +1,210 @@
+package chunker
+
+import (
+	"code_assistant/src/config"
+	"code_assistant/src/lang"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// DefaultContextTokens is the context size assumed for models without a configured budget,
+// it is the default context length Ollama runs a model with
+const DefaultContextTokens = 4096
+
+// Chunk is a range of lines sent to the model at once, 0-based with an exclusive end
+type Chunk struct {
+	Start int
+	End   int
+}
+
+// largeVocabularyModels encode the same text in fewer tokens than the 32k vocabularies the estimate is based on
+var largeVocabularyModels = []string{"llama3", "qwen", "gemma", "deepseek", "phi3", "phi4", "command-r"}
+
+// ContextTokens returns the context budget of a model: the configured value for the model, or for
+// its name without a tag, otherwise the default budget.
+func ContextTokens(model string) int {
+	budgets := parseBudgets(config.AppConfig.Chunking.ContextTokens)
+	if tokens, ok := budgets[model]; ok {
+		return tokens
+	}
+	if name, _, found := strings.Cut(model, ":"); found {
+		if tokens, ok := budgets[name]; ok {
+			return tokens
+		}
+	}
+	if tokens, ok := budgets["default"]; ok {
+		return tokens
+	}
+	return DefaultContextTokens
+}
+
+// parseBudgets parses "model=tokens" pairs separated by commas, a pair with an invalid number is ignored
+func parseBudgets(value string) map[string]int {
+	budgets := map[string]int{}
+	for _, pair := range strings.Split(value, ",") {
+		model, tokens, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if !found {
+			continue
+		}
+		if n, err := strconv.Atoi(strings.TrimSpace(tokens)); err == nil && n > 0 {
+			budgets[strings.TrimSpace(model)] = n
+		}
+	}
+	return budgets
+}
+
+// EstimateTokens estimates how many tokens model needs for text.
+//
+// There is no tokenizer for the local models, so it counts the pieces a BPE tokenizer typically splits
+// code into: words in parts of four characters, each punctuation character, and runs of whitespace
+// other than a single space. For code it errs on the high side, which keeps prompts within the budget.
+func EstimateTokens(model string, text string) int {
+	tokens := 0
+	word := 0
+	space := 0
+	flushWord := func() {
+		if word > 0 {
+			tokens += (word + 3) / 4
+		}
+		word = 0
+	}
+	flushSpace := func() {
+		if space > 1 {
+			tokens += (space + 3) / 4
+		}
+		space = 0
+	}
+
+	for _, r := range text {
+		switch {
+		case r == '\n':
+			flushWord()
+			flushSpace()
+			tokens++
+		case unicode.IsSpace(r):
+			flushWord()
+			space++
+		case r < 128 && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'):
+			flushSpace()
+			word++
+		default:
+			flushWord()
+			flushSpace()
+			tokens++
+		}
+	}
+	flushWord()
+	flushSpace()
+
+	for _, family := range largeVocabularyModels {
+		if strings.HasPrefix(strings.ToLower(model), family) {
+			return tokens * 4 / 5
+		}
+	}
+	return tokens
+}
+
+// Boundaries returns the 0-based lines where a file can be split without cutting a top-level declaration.
+//
+// Declarations are found with the local parser of the language. A file is split right after the end of a
+// declaration, so comments above the next declaration stay with it. Blank lines followed by a line that is
+// not indented are boundaries too, they separate top-level constructs the parser does not know.
+func Boundaries(l lang.Language, lines []string) []int {
+	var boundaries []int
+	inDeclaration := map[int]bool{}
+	coveredUntil := -1 // last 0-based line inside a top-level declaration
+	for _, d := range l.FindDeclarations(lines) {
+		for idx := d.LineStart - 1; idx < d.LineEnd; idx++ {
+			inDeclaration[idx] = true
+		}
+		if d.LineStart-1 <= coveredUntil {
+			// nested in the previous declaration
+			continue
+		}
+		coveredUntil = d.LineEnd - 1
+		boundaries = append(boundaries, d.LineEnd)
+	}
+
+	for idx := 1; idx < len(lines); idx++ {
+		if inDeclaration[idx] || strings.TrimSpace(lines[idx-1]) != "" || strings.TrimSpace(lines[idx]) == "" {
+			continue
+		}
+		if lang.Indentation(lines[idx]) != "" || strings.ContainsAny(lines[idx][:1], "})]") {
+			continue
+		}
+		boundaries = append(boundaries, idx)
+	}
+	return normalize(boundaries, len(lines))
+}
+
+// normalize sorts boundaries, drops duplicates and the ones at the start or end of the file
+func normalize(boundaries []int, lineCount int) []int {
+	seen := map[int]bool{}
+	var result []int
+	for _, b := range boundaries {
+		if b <= 0 || b >= lineCount || seen[b] {
+			continue
+		}
+		seen[b] = true
+		result = append(result, b)
+	}
+	sort.Ints(result)
+	return result
+}
+
+// Split splits lineCount lines into chunks, each ending at one of the sorted boundaries.
+//
+// fits reports whether the lines [start, end) fit in the budget, it must not fit a range once a shorter
+// range from the same start does not fit. Each chunk takes as many declarations as fit. A single construct
+// larger than the budget is split like a sliding window into the largest pieces that fit, at least one line.
+// Each piece overlaps the previous one by a quarter of its length, so that what is cut at the end of a piece
+// is shown whole in the next one if it is short enough.
+func Split(lineCount int, boundaries []int, fits func(start int, end int) bool) []Chunk {
+	ends := append(append([]int{}, boundaries...), lineCount)
+
+	var chunks []Chunk
+	start := 0
+	for start < lineCount {
+		// candidate ends after start
+		candidates := ends[:0:0]
+		for _, e := range ends {
+			if e > start {
+				candidates = append(candidates, e)
+			}
+		}
+
+		if last := lastFitting(len(candidates), func(i int) bool { return fits(start, candidates[i]) }); last >= 0 {
+			chunks = append(chunks, Chunk{Start: start, End: candidates[last]})
+			start = candidates[last]
+			continue
+		}
+
+		// the next construct alone is over the budget
+		next := candidates[0]
+		end := start + 2 + lastFitting(next-start-1, func(i int) bool { return fits(start, start+2+i) })
+		chunks = append(chunks, Chunk{Start: start, End: end})
+		if end == next {
+			start = end
+		} else {
+			start = end - (end-start)/4
+		}
+	}
+	return chunks
+}
+
+// lastFitting returns the largest index i below n for which fits(i) is true, or -1.
+// fits must be true for all indices below one for which it is true.
+func lastFitting(n int, fits func(i int) bool) int {
+	lo, hi := 0, n // the answer is in [lo-1, hi-1]
+	for lo < hi {
+		mid := (lo + hi) / 2
+		if fits(mid) {
+			lo = mid + 1
+		} else {
+			hi = mid
+		}
+	}
+	return lo - 1
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"code_assistant/src/code_analyzer"
+	"code_assistant/src/findings"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const auditUsage = "Usage: audit scan [--file <path>] | audit report [--file <path>] [--all] [--min-confidence <0-1>] [--baseline <file>] [--format text|json|sarif] [--out <file>] | audit baseline --out <file>"
+
+// handleAudit runs the security audit pass, prints its findings or writes a baseline
+func handleAudit(args []string) {
+	if len(args) == 0 {
+		fmt.Println(auditUsage)
+		return
+	}
+
+	flags := flag.NewFlagSet("audit "+args[0], flag.ContinueOnError)
+	filePath := flags.String("file", "", "Only audit or report the given file")
+	all := flags.Bool("all", false, "Also report suppressed and baselined findings")
+	minConfidence := flags.Float64("min-confidence", 0, "Leave out findings the model is less confident about")
+	baselinePath := flags.String("baseline", "", "Leave out findings listed in this baseline file")
+	format := flags.String("format", "text", "Report format: text, json or sarif")
+	outPath := flags.String("out", "", "Write the report or baseline to a file instead of stdout")
+	if err := flags.Parse(args[1:]); err != nil {
+		return
+	}
+	if *filePath != "" {
+		*filePath, _ = filepath.Abs(*filePath)
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "scan":
+		fmt.Println("Auditing functions for security issues...")
+		if err := code_analyzer.AuditSecurity(*filePath); err != nil {
+			fmt.Printf("Failed to audit: %v\n", err)
+		}
+
+	case "report":
+		*format = strings.ToLower(*format)
+		if !findingFormats[*format] {
+			fmt.Printf("Unknown format %s\n", *format)
+			return
+		}
+		var baseline *findings.Baseline
+		if *baselinePath != "" {
+			var err error
+			if baseline, err = findings.LoadBaseline(*baselinePath); err != nil {
+				fmt.Printf("Failed to load baseline: %v\n", err)
+				return
+			}
+		}
+		results, err := auditFindings(*filePath, *all, *minConfidence, baseline)
+		if err != nil {
+			fmt.Printf("Failed to list audit findings: %v\n", err)
+			return
+		}
+		writeFindings(*outPath, *format, results, code_analyzer.SecurityRules)
+
+	case "baseline":
+		if *outPath == "" {
+			fmt.Println("Usage: audit baseline --out <file>")
+			return
+		}
+		results, err := auditFindings(*filePath, false, *minConfidence, nil)
+		if err != nil {
+			fmt.Printf("Failed to list audit findings: %v\n", err)
+			return
+		}
+		if err := findings.WriteBaseline(*outPath, results); err != nil {
+			fmt.Printf("Failed to write baseline: %v\n", err)
+			return
+		}
+		fmt.Printf("Wrote %d findings to baseline %s\n", len(results), *outPath)
+
+	default:
+		fmt.Println(auditUsage)
+	}
+}
+
+// auditFindings loads the stored audit findings, marking or dropping the ones in the baseline
+func auditFindings(filePath string, all bool, minConfidence float64, baseline *findings.Baseline) ([]findings.Finding, error) {
+	stored, err := code_analyzer.ListSecurityFindings(filePath, all, minConfidence)
+	if err != nil {
+		return nil, err
+	}
+	results := []findings.Finding{}
+	for _, s := range stored {
+		f := s.Finding()
+		if baseline.Contains(f.Fingerprint) {
+			if !all {
+				continue
+			}
+			if f.Suppression == "" {
+				f.Suppression = findings.SuppressionExternal
+			}
+		}
+		results = append(results, f)
+	}
+	return results, nil
+}
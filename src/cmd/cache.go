@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"code_assistant/src/http_client"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// handleCache shows the size of the response cache or removes cached answers
+func handleCache(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: cache stats | cache clear [--model <name>] [--expired]")
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "stats":
+		stats, err := http_client.ListCacheStats()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if len(stats) == 0 {
+			fmt.Println("The cache is empty.")
+			return
+		}
+		var total http_client.CacheStats
+		for _, s := range stats {
+			fmt.Printf("%-30s %6d entries  %10d bytes  %6d hits  %6d expired\n", s.Model, s.Entries, s.Bytes, s.Hits, s.Expired)
+			total.Entries += s.Entries
+			total.Bytes += s.Bytes
+			total.Hits += s.Hits
+			total.Expired += s.Expired
+		}
+		fmt.Printf("%-30s %6d entries  %10d bytes  %6d hits  %6d expired\n", "total", total.Entries, total.Bytes, total.Hits, total.Expired)
+
+	case "clear":
+		flags := flag.NewFlagSet("cache clear", flag.ContinueOnError)
+		model := flags.String("model", "", "Only remove the answers of this model")
+		expired := flags.Bool("expired", false, "Only remove answers older than the cache TTL")
+		if err := flags.Parse(args[1:]); err != nil {
+			return
+		}
+		removed, err := http_client.ClearCache(*model, *expired)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("Removed %d cached answers.\n", removed)
+
+	default:
+		invalidCommand()
+	}
+}
@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"code_assistant/src/diffutil"
+	"code_assistant/src/http_client"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// handleCalls lists the recorded model calls, or shows one of them in full
+func handleCalls(args []string) {
+	if len(args) > 0 && strings.ToLower(args[0]) == "show" {
+		if len(args) != 2 {
+			fmt.Println("Usage: calls show <id>")
+			return
+		}
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			fmt.Println("Usage: calls show <id>")
+			return
+		}
+		c, err := http_client.GetCall(id)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		printCall(c)
+		fmt.Printf("\n%s\n\n[reply]\n%s\n", c.Prompt(), c.Reply)
+		return
+	}
+
+	flags := flag.NewFlagSet("calls", flag.ContinueOnError)
+	file := flags.String("file", "", "Only calls made for this file")
+	function := flags.String("function", "", "Only calls made for this function")
+	model := flags.String("model", "", "Only calls to this model")
+	step := flags.String("step", "", "Only calls of this step, e.g. LocateFunctionDefition")
+	errorsOnly := flags.Bool("errors", false, "Only failed calls")
+	limit := flags.Int("limit", 50, "Number of most recent calls to list, 0 for all")
+	if err := flags.Parse(args); err != nil {
+		return
+	}
+
+	calls, err := http_client.ListCalls(http_client.CallFilter{FilePath: *file, FunctionName: *function, Model: *model, Step: *step,
+		ErrorsOnly: *errorsOnly, Limit: *limit})
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if len(calls) == 0 {
+		fmt.Println("No calls recorded.")
+	}
+	for _, c := range calls {
+		status := "ok"
+		if c.Cached {
+			status = "cached"
+		}
+		if c.Error != "" {
+			status = "error: " + c.Error
+		}
+		fmt.Printf("%5d  %s  %-20s %-28s %5dms  %d+%d tokens  %s %s  %s\n", c.Id, c.CreatedDatetime, c.Model, c.Step, c.DurationMs,
+			c.PromptTokens, c.CompletionTokens, c.FilePath, c.FunctionName, status)
+	}
+}
+
+// printCall prints what was recorded about a call, without the prompt and the reply
+func printCall(c http_client.Call) {
+	fmt.Printf("ID: %d\nendpoint: %s\nmodel: %s\n", c.Id, c.Endpoint, c.Model)
+	if c.Options != "" {
+		fmt.Printf("options: %s\n", c.Options)
+	}
+	fmt.Printf("file_path: %s\nfunction_name: %s\nstep: %s\n", c.FilePath, c.FunctionName, c.Step)
+	fmt.Printf("tokens: %d prompt, %d completion\nduration: %dms\n", c.PromptTokens, c.CompletionTokens, c.DurationMs)
+	fmt.Printf("server: %s load, %s prompt eval, %s eval\ncreated: %s\n", c.LoadDuration, c.PromptEvalDuration, c.EvalDuration, c.CreatedDatetime)
+	if len(c.SourcePaths) > 0 {
+		fmt.Printf("source_paths: %s\n", strings.Join(c.SourcePaths, ", "))
+	}
+	if len(c.MetadataPaths) > 0 {
+		fmt.Printf("metadata_paths: %s\n", strings.Join(c.MetadataPaths, ", "))
+	}
+	if c.ScanId != 0 {
+		fmt.Printf("scan_id: %d\n", c.ScanId)
+	}
+	if c.ReplayOf != 0 {
+		fmt.Printf("replay_of: %d\n", c.ReplayOf)
+	}
+	if c.Cached {
+		fmt.Println("cached: true")
+	}
+	if c.Error != "" {
+		fmt.Printf("error: %s\n", c.Error)
+	}
+}
+
+// handleReplay sends a recorded call again, optionally to another model, and shows how the reply changed
+func handleReplay(args []string) {
+	usage := "Usage: replay <id> [--model <name>]"
+	if len(args) == 0 {
+		fmt.Println(usage)
+		return
+	}
+	id, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Println(usage)
+		return
+	}
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	model := flags.String("model", "", "Model to send the call to instead of the recorded one")
+	if err := flags.Parse(args[1:]); err != nil {
+		return
+	}
+
+	original, err := http_client.GetCall(id)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	replayed, err := http_client.Replay(id, *model)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	printCall(replayed)
+	fmt.Println()
+
+	fromName := fmt.Sprintf("call %d (%s)", original.Id, original.Model)
+	toName := fmt.Sprintf("replay (%s)", replayed.Model)
+	if replayed.Id != 0 {
+		toName = fmt.Sprintf("call %d (%s)", replayed.Id, replayed.Model)
+	}
+	diff := diffutil.Unified(fromName, toName, strings.Split(original.Reply, "\n"), strings.Split(replayed.Reply, "\n"), 3)
+	if diff == "" {
+		fmt.Println("The reply did not change.")
+		return
+	}
+	fmt.Print(diff)
+}
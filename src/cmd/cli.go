@@ -11,8 +11,12 @@ import (
 	"strings"
 )
 
+// interactive is set while commands are read from the prompt
+var interactive bool
+
 // StartCLI starts the command-line interface
 func StartCLI() {
+	interactive = true
 	fmt.Println("Welcome to Code Assistant!")
 	fmt.Println("Type 'help' to see available options or 'exit' to quit.")
 
@@ -33,24 +37,62 @@ func StartCLI() {
 	}
 }
 
+// RunCommand runs a single command passed as program arguments instead of starting the prompt
+func RunCommand(args []string) {
+	handleCommand(strings.Join(args, " "))
+}
+
 // handleCommand parses and handles user commands
 func handleCommand(input string) {
 
-	if input == "" {
+	if strings.TrimSpace(input) == "" {
 		// overwrite empty input
 		input = "help"
 	}
 
-	switch strings.ToLower(input) {
+	args := strings.Fields(input)
+	switch strings.ToLower(args[0]) {
 	case "help":
 		fmt.Println("Available options:")
 		fmt.Println(" - scan code")
+		fmt.Println(" - scan --git-diff <range> | --since <commit> [--repo <dir>]")
 		fmt.Println(" - list file")
 		fmt.Println(" - list function")
+		fmt.Println(" - list redaction")
+		fmt.Println(" - list skipped")
 		fmt.Println(" - code explanation")
+		fmt.Println(" - docs build --out <dir> [--format html|markdown]")
+		fmt.Println(" - docgen [--file <path>] [--write] [--overwrite]")
+		fmt.Println(" - drift scan [--file <path>]")
+		fmt.Println(" - drift report [--file <path>] [--all] [--format text|json|sarif] [--out <file>]")
+		fmt.Println(" - serve [--addr <host:port>]")
+		fmt.Println(" - mcp")
+		fmt.Println(" - lsp")
+		fmt.Println(" - watch <dir> | watch status | watch stop")
+		fmt.Println(" - snapshot <revision> [--repo <dir>] | snapshot list")
+		fmt.Println(" - history <function> [--no-summary]")
+		fmt.Println(" - review --diff <file|range> [--repo <dir>] [--format text|json|sarif] [--out <file>]")
+		fmt.Println(" - audit scan [--file <path>]")
+		fmt.Println(" - audit report [--file <path>] [--all] [--min-confidence <0-1>] [--baseline <file>] [--format text|json|sarif] [--out <file>]")
+		fmt.Println(" - audit baseline --out <file>")
+		fmt.Println(" - policy check [--dir <dir>] [--mode full|metadata|exclude]")
+		fmt.Println(" - ensemble report [--below <0-1>]")
+		fmt.Println(" - calls [--file <path>] [--function <name>] [--model <name>] [--step <name>] [--errors] [--limit <n>] | calls show <id>")
+		fmt.Println(" - replay <id> [--model <name>]")
+		fmt.Println(" - prompts list [--language <name>] [--model <name>] | prompts export --out <dir> [--overwrite]")
+		fmt.Println(" - cache stats | cache clear [--model <name>] [--expired]")
+		fmt.Println(" - stats [--scan <id> | --last] [--model <name>] [--top <n>]")
 		fmt.Println(" - exit")
 
-	case "scan code":
+	case "scan":
+		if len(args) > 1 && strings.HasPrefix(args[1], "-") {
+			handleGitScan(args[1:])
+			return
+		}
+		if len(args) != 2 || strings.ToLower(args[1]) != "code" {
+			invalidCommand()
+			return
+		}
 		var directory string
 		fmt.Print("Enter directory to scan: ")
 		if _, err := fmt.Scanln(&directory); err != nil {
@@ -61,28 +103,106 @@ func handleCommand(input string) {
 			return
 		}
 		fmt.Printf("Scanning directory %s ...\n", directory)
-		code_analyzer.AnalyzeDirectory(directory)
-
-	case "list file":
-		fmt.Println("Listing files...")
-		listFiles()
+		if err := code_analyzer.AnalyzeDirectory(directory); err != nil {
+			fmt.Printf("Some files could not be scanned and are retried by the next scan:\n%v\n", err)
+		}
 
-	case "list function":
-		fmt.Println("Listing functions...")
-		listFunctions()
+	case "list":
+		if len(args) != 2 {
+			invalidCommand()
+			return
+		}
+		switch strings.ToLower(args[1]) {
+		case "file":
+			fmt.Println("Listing files...")
+			listFiles()
+		case "function":
+			fmt.Println("Listing functions...")
+			listFunctions()
+		case "redaction":
+			fmt.Println("Listing redactions...")
+			listRedactions()
+		case "skipped":
+			fmt.Println("Listing skipped files...")
+			listSkippedFiles()
+		default:
+			invalidCommand()
+		}
 
-	case "code explanation":
+	case "code":
+		if len(args) != 2 || strings.ToLower(args[1]) != "explanation" {
+			invalidCommand()
+			return
+		}
 		// Add implementation for code explanation
 		fmt.Println("Explaining code...")
 
+	case "docs":
+		handleDocs(args[1:])
+
+	case "docgen":
+		handleDocgen(args[1:])
+
+	case "drift":
+		handleDrift(args[1:])
+
+	case "serve":
+		handleServe(args[1:])
+
+	case "mcp":
+		handleMcp()
+
+	case "lsp":
+		handleLsp()
+
+	case "watch":
+		handleWatch(args[1:])
+
+	case "snapshot":
+		handleSnapshot(args[1:])
+
+	case "history":
+		handleHistory(args[1:])
+
+	case "review":
+		handleReview(args[1:])
+
+	case "audit":
+		handleAudit(args[1:])
+
+	case "policy":
+		handlePolicy(args[1:])
+
+	case "ensemble":
+		handleEnsemble(args[1:])
+
+	case "prompts":
+		handlePrompts(args[1:])
+
+	case "calls":
+		handleCalls(args[1:])
+
+	case "replay":
+		handleReplay(args[1:])
+
+	case "cache":
+		handleCache(args[1:])
+
+	case "stats":
+		handleStats(args[1:])
+
 	default:
-		fmt.Println("Invalid command. Type 'help' to see available options.")
+		invalidCommand()
 	}
 }
 
+func invalidCommand() {
+	fmt.Println("Invalid command. Type 'help' to see available options.")
+}
+
 func listFiles() {
 
-	rows, err := db.GetDatabase().Query("SELECT id, file_path, last_update_datetime FROM files")
+	rows, err := db.GetDatabase().Query("SELECT id, file_path, last_update_datetime, commit_sha FROM files")
 	if err != nil {
 		log.Println(err)
 	}
@@ -93,12 +213,13 @@ func listFiles() {
 		var id int
 		var file_path string
 		var last_update_datetime string
-		err := rows.Scan(&id, &file_path, &last_update_datetime)
+		var commit_sha string
+		err := rows.Scan(&id, &file_path, &last_update_datetime, &commit_sha)
 		if err != nil {
 			log.Fatal(err)
 		}
-		fmt.Printf("ID: %d, file_path: %s, last_update_datetime: %s\n\n",
-			id, file_path, last_update_datetime)
+		fmt.Printf("ID: %d, file_path: %s, last_update_datetime: %s, commit_sha: %s\n\n",
+			id, file_path, last_update_datetime, commit_sha)
 	}
 	if err := rows.Err(); err != nil {
 		log.Fatal(err)
@@ -108,7 +229,7 @@ func listFiles() {
 
 func listFunctions() {
 
-	rows, err := db.GetDatabase().Query("SELECT a.id, function_name, signature, arguments, return, description, b.file_path, line_start, line_end FROM functions a JOIN files b ON a.file_id = b.id")
+	rows, err := db.GetDatabase().Query("SELECT a.id, function_name, signature, arguments, return, description, b.file_path, line_start, line_end, bounds_verified, confidence, prompt_version FROM functions a JOIN files b ON a.file_id = b.id")
 	if err != nil {
 		log.Println(err)
 	}
@@ -125,15 +246,60 @@ func listFunctions() {
 		var file_path string
 		var line_start int
 		var line_end int
-		err := rows.Scan(&id, &function_name, &signature, &arguments, &return_type, &description, &file_path, &line_start, &line_end)
+		var bounds_verified bool
+		var confidence float64
+		var prompt_version string
+		err := rows.Scan(&id, &function_name, &signature, &arguments, &return_type, &description, &file_path, &line_start, &line_end, &bounds_verified, &confidence, &prompt_version)
 		if err != nil {
 			log.Fatal(err)
 		}
-		fmt.Printf("ID: %d, function_name: %s, file_path: %s\ndescription: %s\nsignature: %s\narguments: %s\nreturn_type: %s\nline_start: %d\nline_end: %d\n\n",
-			id, function_name, file_path, description, signature, arguments, return_type, line_start, line_end)
+		fmt.Printf("ID: %d, function_name: %s, file_path: %s\ndescription: %s\nsignature: %s\narguments: %s\nreturn_type: %s\nline_start: %d\nline_end: %d\nbounds_verified: %t\nconfidence: %.2f\nprompt_version: %s\n\n",
+			id, function_name, file_path, description, signature, arguments, return_type, line_start, line_end, bounds_verified, confidence, prompt_version)
 	}
 	if err := rows.Err(); err != nil {
 		log.Fatal(err)
 	}
 
 }
+
+// listSkippedFiles prints the files that were found but not indexed, with the reason
+func listSkippedFiles() {
+	records, err := code_analyzer.ListSkippedFiles()
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	for _, r := range records {
+		fmt.Printf("file_path: %s, reason: %s, detail: %s, skipped_datetime: %s\n", r.Path, r.Reason, r.Detail, r.SkippedDatetime)
+	}
+}
+
+// listRedactions prints what was masked in prompts, grouped by placeholder
+func listRedactions() {
+
+	rows, err := db.GetDatabase().Query(`SELECT kind, placeholder, SUM(occurrences), COUNT(*), MAX(created_datetime) FROM redactions
+		GROUP BY kind, placeholder ORDER BY kind, placeholder`)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+	defer rows.Close()
+
+	// Iterate over the rows and print out the values
+	for rows.Next() {
+		var kind string
+		var placeholder string
+		var occurrences int
+		var requests int
+		var last_datetime string
+		err := rows.Scan(&kind, &placeholder, &occurrences, &requests, &last_datetime)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fmt.Printf("kind: %s, placeholder: %s, occurrences: %d, requests: %d, last_datetime: %s\n",
+			kind, placeholder, occurrences, requests, last_datetime)
+	}
+	if err := rows.Err(); err != nil {
+		log.Fatal(err)
+	}
+}
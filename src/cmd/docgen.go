@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"code_assistant/src/docgen"
+	"flag"
+	"fmt"
+	"path/filepath"
+)
+
+// handleDocgen generates doc comments for undocumented functions
+func handleDocgen(args []string) {
+	flags := flag.NewFlagSet("docgen", flag.ContinueOnError)
+	filePath := flags.String("file", "", "Only document the given file")
+	write := flags.Bool("write", false, "Apply the changes in place instead of printing a diff")
+	overwrite := flags.Bool("overwrite", false, "Replace existing documentation")
+	if err := flags.Parse(args); err != nil {
+		return
+	}
+
+	opts := docgen.Options{Write: *write, Overwrite: *overwrite}
+	if *filePath != "" {
+		opts.FilePath, _ = filepath.Abs(*filePath)
+	}
+
+	result, err := docgen.Generate(opts)
+	if err != nil {
+		fmt.Printf("Failed to generate documentation: %v\n", err)
+		return
+	}
+
+	if !*write {
+		fmt.Print(result.Diff)
+	}
+	for _, skipped := range result.Skipped {
+		fmt.Printf("skipped %s\n", skipped)
+	}
+	if *write {
+		fmt.Printf("Inserted %d doc comments in %d files.\n", result.Inserted, len(result.Files))
+	}
+}
@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"code_assistant/src/docsite"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// handleDocs handles the docs sub commands
+func handleDocs(args []string) {
+	if len(args) == 0 || strings.ToLower(args[0]) != "build" {
+		fmt.Println("Usage: docs build --out <dir> [--format html|markdown]")
+		return
+	}
+
+	flags := flag.NewFlagSet("docs build", flag.ContinueOnError)
+	outDir := flags.String("out", "./site", "Output directory of the documentation site")
+	format := flags.String("format", docsite.FormatHTML, "Output format, html or markdown")
+	if err := flags.Parse(args[1:]); err != nil {
+		return
+	}
+
+	fmt.Printf("Building documentation in %s ...\n", *outDir)
+	if err := docsite.Build(docsite.Options{OutDir: *outDir, Format: *format}); err != nil {
+		fmt.Printf("Failed to build documentation: %v\n", err)
+		return
+	}
+	fmt.Println("Documentation built.")
+}
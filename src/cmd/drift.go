@@ -0,0 +1,87 @@
+package cmd
+
+import (
+	"code_assistant/src/code_analyzer"
+	"code_assistant/src/findings"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// handleDrift runs the documentation drift pass or prints its report
+func handleDrift(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: drift scan|report [--file <path>] [--all] [--format text|json|sarif] [--out <file>]")
+		return
+	}
+
+	flags := flag.NewFlagSet("drift "+args[0], flag.ContinueOnError)
+	filePath := flags.String("file", "", "Only check the given file")
+	all := flags.Bool("all", false, "Also list functions whose comment is consistent")
+	format := flags.String("format", "text", "Report format: text, json or sarif")
+	outPath := flags.String("out", "", "Write the report to a file instead of stdout")
+	if err := flags.Parse(args[1:]); err != nil {
+		return
+	}
+	if *filePath != "" {
+		*filePath, _ = filepath.Abs(*filePath)
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "scan":
+		fmt.Println("Checking documentation drift...")
+		if err := code_analyzer.AnalyzeDocumentationDrift(*filePath); err != nil {
+			fmt.Printf("Failed to check documentation drift: %v\n", err)
+		}
+	case "report":
+		*format = strings.ToLower(*format)
+		if !findingFormats[*format] {
+			fmt.Printf("Unknown format %s\n", *format)
+			return
+		}
+		if *format == "text" && *outPath == "" {
+			printDriftChecklist(*filePath, !*all)
+			return
+		}
+		results, err := code_analyzer.ListDocumentationDrift(*filePath, !*all)
+		if err != nil {
+			fmt.Printf("Failed to list documentation drift: %v\n", err)
+			return
+		}
+		driftFindings := []findings.Finding{}
+		for _, d := range results {
+			driftFindings = append(driftFindings, d.Finding())
+		}
+		writeFindings(*outPath, *format, driftFindings, []findings.Rule{code_analyzer.DocDriftRule})
+	default:
+		fmt.Println("Usage: drift scan|report [--file <path>] [--all] [--format text|json|sarif] [--out <file>]")
+	}
+}
+
+// printDriftChecklist prints the drift results as a checklist grouped by file
+func printDriftChecklist(filePath string, onlyMismatches bool) {
+	results, err := code_analyzer.ListDocumentationDrift(filePath, onlyMismatches)
+	if err != nil {
+		fmt.Printf("Failed to list documentation drift: %v\n", err)
+		return
+	}
+	if len(results) == 0 {
+		fmt.Println("No documentation drift found.")
+		return
+	}
+
+	currentFile := ""
+	for _, d := range results {
+		if d.FilePath != currentFile {
+			currentFile = d.FilePath
+			fmt.Printf("\n%s\n", currentFile)
+		}
+		mark := " "
+		if d.Consistent {
+			mark = "x"
+		}
+		fmt.Printf("  - [%s] %s (line %d, severity: %s): %s\n", mark, d.FunctionName, d.LineStart, d.Severity, d.Explanation)
+	}
+	fmt.Println()
+}
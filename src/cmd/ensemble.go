@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"code_assistant/src/code_analyzer"
+	"code_assistant/src/config"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// handleEnsemble compares the models of ensemble mode and lists the functions their runs did not agree on
+func handleEnsemble(args []string) {
+	if len(args) == 0 || strings.ToLower(args[0]) != "report" {
+		fmt.Println("Usage: ensemble report [--below <0-1>]")
+		return
+	}
+
+	flags := flag.NewFlagSet("ensemble report", flag.ContinueOnError)
+	below := flags.Float64("below", config.AppConfig.Ensemble.MinConfidence, "List functions with a confidence below this value")
+	if err := flags.Parse(args[1:]); err != nil {
+		return
+	}
+
+	agreements, err := code_analyzer.ListModelAgreement()
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if len(agreements) == 0 {
+		fmt.Println("No ensemble runs recorded, scan with -ensemble_runs or -ensemble_models to compare models.")
+	}
+	for _, a := range agreements {
+		fmt.Printf("step: %s, model: %s, votes: %d, agreed with majority: %d (%.0f%%)\n",
+			a.Step, a.Model, a.Votes, a.Agreed, 100*float64(a.Agreed)/float64(a.Votes))
+	}
+
+	functions, err := code_analyzer.ListLowConfidenceFunctions(*below)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	fmt.Printf("\n%d functions with a confidence below %.2f\n", len(functions), *below)
+	for _, f := range functions {
+		fmt.Printf("%.2f  %s  %s\n", f.Confidence, f.FunctionName, f.FilePath)
+	}
+}
@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"code_assistant/src/config"
+	"code_assistant/src/findings"
+	"code_assistant/src/gitutil"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// findingFormats lists the output formats of commands producing findings
+var findingFormats = map[string]bool{"text": true, "json": true, "sarif": true}
+
+// writeFindings writes findings in the given format to outPath, or to stdout if it is empty
+func writeFindings(outPath string, format string, results []findings.Finding, rules []findings.Rule) {
+	var w io.Writer = os.Stdout
+	if outPath != "" {
+		f, err := os.Create(outPath)
+		if err != nil {
+			fmt.Printf("Failed to create %s: %v\n", outPath, err)
+			return
+		}
+		defer f.Close()
+		w = f
+	}
+
+	var err error
+	switch format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(results)
+	case "sarif":
+		err = findings.WriteSarif(w, results, findings.SarifOptions{ToolName: "code_assistant", ToolVersion: "0.1.0",
+			BaseDir: sourceRoot(), Rules: rules})
+	default:
+		printFindings(w, results)
+	}
+	if err != nil {
+		fmt.Printf("Failed to write findings: %v\n", err)
+		return
+	}
+	if outPath != "" {
+		fmt.Printf("Wrote %d findings to %s\n", len(results), outPath)
+	}
+}
+
+// sourceRoot returns the directory SARIF paths are made relative to: the repository root of the
+// working directory, or the working directory itself outside of a repository
+func sourceRoot() string {
+	dir := config.AppConfig.WorkingDir
+	if dir == "" {
+		dir = "."
+	}
+	if root, err := gitutil.RepoRoot(dir); err == nil {
+		return root
+	}
+	root, _ := filepath.Abs(dir)
+	return root
+}
+
+// printFindings prints findings grouped by file
+func printFindings(w io.Writer, results []findings.Finding) {
+	if len(results) == 0 {
+		fmt.Fprintln(w, "No findings.")
+		return
+	}
+
+	currentFile := ""
+	for _, f := range results {
+		if f.FilePath != currentFile {
+			currentFile = f.FilePath
+			fmt.Fprintf(w, "\n%s\n", currentFile)
+		}
+		fmt.Fprintf(w, "  %d [%s] %s: %s\n", f.LineStart, f.Severity, f.RuleId, f.Message)
+		if f.Suggestion != "" {
+			fmt.Fprintf(w, "      Suggestion: %s\n", f.Suggestion)
+		}
+	}
+}
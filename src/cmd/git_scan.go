@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"code_assistant/src/code_analyzer"
+	"code_assistant/src/config"
+	"flag"
+	"fmt"
+)
+
+// handleGitScan scans the changes of a git range instead of a directory
+func handleGitScan(args []string) {
+	flags := flag.NewFlagSet("scan", flag.ContinueOnError)
+	gitDiff := flags.String("git-diff", "", "Scan the changes of a range, e.g. main...HEAD")
+	since := flags.String("since", "", "Scan everything changed since the given commit, including uncommitted changes")
+	repo := flags.String("repo", config.AppConfig.WorkingDir, "Repository directory")
+	if err := flags.Parse(args); err != nil {
+		return
+	}
+
+	rangeSpec := *gitDiff
+	if *since != "" {
+		if rangeSpec != "" {
+			fmt.Println("--git-diff and --since cannot be combined")
+			return
+		}
+		rangeSpec = *since
+	}
+	if rangeSpec == "" {
+		fmt.Println("Usage: scan --git-diff <range> | --since <commit> [--repo <dir>]")
+		return
+	}
+	if *repo == "" {
+		*repo = "."
+	}
+
+	fmt.Printf("Scanning changes of %s in %s ...\n", rangeSpec, *repo)
+	if err := code_analyzer.AnalyzeGitDiff(*repo, rangeSpec); err != nil {
+		fmt.Printf("Failed to scan git changes: %v\n", err)
+	}
+}
@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"code_assistant/src/code_analyzer"
+	"code_assistant/src/config"
+	"flag"
+	"fmt"
+)
+
+// handleSnapshot indexes a git revision or lists the indexed revisions
+func handleSnapshot(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: snapshot <revision> [--repo <dir>] | snapshot list")
+		return
+	}
+	if args[0] == "list" {
+		listSnapshots()
+		return
+	}
+
+	flags := flag.NewFlagSet("snapshot", flag.ContinueOnError)
+	repo := flags.String("repo", config.AppConfig.WorkingDir, "Repository directory")
+	if err := flags.Parse(args[1:]); err != nil {
+		return
+	}
+	if *repo == "" {
+		*repo = "."
+	}
+
+	fmt.Printf("Indexing revision %s of %s ...\n", args[0], *repo)
+	id, err := code_analyzer.SnapshotRevision(*repo, args[0])
+	if err != nil && id > 0 {
+		fmt.Printf("Snapshot %d is incomplete, index the revision again to retry: %v\n", id, err)
+		return
+	}
+	if err != nil {
+		fmt.Printf("Failed to index revision: %v\n", err)
+		return
+	}
+	fmt.Printf("Snapshot %d done.\n", id)
+}
+
+func listSnapshots() {
+	snapshots, err := code_analyzer.ListSnapshots()
+	if err != nil {
+		fmt.Printf("Failed to list snapshots: %v\n", err)
+		return
+	}
+	if len(snapshots) == 0 {
+		fmt.Println("No snapshots indexed.")
+		return
+	}
+	for _, s := range snapshots {
+		incomplete := ""
+		if !s.Complete {
+			incomplete = " incomplete"
+		}
+		fmt.Printf("%d: %s %.10s %s (%d files%s) %s\n", s.Id, s.CommitDatetime, s.CommitSHA, s.Revision, s.FileCount, incomplete, s.RepoRoot)
+	}
+}
+
+// handleHistory prints how a function evolved across the indexed snapshots
+func handleHistory(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: history <function> [--no-summary]")
+		return
+	}
+
+	flags := flag.NewFlagSet("history", flag.ContinueOnError)
+	noSummary := flags.Bool("no-summary", false, "Do not ask the model to summarize the changes")
+	if err := flags.Parse(args[1:]); err != nil {
+		return
+	}
+
+	functionName := args[0]
+	versions, err := code_analyzer.FunctionHistory(functionName)
+	if err != nil {
+		fmt.Printf("Failed to load history: %v\n", err)
+		return
+	}
+	if len(versions) == 0 {
+		fmt.Printf("Function %s not found in any snapshot, index revisions with 'snapshot <revision>'\n", functionName)
+		return
+	}
+
+	var previous *code_analyzer.FunctionVersion
+	for i := range versions {
+		v := versions[i]
+		fmt.Printf("\n%.10s %s (%s)\n", v.CommitSHA, v.Revision, v.CommitDatetime)
+		if previous != nil && previous.BodySHA256 == v.BodySHA256 && previous.FilePath == v.FilePath {
+			fmt.Printf("  unchanged, %s:%d-%d\n", v.FilePath, v.LineStart, v.LineEnd)
+			previous = &versions[i]
+			continue
+		}
+		fmt.Printf("  %s:%d-%d\n", v.FilePath, v.LineStart, v.LineEnd)
+		fmt.Printf("  Signature: %s\n", v.Signature)
+		fmt.Printf("  Description: %s\n", v.Description)
+
+		if previous != nil && !*noSummary {
+			summary, err := code_analyzer.SummarizeFunctionChange(functionName, *previous, v)
+			if err != nil {
+				fmt.Printf("  Failed to summarize change: %v\n", err)
+			} else {
+				fmt.Printf("  Changes: %s\n", summary)
+			}
+		}
+		previous = &versions[i]
+	}
+}
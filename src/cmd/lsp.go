@@ -0,0 +1,13 @@
+package cmd
+
+import (
+	"code_assistant/src/lsp"
+	"log"
+)
+
+// handleLsp serves the code index to editors over the Language Server Protocol on stdio
+func handleLsp() {
+	if err := lsp.ServeStdio(); err != nil {
+		log.Printf("Language server stopped: %v", err)
+	}
+}
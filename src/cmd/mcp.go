@@ -0,0 +1,13 @@
+package cmd
+
+import (
+	"code_assistant/src/mcp"
+	"log"
+)
+
+// handleMcp serves the code index to MCP clients over stdio
+func handleMcp() {
+	if err := mcp.ServeStdio(); err != nil {
+		log.Printf("MCP server stopped: %v", err)
+	}
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"code_assistant/src/config"
+	"code_assistant/src/fileutil"
+	"code_assistant/src/lang"
+	"code_assistant/src/policy"
+	"flag"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// handlePolicy shows what the privacy policy lets through to the model
+func handlePolicy(args []string) {
+	if len(args) == 0 || strings.ToLower(args[0]) != "check" {
+		fmt.Println("Usage: policy check [--dir <dir>] [--mode full|metadata|exclude]")
+		return
+	}
+
+	flags := flag.NewFlagSet("policy check", flag.ContinueOnError)
+	dir := flags.String("dir", config.AppConfig.WorkingDir, "Directory to check")
+	mode := flags.String("mode", "", "Only list files with the given mode")
+	if err := flags.Parse(args[1:]); err != nil {
+		return
+	}
+	if *dir == "" {
+		*dir = "."
+	}
+
+	p := policy.Current()
+	if p.Path == "" {
+		fmt.Printf("No policy file found (%s), the source of every file is sent to the model.\n", policy.DefaultFileName)
+	} else {
+		fmt.Printf("Policy %s\n", p.Path)
+		for _, r := range p.Rules {
+			fmt.Printf("  %d: %s %s\n", r.Line, r.Mode, r.Pattern)
+		}
+	}
+	fmt.Println()
+
+	files, err := fileutil.ScanFiles(*dir, lang.Extensions())
+	if err != nil {
+		fmt.Printf("Failed to list files: %v\n", err)
+		return
+	}
+
+	counts := map[policy.Mode]int{}
+	for _, path := range files {
+		m, rule := p.Match(path)
+		counts[m]++
+		if *mode != "" && string(m) != strings.ToLower(*mode) {
+			continue
+		}
+		rel, err := filepath.Rel(*dir, path)
+		if err != nil {
+			rel = path
+		}
+		reason := "default"
+		if rule != nil {
+			reason = fmt.Sprintf("rule %d: %s %s", rule.Line, rule.Mode, rule.Pattern)
+		}
+		fmt.Printf("%-8s  %s  (%s)\n", m, rel, reason)
+	}
+
+	fmt.Printf("\n%d files: %d sent in full, %d names and signatures only, %d excluded\n",
+		len(files), counts[policy.ModeFull], counts[policy.ModeMetadata], counts[policy.ModeExclude])
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"code_assistant/src/config"
+	"code_assistant/src/llm_prompt"
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// handlePrompts lists the prompt templates in use or exports the built-in ones to start a prompt directory
+func handlePrompts(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: prompts list [--language <name>] [--model <name>] | prompts export --out <dir> [--overwrite]")
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "list":
+		flags := flag.NewFlagSet("prompts list", flag.ContinueOnError)
+		language := flags.String("language", "", "Language the prompts are rendered for")
+		model := flags.String("model", config.AppConfig.Ollama.ChatModel, "Model the prompts are sent to")
+		if err := flags.Parse(args[1:]); err != nil {
+			return
+		}
+		for _, t := range llm_prompt.Templates(*language, *model) {
+			fmt.Printf("%-32s %s  %s\n", t.Name, t.Version, t.Path)
+		}
+
+	case "export":
+		flags := flag.NewFlagSet("prompts export", flag.ContinueOnError)
+		out := flags.String("out", "", "Directory the built-in templates are written to")
+		overwrite := flags.Bool("overwrite", false, "Replace existing templates")
+		if err := flags.Parse(args[1:]); err != nil {
+			return
+		}
+		if *out == "" {
+			fmt.Println("Usage: prompts export --out <dir> [--overwrite]")
+			return
+		}
+		written, err := llm_prompt.ExportTemplates(*out, *overwrite)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		fmt.Printf("%d templates written to %s\n", len(written), *out)
+
+	default:
+		fmt.Println("Usage: prompts list [--language <name>] [--model <name>] | prompts export --out <dir> [--overwrite]")
+	}
+}
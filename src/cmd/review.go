@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"code_assistant/src/config"
+	"code_assistant/src/findings"
+	"code_assistant/src/review"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// handleReview reviews a diff file or a git range with the chat model
+func handleReview(args []string) {
+	flags := flag.NewFlagSet("review", flag.ContinueOnError)
+	diffSpec := flags.String("diff", "", "Unified diff file or git range to review, e.g. main...HEAD")
+	repo := flags.String("repo", config.AppConfig.WorkingDir, "Repository directory")
+	format := flags.String("format", "text", "Output format: text, json or sarif")
+	outPath := flags.String("out", "", "Write the findings to a file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return
+	}
+	if *diffSpec == "" {
+		fmt.Println("Usage: review --diff <file|range> [--repo <dir>] [--format text|json|sarif] [--out <file>]")
+		return
+	}
+	*format = strings.ToLower(*format)
+	if !findingFormats[*format] {
+		fmt.Printf("Unknown format %s\n", *format)
+		return
+	}
+	if *repo == "" {
+		*repo = "."
+	}
+
+	// keep the progress output of the review away from the findings
+	out := os.Stdout
+	if *outPath == "" && *format != "text" {
+		os.Stdout = os.Stderr
+	}
+	files, err := review.LoadDiff(*repo, *diffSpec)
+	var results []findings.Finding
+	if err == nil {
+		results, err = review.Review(files)
+	}
+	os.Stdout = out
+	if err != nil {
+		fmt.Printf("Failed to review %s: %v\n", *diffSpec, err)
+		return
+	}
+
+	writeFindings(*outPath, *format, results, review.Rules)
+}
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"code_assistant/src/server"
+	"flag"
+	"fmt"
+)
+
+// handleServe starts the HTTP/JSON API server and blocks until it stops
+func handleServe(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	addr := flags.String("addr", "127.0.0.1:8080", "Address to listen on")
+	if err := flags.Parse(args); err != nil {
+		return
+	}
+
+	if err := server.ListenAndServe(*addr); err != nil {
+		fmt.Printf("Server stopped: %v\n", err)
+	}
+}
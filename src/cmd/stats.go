@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"code_assistant/src/code_analyzer"
+	"code_assistant/src/http_client"
+	"flag"
+	"fmt"
+	"time"
+)
+
+// handleStats prints the tokens and time the model spent, in total, per model, per step, per scan and for the slowest files
+func handleStats(args []string) {
+	flags := flag.NewFlagSet("stats", flag.ContinueOnError)
+	scanId := flags.Int64("scan", 0, "Only calls made by this scan")
+	last := flags.Bool("last", false, "Only calls made by the most recent scan")
+	model := flags.String("model", "", "Only calls to this model")
+	top := flags.Int("top", 10, "Number of slowest files and most recent scans to list")
+	if err := flags.Parse(args); err != nil {
+		return
+	}
+	if *last {
+		*scanId = code_analyzer.LastScanId()
+	}
+
+	filter := http_client.UsageFilter{ScanId: *scanId, Model: *model}
+	totals, err := http_client.ListUsage("", filter)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if len(totals) == 0 || totals[0].Calls == 0 {
+		fmt.Println("No calls to the model recorded, answers from the cache are not counted.")
+		return
+	}
+	t := totals[0]
+	fmt.Printf("calls: %d\n", t.Calls)
+	fmt.Printf("tokens: %d prompt, %d completion\n", t.PromptTokens, t.CompletionTokens)
+	fmt.Printf("time: %s wall, %s load, %s prompt eval, %s eval\n", roundDuration(t.Wall), roundDuration(t.LoadDuration),
+		roundDuration(t.PromptEvalDuration), roundDuration(t.EvalDuration))
+	fmt.Printf("throughput: %.1f prompt tokens/s, %.1f completion tokens/s\n", t.PromptTokensPerSecond(), t.TokensPerSecond())
+
+	printUsage("Per model", http_client.ByModel, filter)
+	printUsage("Per step", http_client.ByStep, filter)
+
+	if *scanId == 0 {
+		scans, err := code_analyzer.ListScans(*top)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			return
+		}
+		if len(scans) > 0 {
+			fmt.Println("\nPer scan:")
+		}
+		for _, s := range scans {
+			usage, err := http_client.ListUsage("", http_client.UsageFilter{ScanId: s.Id, Model: *model})
+			if err != nil || len(usage) == 0 {
+				continue
+			}
+			duration := "unfinished"
+			if !s.Finished.IsZero() {
+				duration = roundDuration(s.Duration()).String()
+			}
+			fmt.Printf("  %5d  %s  %-9s %-40s %10s  %s\n", s.Id, s.Started.Format(time.DateTime), s.Kind, s.Target, duration, usageLine(usage[0]))
+		}
+	}
+
+	filter.Limit = *top
+	printUsage("Slowest files", http_client.ByFile, filter)
+}
+
+// printUsage prints the usage of the calls grouped by a column, longest wall time first
+func printUsage(title string, groupBy string, filter http_client.UsageFilter) {
+	stats, err := http_client.ListUsage(groupBy, filter)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		return
+	}
+	if len(stats) == 0 {
+		return
+	}
+	fmt.Printf("\n%s:\n", title)
+	for _, s := range stats {
+		key := s.Key
+		if key == "" {
+			key = "-"
+		}
+		fmt.Printf("  %-40s %s\n", key, usageLine(s))
+	}
+}
+
+// usageLine formats the usage of a group of calls on one line
+func usageLine(s http_client.UsageStats) string {
+	tokens := fmt.Sprintf("%d+%d", s.PromptTokens, s.CompletionTokens)
+	return fmt.Sprintf("%5d calls  %15s tokens  %10s wall  %7.1f tokens/s", s.Calls, tokens, roundDuration(s.Wall), s.TokensPerSecond())
+}
+
+// roundDuration rounds a duration for display
+func roundDuration(d time.Duration) time.Duration {
+	return d.Round(time.Millisecond)
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"code_assistant/src/code_analyzer"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+)
+
+// activeWatcher is the watcher started from the prompt, only one can run at a time
+var activeWatcher *code_analyzer.DirectoryWatcher
+
+// handleWatch starts, stops or shows the background watcher
+func handleWatch(args []string) {
+	if len(args) == 0 {
+		fmt.Println("Usage: watch <dir> | watch status | watch stop")
+		return
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "stop":
+		if activeWatcher == nil {
+			fmt.Println("No directory is being watched.")
+			return
+		}
+		activeWatcher.Stop()
+		fmt.Printf("Stopped watching %s\n", activeWatcher.Directory)
+		activeWatcher = nil
+
+	case "status":
+		if activeWatcher == nil {
+			fmt.Println("No directory is being watched.")
+			return
+		}
+		fmt.Printf("Watching %s (%d code files)\n", activeWatcher.Directory, activeWatcher.FileCount())
+
+	default:
+		if activeWatcher != nil {
+			fmt.Printf("Already watching %s, run 'watch stop' first.\n", activeWatcher.Directory)
+			return
+		}
+		dw, err := code_analyzer.WatchDirectory(args[0])
+		if err != nil {
+			fmt.Printf("Failed to watch %s: %v\n", args[0], err)
+			return
+		}
+		activeWatcher = dw
+		fmt.Printf("Watching %s (%d code files)\n", dw.Directory, dw.FileCount())
+		if !interactive {
+			// started from the command line, keep running until interrupted
+			signals := make(chan os.Signal, 1)
+			signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+			<-signals
+			dw.Stop()
+		}
+	}
+}
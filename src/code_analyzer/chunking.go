@@ -0,0 +1,80 @@
+package code_analyzer
+
+import (
+	"code_assistant/src/chunker"
+	"code_assistant/src/config"
+	"code_assistant/src/diffutil"
+	"code_assistant/src/lang"
+	"code_assistant/src/llm_prompt"
+	"code_assistant/src/pipeline"
+	"slices"
+)
+
+// responseTokens is kept free in the context for each answer of the model
+const responseTokens = 256
+
+// Chunks returns the windows the file is sent to the model in, split at top-level declarations so that
+// the prompts of every window fit the context budget of the models.
+func (fa *FileAnalyzer) Chunks() []chunker.Chunk {
+	if fa.chunks != nil {
+		return fa.chunks
+	}
+	var boundaries []int
+	if l, ok := lang.ForFile(fa.FilePath); ok {
+		boundaries = chunker.Boundaries(l, fa.CodeSnippet)
+	}
+	language := GetCodeLanguage(fa.FilePath)
+	fa.chunks = chunker.Split(len(fa.CodeSnippet), boundaries, func(start int, end int) bool {
+		return fa.windowFits(language, start, end)
+	})
+	return fa.chunks
+}
+
+// windowFits reports whether the prompts about the lines [start, end) fit the context budgets.
+//
+// Every model of the ensemble runs is checked. The function list is a single prompt to the text generation
+// models, the chat models locate and analyze a function with the pipelines of their profile.
+func (fa *FileAnalyzer) windowFits(language string, start int, end int) bool {
+	system := llm_prompt.SystemPrompt()
+	for _, model := range runModels(config.AppConfig.Ollama.TextGenModel) {
+		listTokens := chunker.EstimateTokens(model, system) +
+			chunker.EstimateTokens(model, llm_prompt.GetFunctionList(language, fa.CodeSnippet, start, end)) + responseTokens
+		if listTokens > chunker.ContextTokens(model) {
+			return false
+		}
+	}
+
+	in := pipeline.Input{FunctionName: "function", Language: language, Lines: fa.CodeSnippet, LineStart: start, LineEnd: end}
+	for _, model := range runModels(config.AppConfig.Ollama.ChatModel) {
+		profile := pipeline.ProfileFor(model)
+		if !profile.Identify.Fits(in, model, system, responseTokens) || !profile.Analyze.Fits(in, model, system, responseTokens) {
+			return false
+		}
+	}
+	return true
+}
+
+// runModels returns the distinct models of the ensemble runs of a step whose model is defaultModel
+func runModels(defaultModel string) []string {
+	var models []string
+	for _, run := range ensembleRuns(defaultModel, 0) {
+		if !slices.Contains(models, run.Model) {
+			models = append(models, run.Model)
+		}
+	}
+	return models
+}
+
+// chunksTouching returns the windows overlapping the given 0-based inclusive line ranges
+func (fa *FileAnalyzer) chunksTouching(changed []diffutil.LineRange) []chunker.Chunk {
+	var touched []chunker.Chunk
+	for _, c := range fa.Chunks() {
+		for _, r := range changed {
+			if r.Start < c.End && r.End >= c.Start {
+				touched = append(touched, c)
+				break
+			}
+		}
+	}
+	return touched
+}
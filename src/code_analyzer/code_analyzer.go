@@ -1,35 +1,66 @@
 package code_analyzer
 
 import (
+	"code_assistant/src/chunker"
+	"code_assistant/src/config"
 	"code_assistant/src/db"
+	"code_assistant/src/diffutil"
 	"code_assistant/src/fileutil"
+	"code_assistant/src/gitutil"
 	"code_assistant/src/http_client"
+	"code_assistant/src/lang"
 	"code_assistant/src/llm_prompt"
+	"code_assistant/src/pipeline"
+	"code_assistant/src/policy"
 	"code_assistant/src/util"
 	"crypto/sha256"
+	"database/sql"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// ProgressFunc is called after each file of a directory scan
+type ProgressFunc func(done int, total int, filePath string)
+
 // Entry point in code_analyzer
-func AnalyzeDirectory(directory string) {
-	ext := []string{".go", ".h", ".cpp", ".hpp", ".js", ".ts", ".py", ".java"}
-	codeFilePaths, _ := fileutil.ScanFiles(directory, ext)
+func AnalyzeDirectory(directory string) error {
+	return AnalyzeDirectoryWithProgress(directory, nil)
+}
+
+// AnalyzeDirectoryWithProgress scans a directory like AnalyzeDirectory and reports progress after each file.
+// A file that fails is left for the next scan and the others are scanned, the failures are returned together.
+func AnalyzeDirectoryWithProgress(directory string, progress ProgressFunc) error {
+	codeFilePaths, skipped, _ := fileutil.ScanFilesWithSkipped(directory, lang.Extensions())
 	// fmt.Println(codeFilePaths) // DEBUG
+	for _, s := range skipped {
+		recordSkippedFile(s)
+	}
 
-	for _, path := range codeFilePaths {
+	var errs []error
+	scanId := beginScan(ScanDirectory, directory)
+	for idx, path := range codeFilePaths {
 		fa, err := NewFunctionAnalyzer(path)
+		if err == nil && fa != nil {
+			fa.scanId = scanId
+			err = fa.ScanFile()
+		}
 		if err != nil {
-			log.Panicln(err)
+			log.Printf("Failed to scan %s: %v", path, err)
+			errs = append(errs, fmt.Errorf("%s: %v", path, err))
 		}
-		if fa == nil {
-			continue
+		if progress != nil {
+			progress(idx+1, len(codeFilePaths), path)
 		}
-		fa.ScanFile()
 	}
+	finishScan(scanId)
+	return errors.Join(errs...)
 }
 
 // GetCodeLanguage returns the programming language based on the file extension.
@@ -37,54 +68,37 @@ func AnalyzeDirectory(directory string) {
 // filePath: a string representing the file path.
 // string: the programming language based on the file extension.
 func GetCodeLanguage(filePath string) string {
-	switch {
-	case strings.HasSuffix(filePath, ".cpp"):
-		return "cpp"
-	case strings.HasSuffix(filePath, ".h"):
-		return "cpp"
-	case strings.HasSuffix(filePath, ".hpp"):
-		return "cpp"
-	case strings.HasSuffix(filePath, ".js"):
-		return "javascript"
-	case strings.HasSuffix(filePath, ".ts"):
-		return "typescript"
-	case strings.HasSuffix(filePath, ".go"):
-		return "golang"
-	case strings.HasSuffix(filePath, ".java"):
-		return "java"
-	case strings.HasSuffix(filePath, ".py"):
-		return "python"
-	default:
-		return "unkown type"
+	if l, ok := lang.ForFile(filePath); ok {
+		return l.Name
 	}
+	return "unkown type"
 }
 
 func GetFileFromDb(filePath string) (int, string, error) {
 	// get row from db where functionName matches
-	rows, _ := db.GetDatabase().Query("SELECT id, sha256 FROM files WHERE file_path = ? LIMIT 1", filePath)
-
-	defer rows.Close()
-
-	// Iterate over the rows and print out the values
-	for rows.Next() {
-		var id int = -1
-		var hash string = ""
-
-		err := rows.Scan(&id, &hash)
-		if err != nil {
-			log.Fatal(err)
-		}
-		if id > -1 {
-			return id, hash, nil
-		}
+	var id int
+	var hash string
+	err := db.GetDatabase().QueryRow("SELECT id, sha256 FROM files WHERE file_path = ? LIMIT 1", filePath).Scan(&id, &hash)
+	if err == sql.ErrNoRows {
+		return 0, "", fmt.Errorf("file not found")
 	}
-	if err := rows.Err(); err != nil {
-		log.Fatal(err)
+	if err != nil {
 		return 0, "", err
 	}
-	return 0, "", fmt.Errorf("file not found")
+	return id, hash, nil
 }
 
+// FileContentHash returns the SHA256 of the lines of a file joined by newlines.
+func FileContentHash(codeSnippet []string) string {
+	concatenated := strings.Join(codeSnippet, "\n")
+	hash := sha256.New()
+	hash.Write([]byte(concatenated))
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// scanLock serializes scans, a watcher and the prompt may trigger them at the same time
+var scanLock sync.Mutex
+
 type FileAnalyzer struct {
 	FileId      int
 	FilePath    string
@@ -93,6 +107,22 @@ type FileAnalyzer struct {
 	LineEnd     int
 	StepSize    int
 	SHA256      string
+	CommitSHA   string
+
+	// MetadataOnly indexes names and signatures with a local parser, the source is not sent to the model
+	MetadataOnly bool
+
+	chunks    []chunker.Chunk      // windows sent to the model, see Chunks
+	unchanged map[string]bool      // functions whose body hash did not change since the last scan
+	requeued  []diffutil.LineRange // 0-based lines of unchanged functions with a low confidence, scanned again
+	attempts  map[string]int       // attempts of the requeued functions, stored with their new analysis
+	attempt   int                  // highest attempt of a requeued function, the ensemble seeds are chosen by it
+	analyzed  map[string]bool      // functions sent to the model in this scan
+	scanned   []chunker.Chunk      // windows of a partial scan, nil when the whole file is scanned
+	scanId    int64                // scan the calls to the model are recorded for, see beginScan
+
+	// store receives analyzed functions instead of the functions table, used for snapshots
+	store func(functionName string, functionInfo *llm_prompt.AnalyzeFunctionResponse, startLine int, endLine int)
 }
 
 // NewFunctionAnalyzer creates a new FileAnalyzer instance for the given file path.
@@ -110,17 +140,30 @@ func NewFunctionAnalyzer(filePath string) (*FileAnalyzer, error) {
 		return nil, fmt.Errorf("file does not exist %s", filePath)
 	}
 
+	mode := policy.ModeFor(filePath)
+	if mode == policy.ModeExclude {
+		fmt.Printf("Skipping %s, excluded by policy\n", filePath)
+		removeFileFromDb(filePath)
+		return nil, nil
+	}
+
+	if skipped := fileutil.CheckFile(filePath); skipped != nil {
+		recordSkippedFile(*skipped)
+		return nil, nil
+	}
+	clearSkippedFile(filePath)
+
 	// Read file to memory
-	codeSnippet, _ := fileutil.ReadFileLines(filePath)
+	codeSnippet, err := fileutil.ReadFileLines(filePath)
+	if err != nil {
+		return nil, err
+	}
 
 	// Generate SHA256 hash
-	concatenated := strings.Join(codeSnippet, "\n")
-	hash := sha256.New()
-	hash.Write([]byte(concatenated))
-	hashedString := hex.EncodeToString(hash.Sum(nil))
+	hashedString := FileContentHash(codeSnippet)
 
-	_, dbHash, _ := GetFileFromDb(filePath)
-	if dbHash == hashedString {
+	dbFileId, dbHash, _ := GetFileFromDb(filePath)
+	if dbHash == hashedString && !rescanRequired(dbFileId) {
 		// file exists and already analyzed
 		return nil, nil
 	}
@@ -129,13 +172,18 @@ func NewFunctionAnalyzer(filePath string) (*FileAnalyzer, error) {
 	currentTime := time.Now()
 
 	// Try to insert the file into the database
-	// function_name is unique, so there should only be one row
-	db.GetDatabase().Execute("INSERT INTO files (file_path, sha256, last_update_datetime, rescan_required) VALUES (?, ?, ?, ?)", filePath, hashedString, currentTime, 0)
+	// function_name is unique, so there should only be one row.
+	// The hash is stored once the scan succeeded, a failed scan is retried by the next one.
+	db.GetDatabase().Execute("INSERT INTO files (file_path, sha256, last_update_datetime, rescan_required) VALUES (?, ?, ?, ?)", filePath, "", currentTime, 0)
 
 	// Get the ID of the inserted record
-	dbFileId, _, _ := GetFileFromDb(filePath)
+	dbFileId, _, err = GetFileFromDb(filePath)
+	if err != nil {
+		return nil, err
+	}
 
-	fa := &FileAnalyzer{FileId: dbFileId, FilePath: filePath, CodeSnippet: codeSnippet, LineStart: 0, LineEnd: 0, StepSize: 100, SHA256: hashedString}
+	fa := &FileAnalyzer{FileId: dbFileId, FilePath: filePath, CodeSnippet: codeSnippet, LineStart: 0, LineEnd: 0, StepSize: 100, SHA256: hashedString,
+		CommitSHA: gitutil.FileRevision(filePath), MetadataOnly: mode == policy.ModeMetadata}
 
 	// Set LineEnd
 	if len(codeSnippet) < fa.StepSize {
@@ -144,6 +192,9 @@ func NewFunctionAnalyzer(filePath string) (*FileAnalyzer, error) {
 		fa.LineEnd = fa.StepSize
 	}
 
+	// Keep the analysis of functions whose body did not change
+	fa.relocateFunctions()
+
 	return fa, nil
 }
 
@@ -162,18 +213,82 @@ func (fa *FileAnalyzer) EnlargeWindow(step int) {
 	fa.LineEnd += step
 }
 
-// Entry point in FileAnalyzer
-func (fa *FileAnalyzer) ScanFile() {
+// setWindow moves the window to a chunk of the file.
+func (fa *FileAnalyzer) setWindow(c chunker.Chunk) {
+	fa.LineStart = c.Start
+	fa.LineEnd = c.End
+}
+
+// Entry point in FileAnalyzer.
+// If a window fails the other windows are still scanned, but the file is not marked as scanned so the next scan retries it.
+func (fa *FileAnalyzer) ScanFile() error {
+	scanLock.Lock()
+	defer scanLock.Unlock()
 
+	fa.scanned = nil
 	fmt.Printf("Scanning file %s\n", fa.FilePath)
-	fa.ScanContent()
+	var errs []error
+	if fa.MetadataOnly {
+		fa.ScanMetadata()
+	} else {
+		for _, c := range fa.Chunks() {
+			fa.setWindow(c)
+			if err := fa.ScanContent(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return fa.finishFile(errors.Join(errs...))
+}
+
+// finishFile removes the functions that were not found anymore and stores the hash of the scanned file.
+// Nothing is changed if the scan failed, since functions may be missing only because their window failed.
+func (fa *FileAnalyzer) finishFile(scanErr error) error {
+	if scanErr != nil {
+		return scanErr
+	}
+	fa.removeStaleFunctions()
 
 	// Update the file in the database
-	db.GetDatabase().Execute("UPDATE files SET sha256 = ?, last_update_datetime = ? WHERE id = ?", fa.SHA256, time.Now(), fa.FileId)
+	db.GetDatabase().Execute("UPDATE files SET sha256 = ?, last_update_datetime = ?, commit_sha = ? WHERE id = ?", fa.SHA256, time.Now(), fa.CommitSHA, fa.FileId)
+	fa.updateRescanRequired()
+	return nil
+}
+
+// ScanChangedLines scans only the windows overlapping the given 0-based line ranges.
+// A file with functions indexed without a body hash is scanned in full, they cannot be recognized as unchanged.
+func (fa *FileAnalyzer) ScanChangedLines(changed []diffutil.LineRange) error {
+	if fa.hasUnhashedFunctions() {
+		fmt.Printf("File %s was indexed without function hashes, scanning it in full\n", fa.FilePath)
+		return fa.ScanFile()
+	}
+
+	scanLock.Lock()
+	defer scanLock.Unlock()
+
+	var windows []chunker.Chunk
+	if fa.MetadataOnly {
+		// the parser is cheap, the whole file is parsed
+		fa.ScanMetadata()
+	} else {
+		windows = fa.chunksTouching(append(changed, fa.requeued...))
+		fa.scanned = append([]chunker.Chunk{}, windows...)
+	}
+
+	fmt.Printf("Scanning %d changed windows of file %s\n", len(windows), fa.FilePath)
+	var errs []error
+	for _, c := range windows {
+		fa.setWindow(c)
+		if err := fa.ScanContent(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return fa.finishFile(errors.Join(errs...))
 }
 
-// Scan Content in a window
-func (fa *FileAnalyzer) ScanContent() {
+// Scan Content in a window.
+// A function that cannot be located or analyzed does not stop the others, all failures are returned together.
+func (fa *FileAnalyzer) ScanContent() error {
 
 	// 1 Get Code Language
 	language := GetCodeLanguage(fa.FilePath)
@@ -183,46 +298,208 @@ func (fa *FileAnalyzer) ScanContent() {
 
 	// Get a default TextGenRequest struct
 	req := http_client.NewTextGenRequest()
+	req.SourcePaths = []string{fa.FilePath}
+	req.Trace = http_client.Trace{Step: "GetFunctionList", ScanId: fa.scanId}
 
 	// 3 Search For Functions
 	prompt := llm_prompt.GetFunctionList(language, fa.CodeSnippet, fa.LineStart, fa.LineEnd)
 	fmt.Printf("GetFunctionList\n%s\n\n", prompt) //DEBUG
 	req.Prompt = prompt
 
-	// Call TextGenerateRemote function
-	resp, err := http_client.TextGenerateRemote(req)
-	if err != nil {
-		log.Fatalf("Error calling TextGenerateRemote: %v", err)
-	}
+	// In ensemble mode every run lists the functions, a function needs to be listed by most runs
+	runs := ensembleRuns(req.Model, fa.attempt)
+	var names []string
+	listedBy := make([]map[string]bool, len(runs))
+	for idx, run := range runs {
+		req.Model = run.Model
+		req.Options = run.options()
 
-	// Print response
-	fmt.Println("Result:", resp.Result)
-	fmt.Println("Token:", resp.Token)
+		// Call TextGenerateRemote function
+		resp, err := http_client.TextGenerateRemote(req)
+		if err != nil {
+			return fmt.Errorf("error calling TextGenerateRemote: %v", err)
+		}
 
-	functions, _ := util.ParseJsonArray[llm_prompt.FunctionListItem](resp.Result)
+		// Print response
+		fmt.Println("Result:", resp.Result)
+		fmt.Println("Token:", resp.Token)
+
+		functions, _ := util.ParseJsonArray[llm_prompt.FunctionListItem](resp.Result)
+		listedBy[idx] = map[string]bool{}
+		for _, f := range functions {
+			// if something goes wrong when parsing
+			if f.FunctionName == "" || listedBy[idx][f.FunctionName] {
+				continue
+			}
+			listedBy[idx][f.FunctionName] = true
+			if !slices.Contains(names, f.FunctionName) {
+				names = append(names, f.FunctionName)
+			}
+		}
+	}
+
+	if fa.analyzed == nil {
+		fa.analyzed = map[string]bool{}
+	}
 
 	// Access the parsed objects
-	for _, f := range functions {
+	var errs []error
+	for _, functionName := range names {
+		votes := make([]ensembleVote, len(runs))
+		for idx, run := range runs {
+			votes[idx] = ensembleVote{Run: run, Answer: strconv.FormatBool(listedBy[idx][functionName])}
+		}
+		winner, listConfidence := majority(votes)
+		fa.recordVotes(StepFunctionList, functionName, votes, winner)
+		if winner != "true" {
+			fmt.Printf("Function %s listed by too few runs, skipping\n", functionName)
+			continue
+		}
 
-		// if something goes wrong when parsing
-		if f.FunctionName == "" {
+		if fa.unchanged[functionName] {
+			fmt.Printf("Function %s unchanged, keeping stored analysis\n", functionName)
+			continue
+		}
+		if fa.analyzed[functionName] {
+			// windows overlap where a construct was too large for one
+			fmt.Printf("Function %s already analyzed in this scan, skipping\n", functionName)
 			continue
 		}
 
-		validFunction, startLine, endLine := IdentifyFunction(fa, f.FunctionName, language)
-		if !validFunction {
+		located, err := fa.locateFunction(functionName, language)
+		if err != nil {
+			log.Println(err)
+			errs = append(errs, err)
+			continue
+		}
+		if !located.Found {
 			continue
 		}
 
-		functionInfo, err := AnalyzeFunction(fa, language, f.FunctionName, startLine, endLine)
+		functionInfo, err := AnalyzeFunction(fa, language, functionName, located)
 		if err != nil {
+			log.Println(err)
+			errs = append(errs, err)
 			continue
 		}
 
-		// Remove old record if exists
-		db.GetDatabase().Execute(`DELETE FROM functions WHERE function_name = ? AND file_id = ?`, f.FunctionName, fa.FileId)
+		fa.storeFunction(functionName, functionInfo, located.LineStart, located.LineEnd, located.BoundsVerified, min(listConfidence, located.Confidence))
+	}
+	return errors.Join(errs...)
+}
+
+// locatedFunction is where the runs located a function
+type locatedFunction struct {
+	Found     bool
+	LineStart int
+	LineEnd   int
+	// BoundsVerified tells whether the range was confirmed by the source
+	BoundsVerified bool
+	// Confidence is the share of runs that found the range
+	Confidence float64
+	// Run is the first run that found the range, and Answer its answer
+	Run    ensembleRun
+	Answer pipeline.Result
+}
 
-		db.GetDatabase().Execute(`INSERT INTO functions (function_name, signature, arguments, return, namespace, description, file_id, line_start, line_end) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
-			f.FunctionName, functionInfo.Signature, functionInfo.Arguments, functionInfo.Return, "NONE", functionInfo.Purpose, fa.FileId, startLine, endLine)
+// locateFunction finds the line range of a function, verified against the source.
+//
+// In ensemble mode every run locates the function and the range found by most runs wins. The
+// confidence is the share of runs that found it, 1 for a single run.
+func (fa *FileAnalyzer) locateFunction(functionName string, language string) (locatedFunction, error) {
+	var votes []ensembleVote
+	found := map[string]locatedFunction{}
+	for _, run := range ensembleRuns(config.AppConfig.Ollama.ChatModel, fa.attempt) {
+		answer, err := IdentifyFunction(fa, functionName, language, run)
+		if err != nil {
+			return locatedFunction{}, err
+		}
+		validFunction, startLine, endLine := answer.Complete, answer.StartLine, answer.EndLine
+		if validFunction {
+			var boundsVerified bool
+			var err error
+			startLine, endLine, boundsVerified, err = fa.verifyBounds(functionName, startLine, endLine)
+			if err != nil {
+				fmt.Printf("Rejecting function %s: %v\n", functionName, err)
+				validFunction = false
+			}
+			if key := locateAnswer(validFunction, startLine, endLine); validFunction && !found[key].Found {
+				found[key] = locatedFunction{Found: true, LineStart: startLine, LineEnd: endLine, BoundsVerified: boundsVerified, Run: run, Answer: answer}
+			}
+		}
+		votes = append(votes, ensembleVote{Run: run, Answer: locateAnswer(validFunction, startLine, endLine)})
+	}
+
+	winner, confidence := majority(votes)
+	fa.recordVotes(StepLocateFunction, functionName, votes, winner)
+	located := found[winner]
+	located.Confidence = confidence
+	return located, nil
+}
+
+// verifyBounds checks the 1-based line range the model reported for a function against the source.
+// The range is corrected to the declaration found in the source, and rejected if it starts outside of the window.
+func (fa *FileAnalyzer) verifyBounds(functionName string, startLine int, endLine int) (int, int, bool, error) {
+	l, ok := lang.ForFile(fa.FilePath)
+	if !ok {
+		return startLine, endLine, false, nil
+	}
+	start, end, verified, err := l.VerifyBounds(fa.CodeSnippet, functionName, startLine, endLine)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	if start <= fa.LineStart || start > fa.LineEnd {
+		return 0, 0, false, fmt.Errorf("line %d is outside of the window %d-%d", start, fa.LineStart+1, fa.LineEnd)
+	}
+	if start != startLine || end != endLine {
+		fmt.Printf("Function %s moved from lines %d-%d to %d-%d\n", functionName, startLine, endLine, start, end)
+	}
+	return start, end, verified, nil
+}
+
+// ScanMetadata indexes the functions of the file with the local parser of its language.
+// Only names, signatures and line ranges are stored, nothing is sent to the model.
+func (fa *FileAnalyzer) ScanMetadata() {
+	if fa.analyzed == nil {
+		fa.analyzed = map[string]bool{}
 	}
+	l, ok := lang.ForFile(fa.FilePath)
+	if !ok {
+		return
+	}
+	for _, d := range l.FindDeclarations(fa.CodeSnippet) {
+		if fa.unchanged[d.Name] || fa.analyzed[d.Name] {
+			continue
+		}
+		fa.storeFunction(d.Name, &llm_prompt.AnalyzeFunctionResponse{Signature: d.Signature}, d.LineStart, d.LineEnd, true, 1)
+	}
+}
+
+// storeFunction saves the analysis of a function to the functions table, or to the store of the analyzer if it has one.
+// boundsVerified tells whether the line range was confirmed by the source, confidence is the agreement of the ensemble runs.
+func (fa *FileAnalyzer) storeFunction(functionName string, functionInfo *llm_prompt.AnalyzeFunctionResponse, startLine int, endLine int, boundsVerified bool, confidence float64) {
+	fa.analyzed[functionName] = true
+	if fa.store != nil {
+		fa.store(functionName, functionInfo, startLine, endLine)
+		return
+	}
+
+	// an analysis done again keeps the previous answer if the runs agreed more on it
+	if attempts, requeued := fa.attempts[functionName]; requeued {
+		var previous float64
+		err := db.GetDatabase().QueryRow(`SELECT confidence FROM functions WHERE function_name = ? AND file_id = ?`, functionName, fa.FileId).Scan(&previous)
+		if err == nil && previous >= confidence {
+			fmt.Printf("Keeping the previous analysis of %s with a confidence of %.2f\n", functionName, previous)
+			db.GetDatabase().Execute(`UPDATE functions SET attempts = ? WHERE function_name = ? AND file_id = ?`, attempts, functionName, fa.FileId)
+			return
+		}
+	}
+
+	// Remove old record if exists
+	db.GetDatabase().Execute(`DELETE FROM doc_drift WHERE function_id IN (SELECT id FROM functions WHERE function_name = ? AND file_id = ?)`, functionName, fa.FileId)
+	db.GetDatabase().Execute(`DELETE FROM functions WHERE function_name = ? AND file_id = ?`, functionName, fa.FileId)
+
+	db.GetDatabase().Execute(`INSERT INTO functions (function_name, signature, arguments, return, namespace, description, file_id, line_start, line_end, body_sha256, bounds_verified, confidence, attempts, prompt_version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		functionName, functionInfo.Signature, functionInfo.Arguments, functionInfo.Return, "NONE", functionInfo.Purpose, fa.FileId, startLine, endLine,
+		FunctionBodyHash(fa.CodeSnippet, startLine, endLine), boundsVerified, confidence, fa.attempts[functionName], functionInfo.PromptVersion)
 }
@@ -0,0 +1,206 @@
+package code_analyzer
+
+import (
+	"code_assistant/src/config"
+	"code_assistant/src/db"
+	"code_assistant/src/docgen"
+	"code_assistant/src/fileutil"
+	"code_assistant/src/findings"
+	"code_assistant/src/http_client"
+	"code_assistant/src/index"
+	"code_assistant/src/lang"
+	"code_assistant/src/llm_prompt"
+	"code_assistant/src/policy"
+	"code_assistant/src/util"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+)
+
+// DocDrift is a row of the doc_drift report table
+type DocDrift struct {
+	FunctionId   int
+	FunctionName string
+	FilePath     string
+	LineStart    int
+	LineEnd      int
+	Comment      string
+	Consistent   bool
+	Severity     string
+	Explanation  string
+}
+
+// DocDriftRule is the rule id of documentation drift findings
+var DocDriftRule = findings.Rule{Id: "doc-drift", Name: "DocumentationDrift",
+	Description: "The leading comment of a function no longer matches its implementation."}
+
+// Finding converts a drift result to the common finding model.
+func (d DocDrift) Finding() findings.Finding {
+	message := d.Explanation
+	if message == "" {
+		message = "Comment of " + d.FunctionName + " does not match its implementation."
+	}
+	return findings.Finding{RuleId: DocDriftRule.Id, FilePath: d.FilePath, LineStart: d.LineStart, LineEnd: d.LineEnd,
+		Function: d.FunctionName, Severity: findings.ParseSeverity(d.Severity, findings.SeverityMedium), Message: message}
+}
+
+// AnalyzeDocumentationDrift compares the leading comment of every indexed function against its body.
+//
+// filePath limits the pass to a single file, all indexed files are checked if it is empty.
+// Functions without a leading comment are skipped. Results replace previous rows in doc_drift.
+func AnalyzeDocumentationDrift(filePath string) error {
+	files, err := index.ListFiles()
+	if err != nil {
+		return err
+	}
+
+	// rows of functions removed by an older version, which did not remove their drift rows
+	db.GetDatabase().Execute(`DELETE FROM doc_drift WHERE function_id NOT IN (SELECT id FROM functions)`)
+
+	for _, f := range files {
+		if filePath != "" && f.FilePath != filePath {
+			continue
+		}
+		l, ok := lang.ForFile(f.FilePath)
+		if !ok || !policy.AllowsSource(f.FilePath) {
+			continue
+		}
+		lines, err := fileutil.ReadFileLines(f.FilePath)
+		if err != nil {
+			log.Printf("Failed to read %s: %v", f.FilePath, err)
+			continue
+		}
+		functions, err := index.FunctionsByFile(f.Id)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Checking documentation of %s\n", f.FilePath)
+		promptVersion := llm_prompt.Version(l.Name, config.AppConfig.Ollama.ChatModel, "check_documentation_drift", "check_documentation_drift_final")
+		for _, fn := range functions {
+			declIdx := docgen.FindDeclaration(lines, fn, l)
+			if declIdx < 0 {
+				log.Printf("Declaration of %s not found near line %d, skipping", fn.FunctionName, fn.LineStart)
+				continue
+			}
+			// the body moved along with the declaration
+			shift := declIdx - (fn.LineStart - 1)
+			fn.LineStart += shift
+			fn.LineEnd += shift
+
+			start, end, documented := l.LeadingComment(lines, declIdx)
+			if !documented {
+				continue
+			}
+			comment := l.Comment(lines, start, end)
+
+			res, err := CheckDocumentationDrift(fn, l.Name, comment, lines)
+			if err != nil {
+				log.Printf("Failed to check documentation of %s: %v", fn.FunctionName, err)
+				continue
+			}
+
+			db.GetDatabase().Execute(`DELETE FROM doc_drift WHERE function_id = ?`, fn.Id)
+			db.GetDatabase().Execute(`INSERT INTO doc_drift (function_id, file_id, comment, consistent, severity, explanation, checked_datetime, prompt_version) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				fn.Id, fn.FileId, comment, res.Consistent, res.Severity, res.Explanation, time.Now(), promptVersion)
+		}
+	}
+	return nil
+}
+
+// CheckDocumentationDrift asks the chat model whether comment still describes the function body.
+func CheckDocumentationDrift(fn index.Function, language string, comment string, lines []string) (*llm_prompt.DocDriftResponse, error) {
+
+	lineStart := fn.LineStart - 1
+	if lineStart < 0 {
+		lineStart = 0
+	}
+	lineEnd := fn.LineEnd
+	if lineEnd > len(lines) || lineEnd < lineStart {
+		lineEnd = len(lines)
+	}
+
+	chatReq := http_client.NewChatRequest()
+	chatReq.SourcePaths = []string{fn.FilePath}
+	chatReq.Trace = http_client.Trace{FunctionName: fn.FunctionName, Step: "CheckDocumentationDrift"}
+
+	{
+		prompt := llm_prompt.CheckDocumentationDrift(fn.FunctionName, language, comment, lines, lineStart, lineEnd)
+		fmt.Printf("CheckDocumentationDrift\n%s\n\n", prompt) //DEBUG
+
+		chatReq.Messages = append(chatReq.Messages, http_client.Chat{Role: "user", Content: prompt})
+
+		resp, err := http_client.ChatGenerateRemote(chatReq)
+		if err != nil {
+			return nil, fmt.Errorf("error calling ChatGenerateRemote: %v", err)
+		}
+		fmt.Println("Role:", resp.Result.Role)
+		fmt.Println("Content:", resp.Result.Content)
+
+		chatReq.Messages = append(chatReq.Messages, resp.Result)
+	}
+
+	{
+		prompt := llm_prompt.CheckDocumentationDriftFinal(fn.FunctionName, language)
+		fmt.Printf("CheckDocumentationDriftFinal\n%s\n\n", prompt) //DEBUG
+		chatReq.Trace.Step = "CheckDocumentationDriftFinal"
+
+		chatReq.Messages = append(chatReq.Messages, http_client.Chat{Role: "user", Content: prompt})
+
+		resp, err := http_client.ChatGenerateRemote(chatReq)
+		if err != nil {
+			return nil, fmt.Errorf("error calling ChatGenerateRemote: %v", err)
+		}
+		fmt.Println("Role:", resp.Result.Role)
+		fmt.Println("Content:", resp.Result.Content)
+
+		res, err := util.ParseJsonObject[llm_prompt.DocDriftResponse](resp.Result.Content)
+		if err != nil {
+			return nil, fmt.Errorf("error CheckDocumentationDriftFinal ParseJsonObject: %v", err)
+		}
+		res.Severity = normalizeSeverity(res.Severity, res.Consistent)
+		return &res, nil
+	}
+}
+
+func normalizeSeverity(severity string, consistent bool) string {
+	severity = strings.ToLower(strings.TrimSpace(severity))
+	switch severity {
+	case "none", "low", "medium", "high":
+	default:
+		severity = "medium"
+	}
+	if consistent {
+		return "none"
+	}
+	if severity == "none" {
+		return "low"
+	}
+	return severity
+}
+
+// ListDocumentationDrift returns the stored drift results ordered by file and line.
+//
+// If onlyMismatches is set, consistent comments are left out.
+func ListDocumentationDrift(filePath string, onlyMismatches bool) ([]DocDrift, error) {
+	query := `SELECT a.function_id, b.function_name, c.file_path, b.line_start, b.line_end, a.comment, a.consistent, a.severity, a.explanation
+		FROM doc_drift a JOIN functions b ON a.function_id = b.id JOIN files c ON a.file_id = c.id
+		WHERE (? = '' OR c.file_path = ?) AND (? = 0 OR a.consistent = 0)
+		ORDER BY c.file_path, b.line_start`
+	rows, err := db.GetDatabase().Query(query, filePath, filePath, onlyMismatches)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []DocDrift
+	for rows.Next() {
+		var d DocDrift
+		if err := rows.Scan(&d.FunctionId, &d.FunctionName, &d.FilePath, &d.LineStart, &d.LineEnd, &d.Comment, &d.Consistent, &d.Severity, &d.Explanation); err != nil {
+			return nil, err
+		}
+		results = append(results, d)
+	}
+	return results, rows.Err()
+}
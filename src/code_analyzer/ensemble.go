@@ -0,0 +1,155 @@
+package code_analyzer
+
+import (
+	"code_assistant/src/config"
+	"code_assistant/src/db"
+	"code_assistant/src/http_client"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Steps whose answers are voted on in ensemble mode
+const (
+	StepFunctionList   = "function_list"
+	StepLocateFunction = "locate_function"
+)
+
+// ensembleRun is one run of a step, with its model and seed
+type ensembleRun struct {
+	Model string
+	Seed  int
+}
+
+// options returns the model options of the run, the default options for the single default run
+func (r ensembleRun) options() *http_client.Options {
+	if r.Seed == 0 {
+		return http_client.DefaultOptions()
+	}
+	return &http_client.Options{Seed: r.Seed}
+}
+
+// ensembleRuns returns the runs of a step whose model is defaultModel.
+//
+// Without ensemble mode there is a single run of defaultModel. Otherwise the configured number of runs,
+// at least one per configured model, is spread over the models round robin, each run with its own seed.
+// attempt counts the scans that analyze the same functions again because the runs did not agree,
+// every attempt gets new seeds, so its requests are neither answered from the cache nor repeat the same votes.
+func ensembleRuns(defaultModel string, attempt int) []ensembleRun {
+	var models []string
+	for _, m := range strings.Split(config.AppConfig.Ensemble.Models, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			models = append(models, m)
+		}
+	}
+	count := max(config.AppConfig.Ensemble.Runs, len(models))
+	if count <= 1 && len(models) == 0 {
+		return []ensembleRun{{Model: defaultModel}}
+	}
+	if len(models) == 0 {
+		models = []string{defaultModel}
+	}
+
+	runs := make([]ensembleRun, count)
+	for i := range runs {
+		runs[i] = ensembleRun{Model: models[i%len(models)], Seed: attempt*count + i + 1}
+	}
+	return runs
+}
+
+// ensembleVote is the answer of one run
+type ensembleVote struct {
+	Run    ensembleRun
+	Answer string
+}
+
+// majority returns the answer given by most runs, the earliest one on a tie, and the share of runs that gave it
+func majority(votes []ensembleVote) (string, float64) {
+	counts := map[string]int{}
+	best := ""
+	for _, v := range votes {
+		counts[v.Answer]++
+		if counts[v.Answer] > counts[best] {
+			best = v.Answer
+		}
+	}
+	if len(votes) == 0 {
+		return "", 0
+	}
+	return best, float64(counts[best]) / float64(len(votes))
+}
+
+// recordVotes stores the votes of an ensemble step, so that models can be compared by how often they agree with the majority.
+// Nothing is recorded for a single run.
+func (fa *FileAnalyzer) recordVotes(step string, functionName string, votes []ensembleVote, winner string) {
+	if len(votes) < 2 || fa.store != nil {
+		return
+	}
+	now := time.Now()
+	for _, v := range votes {
+		db.GetDatabase().Execute(`INSERT INTO ensemble_votes (file_path, function_name, step, model, seed, answer, agreed, created_datetime) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			fa.FilePath, functionName, step, v.Run.Model, v.Run.Seed, v.Answer, v.Answer == winner, now)
+	}
+}
+
+// ModelAgreement is how often the answers of a model agreed with the majority of an ensemble
+type ModelAgreement struct {
+	Model  string
+	Step   string
+	Votes  int
+	Agreed int
+}
+
+// ListModelAgreement returns the agreement of every model with the ensemble majority, per step
+func ListModelAgreement() ([]ModelAgreement, error) {
+	rows, err := db.GetDatabase().Query(`SELECT model, step, COUNT(*), SUM(agreed) FROM ensemble_votes GROUP BY model, step ORDER BY step, model`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []ModelAgreement
+	for rows.Next() {
+		var a ModelAgreement
+		if err := rows.Scan(&a.Model, &a.Step, &a.Votes, &a.Agreed); err != nil {
+			return nil, err
+		}
+		result = append(result, a)
+	}
+	return result, rows.Err()
+}
+
+// LowConfidenceFunction is a function whose ensemble runs disagreed
+type LowConfidenceFunction struct {
+	FilePath     string
+	FunctionName string
+	Confidence   float64
+}
+
+// ListLowConfidenceFunctions returns the functions with a confidence below threshold, least confident first
+func ListLowConfidenceFunctions(threshold float64) ([]LowConfidenceFunction, error) {
+	rows, err := db.GetDatabase().Query(`SELECT b.file_path, a.function_name, a.confidence FROM functions a JOIN files b ON a.file_id = b.id
+		WHERE a.confidence < ? ORDER BY a.confidence, b.file_path, a.function_name`, threshold)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []LowConfidenceFunction
+	for rows.Next() {
+		var f LowConfidenceFunction
+		if err := rows.Scan(&f.FilePath, &f.FunctionName, &f.Confidence); err != nil {
+			return nil, err
+		}
+		result = append(result, f)
+	}
+	return result, rows.Err()
+}
+
+// locateAnswer formats a verified line range as a vote
+func locateAnswer(valid bool, start int, end int) string {
+	if !valid {
+		return "invalid"
+	}
+	return fmt.Sprintf("%d-%d", start, end)
+}
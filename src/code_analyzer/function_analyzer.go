@@ -3,9 +3,8 @@ package code_analyzer
 import (
 	"code_assistant/src/http_client"
 	"code_assistant/src/llm_prompt"
-	"code_assistant/src/util"
+	"code_assistant/src/pipeline"
 	"fmt"
-	"log"
 )
 
 type Function struct {
@@ -13,143 +12,49 @@ type Function struct {
 	LineEnd   int
 }
 
-func IdentifyFunction(fa *FileAnalyzer, functionName string, language string) (bool, int, int) {
+// IdentifyFunction locates a function in the window of the analyzer and checks that it is entirely shown,
+// with the identify pipeline of the profile of the run's model
+func IdentifyFunction(fa *FileAnalyzer, functionName string, language string, run ensembleRun) (pipeline.Result, error) {
 
 	// Get a default TextGenRequest struct
 	chatReq := http_client.NewChatRequest()
-
-	startLine := 0
-	endLine := 0
-
-	// Locate Function Prompt
-	{
-		prompt := llm_prompt.LocateFunctionDefition(functionName, language, fa.CodeSnippet, fa.LineStart, fa.LineEnd)
-		fmt.Printf("LocateFunctionDefition\n%s\n\n", prompt) //DEBUG
-
-		chatReq.Messages = append(chatReq.Messages, http_client.Chat{Role: "user", Content: prompt})
-
-		// Call ChatGenerateRemote function
-		resp, err := http_client.ChatGenerateRemote(chatReq)
-		if err != nil {
-			log.Fatalf("Error calling ChatGenerateRemote: %v", err)
-		}
-		fmt.Println("Role:", resp.Result.Role)
-		fmt.Println("Content:", resp.Result.Content)
-
-		chatReq.Messages = append(chatReq.Messages, resp.Result) // append to the messages
-	}
-
-	// Locate Function Prompt
-	{
-		prompt := llm_prompt.LocateFunctionDefitionFinal(functionName, language, fa.CodeSnippet, fa.LineStart, fa.LineEnd)
-		fmt.Printf("LocateFunctionDefitionFinal\n%s\n\n", prompt) //DEBUG
-		chatReq.Messages = append(chatReq.Messages, http_client.Chat{Role: "user", Content: prompt})
-
-		// Call ChatGenerateRemote function
-		resp, err := http_client.ChatGenerateRemote(chatReq)
-		if err != nil {
-			log.Fatalf("Error calling ChatGenerateRemote: %v", err)
-		}
-
-		// Print response
-		fmt.Println("Role:", resp.Result.Role)
-		fmt.Println("Content:", resp.Result.Content)
-
-		// no need to append to the messages
-		chatReq.Messages = append(chatReq.Messages, resp.Result) // append to the messages
-
-		res, err := util.ParseJsonObject[llm_prompt.LocateFunctionResponse](resp.Result.Content)
-		if err != nil {
-			log.Fatalf("Error LocateFunctionDefitionFinal ParseJsonObject: %v", err)
-			return false, startLine, endLine
-		}
-		startLine = res.StartLine
-		endLine = res.EndLine
+	chatReq.SourcePaths = []string{fa.FilePath}
+	chatReq.Model = run.Model
+	chatReq.Options = run.options()
+	chatReq.Trace.ScanId = fa.scanId
+
+	res, err := pipeline.ProfileFor(run.Model).Identify.Run(fa.pipelineInput(functionName, language), chatReq)
+	if err != nil {
+		return res, fmt.Errorf("error identifying function %s: %v", functionName, err)
 	}
+	return res, nil
+}
 
-	// Check Function Defition Prompt
-	{
-		prompt := llm_prompt.CheckFunctionDefition(functionName, language, fa.CodeSnippet, fa.LineStart, fa.LineEnd)
-		fmt.Printf("CheckFunctionDefition\n%s\n\n", prompt) //DEBUG
-		chatReq.Messages = append(chatReq.Messages, http_client.Chat{Role: "user", Content: prompt})
-
-		// Call ChatGenerateRemote function
-		resp, err := http_client.ChatGenerateRemote(chatReq)
-		if err != nil {
-			log.Fatalf("Error calling ChatGenerateRemote: %v", err)
-		}
-
-		// Print response
-		fmt.Println("Role:", resp.Result.Role)
-		fmt.Println("Content:", resp.Result.Content)
-		chatReq.Messages = append(chatReq.Messages, resp.Result)
-	}
-
-	// Check Function Defition Prompt
-	{
-		prompt := llm_prompt.CheckFunctionDefitionFinal(functionName, language, fa.CodeSnippet, fa.LineStart, fa.LineEnd)
-		fmt.Printf("CheckFunctionDefitionFinal\n%s\n\n", prompt) //DEBUG
-		chatReq.Messages = append(chatReq.Messages, http_client.Chat{Role: "user", Content: prompt})
-
-		// Call ChatGenerateRemote function
-		resp, err := http_client.ChatGenerateRemote(chatReq)
-		if err != nil {
-			log.Fatalf("Error calling ChatGenerateRemote: %v", err)
-		}
+// AnalyzeFunction describes a function with the analyze pipeline of the profile of the model that identified it.
+// A profile without analyze steps keeps the analysis the identify pipeline returned.
+func AnalyzeFunction(fa *FileAnalyzer, language string, functionName string, located locatedFunction) (*llm_prompt.AnalyzeFunctionResponse, error) {
 
-		// Print response
-		fmt.Println("Role:", resp.Result.Role)
-		fmt.Println("Content:", resp.Result.Content)
-		res, err := util.ParseJsonObject[llm_prompt.BooleanItem](resp.Result.Content)
-		if err != nil {
-			log.Fatalf("Error CheckFunctionDefitionFinal ParseJsonObject: %v", err)
-			return false, startLine, endLine
-		}
-		return res.Result, startLine, endLine
+	analyze := pipeline.ProfileFor(located.Run.Model).Analyze
+	if len(analyze.Steps) == 0 {
+		return located.Answer.Analysis(), nil
 	}
-}
-
-func AnalyzeFunction(fa *FileAnalyzer, language string, functionName string, functionStartLine int, functionEndLine int) (*llm_prompt.AnalyzeFunctionResponse, error) {
 
 	// Get a default TextGenRequest struct
 	chatReq := http_client.NewChatRequest()
+	chatReq.SourcePaths = []string{fa.FilePath}
+	chatReq.Model = located.Run.Model
+	chatReq.Trace.ScanId = fa.scanId
 
-	{
-		prompt := llm_prompt.AnalyzeFunction(functionName, language, fa.CodeSnippet, fa.LineStart, fa.LineEnd)
-		fmt.Printf("AnalyzeFunction\n%s\n\n", prompt) //DEBUG
-
-		chatReq.Messages = append(chatReq.Messages, http_client.Chat{Role: "user", Content: prompt})
-
-		// Call ChatGenerateRemote function
-		resp, err := http_client.ChatGenerateRemote(chatReq)
-		if err != nil {
-			log.Fatalf("Error calling ChatGenerateRemote: %v", err)
-		}
-		fmt.Println("Role:", resp.Result.Role)
-		fmt.Println("Content:", resp.Result.Content)
-
-		chatReq.Messages = append(chatReq.Messages, resp.Result)
+	res, err := analyze.Run(fa.pipelineInput(functionName, language), chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("error analyzing function %s: %v", functionName, err)
 	}
+	info := res.Analysis()
+	info.PromptVersion = llm_prompt.CombineVersions(located.Answer.PromptVersion, res.PromptVersion)
+	return info, nil
+}
 
-	{
-		prompt := llm_prompt.AnalyzeFunctionFinal(functionName, language, fa.CodeSnippet, fa.LineStart, fa.LineEnd)
-		fmt.Printf("AnalyzeFunction\n%s\n\n", prompt) //DEBUG
-
-		chatReq.Messages = append(chatReq.Messages, http_client.Chat{Role: "user", Content: prompt})
-
-		// Call ChatGenerateRemote function
-		resp, err := http_client.ChatGenerateRemote(chatReq)
-		if err != nil {
-			log.Fatalf("Error calling ChatGenerateRemote: %v", err)
-		}
-		fmt.Println("Role:", resp.Result.Role)
-		fmt.Println("Content:", resp.Result.Content)
-
-		res, err := util.ParseJsonObject[llm_prompt.AnalyzeFunctionResponse](resp.Result.Content)
-		if err != nil {
-			log.Fatalf("Error AnalyzeFunctionFinal ParseJsonObject: %v", err)
-			return nil, fmt.Errorf("Error AnalyzeFunctionFinal ParseJsonObject: %v", err)
-		}
-		return &res, nil
-	}
+// pipelineInput returns what the prompts about a function in the window of the analyzer are built from
+func (fa *FileAnalyzer) pipelineInput(functionName string, language string) pipeline.Input {
+	return pipeline.Input{FunctionName: functionName, Language: language, Lines: fa.CodeSnippet, LineStart: fa.LineStart, LineEnd: fa.LineEnd}
 }
@@ -0,0 +1,113 @@
+package code_analyzer
+
+import (
+	"code_assistant/src/fileutil"
+	"code_assistant/src/gitutil"
+	"code_assistant/src/lang"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+)
+
+// AnalyzeGitDiff analyzes only the functions touched by the changes in rangeSpec.
+//
+// rangeSpec is anything "git diff" accepts, e.g. "main...HEAD" for a branch or a single commit
+// for everything changed since then. The working tree copy of each changed file is analyzed,
+// and only the windows overlapping a hunk are sent to the model. Files whose indexed copy is not
+// their content at the base of the range are scanned in full, the hunks do not cover what changed
+// since. When the new side of rangeSpec is a commit, the changed files must not differ from it in
+// the working tree, the hunk line numbers would not match them otherwise.
+func AnalyzeGitDiff(repoDir string, rangeSpec string) error {
+	files, err := gitutil.Diff(repoDir, rangeSpec)
+	if err != nil {
+		return err
+	}
+	root, err := gitutil.RepoRoot(repoDir)
+	if err != nil {
+		return err
+	}
+	if end, ok := gitutil.RangeEnd(rangeSpec); ok {
+		var modified []string
+		for _, f := range files {
+			if f.NewPath != "" && !gitutil.MatchesRevision(root, end, f.NewPath) {
+				modified = append(modified, f.NewPath)
+			}
+		}
+		if len(modified) > 0 {
+			return fmt.Errorf("the working tree differs from %s in %s, commit or stash the changes, or use --since to compare with the working tree",
+				end, strings.Join(modified, ", "))
+		}
+	}
+
+	base, err := gitutil.RangeBase(root, rangeSpec)
+	if err != nil {
+		return err
+	}
+
+	ig := fileutil.NewIgnorer(repoDir)
+	var errs []error
+	scanId := beginScan(ScanGitDiff, repoDir+" "+rangeSpec)
+	for _, f := range files {
+		if f.NewPath == "" {
+			// deleted in the range
+			if f.OldPath != "" {
+				removeFileFromDb(f.OldPath)
+			}
+			continue
+		}
+		if _, ok := lang.ForFile(f.NewPath); !ok {
+			continue
+		}
+		if ig.Skip(f.NewPath) {
+			fmt.Printf("Skipping ignored file %s\n", f.NewPath)
+			continue
+		}
+
+		// the hunks only tell what changed since the base, a file indexed at another version is scanned in full
+		_, indexedHash, indexErr := GetFileFromDb(f.NewPath)
+		indexedAtBase := indexErr == nil && f.OldPath != "" && indexedAt(root, base, f.OldPath, indexedHash)
+		fa, err := NewFunctionAnalyzer(f.NewPath)
+		if err != nil {
+			log.Printf("Skipping %s: %v", f.NewPath, err)
+			continue
+		}
+		if fa == nil {
+			// files excluded by policy or skipped at ingestion were removed from the index and reported
+			if _, hash, err := GetFileFromDb(f.NewPath); err == nil && hash != "" {
+				fmt.Printf("File %s already analyzed\n", f.NewPath)
+			}
+			continue
+		}
+		fa.scanId = scanId
+		if !indexedAtBase {
+			err = fa.ScanFile()
+		} else {
+			err = fa.ScanChangedLines(f.NewLineRanges())
+		}
+		if err != nil {
+			log.Printf("Failed to scan %s: %v", f.NewPath, err)
+			errs = append(errs, fmt.Errorf("%s: %v", f.NewPath, err))
+		}
+	}
+	finishScan(scanId)
+	return errors.Join(errs...)
+}
+
+// indexedAt reports whether the indexed copy of a file, identified by its hash, is its content at rev
+func indexedAt(root string, rev string, path string, indexedHash string) bool {
+	if indexedHash == "" {
+		return false
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	content, err := gitutil.ShowFile(root, rev, rel)
+	if err != nil {
+		return false
+	}
+	lines, err := fileutil.ReadLines(strings.NewReader(content))
+	return err == nil && FileContentHash(lines) == indexedHash
+}
@@ -0,0 +1,173 @@
+package code_analyzer
+
+import (
+	"code_assistant/src/chunker"
+	"code_assistant/src/config"
+	"code_assistant/src/db"
+	"code_assistant/src/diffutil"
+	"code_assistant/src/index"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// storedFunction is an analyzed function of the file as it was stored by a previous scan
+type storedFunction struct {
+	Id         int
+	Name       string
+	LineStart  int
+	LineEnd    int
+	BodySHA256 string
+	Confidence float64
+	Attempts   int
+}
+
+// FunctionBodyHash returns the SHA256 of the function body between the 1-based inclusive lines start and end.
+// Whitespace is normalized, so re-indenting or re-wrapping does not change the hash.
+func FunctionBodyHash(lines []string, start int, end int) string {
+	body := strings.Join(index.SliceLines(lines, start, end), "\n")
+	normalized := strings.Join(strings.Fields(body), " ")
+	hash := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(hash[:])
+}
+
+func getStoredFunctions(fileId int) ([]storedFunction, error) {
+	rows, err := db.GetDatabase().Query("SELECT id, function_name, line_start, line_end, body_sha256, confidence, attempts FROM functions WHERE file_id = ?", fileId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var functions []storedFunction
+	for rows.Next() {
+		var f storedFunction
+		if err := rows.Scan(&f.Id, &f.Name, &f.LineStart, &f.LineEnd, &f.BodySHA256, &f.Confidence, &f.Attempts); err != nil {
+			return nil, err
+		}
+		functions = append(functions, f)
+	}
+	return functions, rows.Err()
+}
+
+// relocateFunctions finds the functions of a previous scan whose body did not change.
+//
+// Every line mentioning the function name is tried as a new start line, with the previous length of
+// the function. If the body hash matches, the stored analysis is kept and only its line range is
+// updated. These functions are not sent to the model again, unless the runs of ensemble mode did not
+// agree on them: their lines are queued to be scanned again, with the seeds of their next attempt,
+// until the configured number of attempts is reached.
+func (fa *FileAnalyzer) relocateFunctions() {
+	fa.unchanged = map[string]bool{}
+	fa.analyzed = map[string]bool{}
+	fa.requeued = nil
+	fa.attempts = map[string]int{}
+	fa.attempt = 0
+
+	stored, err := getStoredFunctions(fa.FileId)
+	if err != nil {
+		fmt.Printf("Failed to load stored functions of %s: %v\n", fa.FilePath, err)
+		return
+	}
+
+	for _, f := range stored {
+		start, found := locateBody(fa.CodeSnippet, f.Name, f.LineStart, f.LineEnd, f.BodySHA256)
+		if !found {
+			continue
+		}
+		if start != f.LineStart {
+			db.GetDatabase().Execute("UPDATE functions SET line_start = ?, line_end = ? WHERE id = ?", start, start+f.LineEnd-f.LineStart, f.Id)
+		}
+		if f.Confidence < config.AppConfig.Ensemble.MinConfidence && f.Attempts < config.AppConfig.Ensemble.MaxAttempts {
+			fmt.Printf("Function %s has a confidence of %.2f, analyzing it again\n", f.Name, f.Confidence)
+			fa.requeued = append(fa.requeued, diffutil.LineRange{Start: start - 1, End: start - 1 + f.LineEnd - f.LineStart})
+			fa.attempts[f.Name] = f.Attempts + 1
+			fa.attempt = max(fa.attempt, f.Attempts+1)
+			continue
+		}
+		fa.unchanged[f.Name] = true
+		if start != f.LineStart {
+			fmt.Printf("Function %s unchanged, moved from line %d to %d\n", f.Name, f.LineStart, start)
+		}
+	}
+}
+
+// locateBody searches lines for a function body with the given hash and the length of the previous range.
+// It returns the new 1-based start line.
+func locateBody(lines []string, functionName string, lineStart int, lineEnd int, bodySHA256 string) (int, bool) {
+	if bodySHA256 == "" {
+		return 0, false
+	}
+	name := index.ShortName(functionName)
+	length := lineEnd - lineStart
+
+	// try the old position first, then every line mentioning the name
+	candidates := []int{lineStart}
+	for idx, line := range lines {
+		if idx+1 != lineStart && strings.Contains(line, name) {
+			candidates = append(candidates, idx+1)
+		}
+	}
+
+	for _, start := range candidates {
+		if start < 1 || start+length > len(lines) {
+			continue
+		}
+		if FunctionBodyHash(lines, start, start+length) == bodySHA256 {
+			return start, true
+		}
+	}
+	return 0, false
+}
+
+// updateRescanRequired flags the file for the next scan while it has functions the ensemble runs did not agree on
+// and that have attempts left
+func (fa *FileAnalyzer) updateRescanRequired() {
+	db.GetDatabase().Execute("UPDATE files SET rescan_required = (SELECT COUNT(*) > 0 FROM functions WHERE file_id = ? AND confidence < ? AND attempts < ?) WHERE id = ?",
+		fa.FileId, config.AppConfig.Ensemble.MinConfidence, config.AppConfig.Ensemble.MaxAttempts, fa.FileId)
+}
+
+// rescanRequired reports whether a file was flagged to be scanned again although its content did not change
+func rescanRequired(fileId int) bool {
+	var required bool
+	db.GetDatabase().QueryRow("SELECT rescan_required FROM files WHERE id = ?", fileId).Scan(&required)
+	return required
+}
+
+// hasUnhashedFunctions reports whether functions of the file were stored without a body hash, by older versions
+func (fa *FileAnalyzer) hasUnhashedFunctions() bool {
+	var count int
+	db.GetDatabase().QueryRow("SELECT COUNT(*) FROM functions WHERE file_id = ? AND body_sha256 = ''", fa.FileId).Scan(&count)
+	return count > 0
+}
+
+// removeStaleFunctions deletes functions of the file that were neither kept nor analyzed in this scan.
+// After a partial scan only the functions whose stored range overlaps a scanned window are candidates,
+// the others were not looked for.
+func (fa *FileAnalyzer) removeStaleFunctions() {
+	stored, err := getStoredFunctions(fa.FileId)
+	if err != nil {
+		return
+	}
+	for _, f := range stored {
+		if fa.unchanged[f.Name] || fa.analyzed[f.Name] {
+			continue
+		}
+		if fa.scanned != nil && !overlapsChunks(f.LineStart-1, f.LineEnd-1, fa.scanned) {
+			continue
+		}
+		fmt.Printf("Function %s no longer found, removing it\n", f.Name)
+		db.GetDatabase().Execute("DELETE FROM doc_drift WHERE function_id = ?", f.Id)
+		db.GetDatabase().Execute("DELETE FROM functions WHERE id = ?", f.Id)
+	}
+}
+
+// overlapsChunks reports whether the 0-based inclusive lines [start, end] overlap one of the chunks
+func overlapsChunks(start int, end int, chunks []chunker.Chunk) bool {
+	for _, c := range chunks {
+		if start < c.End && end >= c.Start {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,94 @@
+package code_analyzer
+
+import (
+	"code_assistant/src/db"
+	"code_assistant/src/http_client"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Kinds of scans
+const (
+	ScanDirectory = "directory"
+	ScanGitDiff   = "git"
+	ScanWatch     = "watch"
+)
+
+// ScanRecord is a scan as stored in the scans table
+type ScanRecord struct {
+	Id     int64
+	Kind   string
+	Target string
+	// Started and Finished are the wall clock of the scan, Finished is zero while it runs or if it was interrupted
+	Started  time.Time
+	Finished time.Time
+}
+
+// Duration returns how long the scan took, 0 if it did not finish
+func (s ScanRecord) Duration() time.Duration {
+	if s.Finished.IsZero() {
+		return 0
+	}
+	return s.Finished.Sub(s.Started)
+}
+
+// beginScan records the start of a scan and returns its id, the calls made for it are recorded with the id
+func beginScan(kind string, target string) int64 {
+	result, err := db.GetDatabase().Execute("INSERT INTO scans (kind, target, started_datetime) VALUES (?, ?, ?)", kind, target, time.Now())
+	if err != nil {
+		return 0
+	}
+	id, _ := result.LastInsertId()
+	return id
+}
+
+// finishScan records the end of a scan and prints what the model did for it
+func finishScan(id int64) {
+	if id == 0 {
+		return
+	}
+	db.GetDatabase().Execute("UPDATE scans SET finished_datetime = ? WHERE id = ?", time.Now(), id)
+
+	totals, err := http_client.ListUsage("", http_client.UsageFilter{ScanId: id})
+	if err != nil || len(totals) == 0 || totals[0].Calls == 0 {
+		return
+	}
+	t := totals[0]
+	fmt.Printf("Scan %d: %d calls, %d prompt and %d completion tokens, %s waiting, %.1f tokens/s\n",
+		id, t.Calls, t.PromptTokens, t.CompletionTokens, t.Wall.Round(time.Millisecond), t.TokensPerSecond())
+}
+
+// ListScans returns the most recent scans, limit 0 returns all of them
+func ListScans(limit int) ([]ScanRecord, error) {
+	query := "SELECT id, kind, target, started_datetime, finished_datetime FROM scans ORDER BY id DESC"
+	var args []any
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := db.GetDatabase().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var scans []ScanRecord
+	for rows.Next() {
+		var s ScanRecord
+		var finished sql.NullTime
+		if err := rows.Scan(&s.Id, &s.Kind, &s.Target, &s.Started, &finished); err != nil {
+			return nil, err
+		}
+		s.Finished = finished.Time
+		scans = append(scans, s)
+	}
+	return scans, rows.Err()
+}
+
+// LastScanId returns the id of the most recent scan, 0 if there was none
+func LastScanId() int64 {
+	var id int64
+	db.GetDatabase().QueryRow("SELECT COALESCE(MAX(id), 0) FROM scans").Scan(&id)
+	return id
+}
@@ -0,0 +1,372 @@
+package code_analyzer
+
+import (
+	"code_assistant/src/config"
+	"code_assistant/src/db"
+	"code_assistant/src/fileutil"
+	"code_assistant/src/findings"
+	"code_assistant/src/gitutil"
+	"code_assistant/src/http_client"
+	"code_assistant/src/index"
+	"code_assistant/src/lang"
+	"code_assistant/src/llm_prompt"
+	"code_assistant/src/policy"
+	"code_assistant/src/util"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// suppressMarker disables audit findings on the same or the following line, optionally limited to
+// the rules listed after it, e.g. "// code-assistant:ignore sql-injection"
+const suppressMarker = "code-assistant:ignore"
+
+const securityRulePrefix = "security/"
+
+// SecurityRules describes the rule ids of the audit pass
+var SecurityRules = []findings.Rule{
+	{Id: securityRulePrefix + lang.CheckInjection, Name: "Injection", Description: "Untrusted input reaches a shell, an interpreter or a template."},
+	{Id: securityRulePrefix + lang.CheckSQLInjection, Name: "SQLInjection", Description: "SQL is built from strings instead of bound parameters."},
+	{Id: securityRulePrefix + lang.CheckPathTraversal, Name: "PathTraversal", Description: "A file path built from input can escape its base directory."},
+	{Id: securityRulePrefix + lang.CheckDeserialization, Name: "UnsafeDeserialization", Description: "Untrusted data is deserialized into arbitrary types."},
+	{Id: securityRulePrefix + lang.CheckSecrets, Name: "HardcodedSecret", Description: "A credential or key is written in the source."},
+	{Id: securityRulePrefix + lang.CheckWeakCrypto, Name: "WeakCrypto", Description: "A broken algorithm or predictable randomness is used for security."},
+	{Id: securityRulePrefix + "other", Name: "OtherSecurityIssue", Description: "Other security issue."},
+}
+
+// SecurityFinding is a row of the audit_findings table
+type SecurityFinding struct {
+	Id           int
+	Fingerprint  string
+	RuleId       string
+	FilePath     string
+	FunctionName string
+	LineStart    int
+	LineEnd      int
+	Severity     string
+	Confidence   float64
+	Message      string
+	Suggestion   string
+	Suppressed   bool
+	FirstSeen    string
+	LastSeen     string
+	// PromptVersion is the version of the audit prompts that reported the finding
+	PromptVersion string
+}
+
+// Finding converts an audit result to the common finding model.
+func (s SecurityFinding) Finding() findings.Finding {
+	f := findings.Finding{RuleId: s.RuleId, FilePath: s.FilePath, LineStart: s.LineStart, LineEnd: s.LineEnd, Function: s.FunctionName,
+		Severity: findings.ParseSeverity(s.Severity, findings.SeverityMedium), Message: s.Message, Suggestion: s.Suggestion,
+		Confidence: s.Confidence, Fingerprint: s.Fingerprint}
+	if s.Suppressed {
+		f.Suppression = findings.SuppressionInSource
+	}
+	return f
+}
+
+// AuditSecurity asks the chat model to check every indexed function for security smells.
+//
+// filePath limits the pass to a single file, all indexed files are audited if it is empty.
+// Findings are matched with earlier runs by fingerprint, so a finding keeps its first seen date
+// while its function does not change. Findings of a function that are not reported again are removed.
+func AuditSecurity(filePath string) error {
+	files, err := index.ListFiles()
+	if err != nil {
+		return err
+	}
+
+	for _, f := range files {
+		if filePath != "" && f.FilePath != filePath {
+			continue
+		}
+		l, ok := lang.ForFile(f.FilePath)
+		if !ok || !policy.AllowsSource(f.FilePath) {
+			continue
+		}
+		lines, err := fileutil.ReadFileLines(f.FilePath)
+		if err != nil {
+			log.Printf("Failed to read %s: %v", f.FilePath, err)
+			continue
+		}
+		functions, err := index.FunctionsByFile(f.Id)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Auditing %s\n", f.FilePath)
+		fingerprintPath := repoRelativePath(f.FilePath)
+		promptVersion := llm_prompt.Version(l.Name, config.AppConfig.Ollama.ChatModel, "security_audit", "security_audit_final")
+		current := map[string]bool{}
+		for _, fn := range functions {
+			current[fn.FunctionName] = true
+
+			items, err := AuditFunction(fn, l, lines)
+			if err != nil {
+				log.Printf("Failed to audit %s: %v", fn.FunctionName, err)
+				continue
+			}
+
+			seen := map[string]bool{}
+			for _, item := range items {
+				finding := securityFinding(fn, l, lines, fingerprintPath, item)
+				if finding == nil || seen[finding.Fingerprint] {
+					continue
+				}
+				seen[finding.Fingerprint] = true
+				finding.PromptVersion = promptVersion
+				storeSecurityFinding(f.Id, finding)
+			}
+			removeSecurityFindings(f.Id, fn.FunctionName, seen)
+		}
+
+		// drop findings of functions that were removed from the file
+		stored, err := ListSecurityFindings(f.FilePath, true, 0)
+		if err != nil {
+			return err
+		}
+		for _, s := range stored {
+			if !current[s.FunctionName] {
+				db.GetDatabase().Execute(`DELETE FROM audit_findings WHERE id = ?`, s.Id)
+			}
+		}
+	}
+	return nil
+}
+
+// AuditFunction asks the chat model for the security problems of a single function.
+func AuditFunction(fn index.Function, l lang.Language, lines []string) ([]llm_prompt.SecurityFindingItem, error) {
+
+	lineStart := fn.LineStart - 1
+	if lineStart < 0 {
+		lineStart = 0
+	}
+	lineEnd := fn.LineEnd
+	if lineEnd > len(lines) || lineEnd < lineStart {
+		lineEnd = len(lines)
+	}
+
+	hints := l.SecurityHints()
+	var checks strings.Builder
+	for _, check := range lang.SecurityChecks {
+		fmt.Fprintf(&checks, "- %s: %s\n", check, hints[check])
+	}
+
+	chatReq := http_client.NewChatRequest()
+	chatReq.SourcePaths = []string{fn.FilePath}
+	chatReq.Trace = http_client.Trace{FunctionName: fn.FunctionName, Step: "SecurityAudit"}
+
+	{
+		prompt := llm_prompt.SecurityAudit(fn.FunctionName, l.Name, strings.TrimRight(checks.String(), "\n"), lines, lineStart, lineEnd)
+		fmt.Printf("SecurityAudit\n%s\n\n", prompt) //DEBUG
+
+		chatReq.Messages = append(chatReq.Messages, http_client.Chat{Role: "user", Content: prompt})
+
+		resp, err := http_client.ChatGenerateRemote(chatReq)
+		if err != nil {
+			return nil, fmt.Errorf("error calling ChatGenerateRemote: %v", err)
+		}
+		fmt.Println("Role:", resp.Result.Role)
+		fmt.Println("Content:", resp.Result.Content)
+
+		chatReq.Messages = append(chatReq.Messages, resp.Result)
+	}
+
+	prompt := llm_prompt.SecurityAuditFinal(fn.FunctionName, l.Name)
+	fmt.Printf("SecurityAuditFinal\n%s\n\n", prompt) //DEBUG
+	chatReq.Trace.Step = "SecurityAuditFinal"
+
+	chatReq.Messages = append(chatReq.Messages, http_client.Chat{Role: "user", Content: prompt})
+
+	resp, err := http_client.ChatGenerateRemote(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("error calling ChatGenerateRemote: %v", err)
+	}
+	fmt.Println("Role:", resp.Result.Role)
+	fmt.Println("Content:", resp.Result.Content)
+
+	items, err := util.ParseJsonArray[llm_prompt.SecurityFindingItem](resp.Result.Content)
+	if err != nil {
+		return nil, fmt.Errorf("error SecurityAuditFinal ParseJsonArray: %v", err)
+	}
+	return items, nil
+}
+
+// securityFinding validates a reported problem and derives its rule id, location and fingerprint
+func securityFinding(fn index.Function, l lang.Language, lines []string, fingerprintPath string, item llm_prompt.SecurityFindingItem) *SecurityFinding {
+	if strings.TrimSpace(item.Message) == "" {
+		return nil
+	}
+	check := normalizeCheck(item.Rule)
+
+	line := item.Line
+	if line < fn.LineStart || line > fn.LineEnd {
+		// the model lost track of the numbering, report the declaration
+		line = fn.LineStart
+	}
+	lineText := ""
+	if line >= 1 && line <= len(lines) {
+		lineText = strings.Join(strings.Fields(lines[line-1]), " ")
+	}
+
+	confidence := item.Confidence
+	if confidence > 1 && confidence <= 100 {
+		// percentages
+		confidence /= 100
+	}
+	confidence = min(max(confidence, 0), 1)
+
+	severity := findings.ParseSeverity(strings.ToLower(strings.TrimSpace(item.Severity)), findings.SeverityMedium)
+	if severity == findings.SeverityNone {
+		severity = findings.SeverityLow
+	}
+
+	return &SecurityFinding{
+		Fingerprint:  SecurityFingerprint(securityRulePrefix+check, fingerprintPath, fn.FunctionName, lineText),
+		RuleId:       securityRulePrefix + check,
+		FilePath:     fn.FilePath,
+		FunctionName: fn.FunctionName,
+		LineStart:    line,
+		LineEnd:      line,
+		Severity:     string(severity),
+		Confidence:   confidence,
+		Message:      item.Message,
+		Suggestion:   item.Suggestion,
+		Suppressed:   isSuppressed(lines, line, check) || isSuppressed(lines, fn.LineStart, check),
+	}
+}
+
+// normalizeCheck maps the rule name reported by the model to one of lang.SecurityChecks
+func normalizeCheck(rule string) string {
+	rule = strings.ToLower(strings.TrimSpace(rule))
+	rule = strings.TrimPrefix(rule, securityRulePrefix)
+	rule = strings.NewReplacer(" ", "-", "_", "-").Replace(rule)
+	for _, check := range lang.SecurityChecks {
+		if rule == check {
+			return check
+		}
+	}
+	return "other"
+}
+
+// isSuppressed reports whether the line or the line above it carries a suppress marker for check.
+// line is 1-based.
+func isSuppressed(lines []string, line int, check string) bool {
+	for _, idx := range []int{line - 1, line - 2} {
+		if idx < 0 || idx >= len(lines) {
+			continue
+		}
+		pos := strings.Index(lines[idx], suppressMarker)
+		if pos < 0 {
+			continue
+		}
+		rules := strings.FieldsFunc(lines[idx][pos+len(suppressMarker):], func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ','
+		})
+		// ignore trailing block comment ends and the like
+		var named []string
+		for _, r := range rules {
+			r = strings.TrimPrefix(strings.ToLower(r), securityRulePrefix)
+			if strings.Trim(r, "*/-#>") != "" {
+				named = append(named, r)
+			}
+		}
+		if len(named) == 0 {
+			return true
+		}
+		for _, r := range named {
+			if r == check {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// SecurityFingerprint identifies a finding by its rule, file, function and the normalized text of the
+// flagged line, so it survives line moves and whitespace changes.
+func SecurityFingerprint(ruleId string, filePath string, functionName string, lineText string) string {
+	hash := sha256.New()
+	for _, part := range []string{ruleId, filePath, functionName, lineText} {
+		hash.Write([]byte(part))
+		hash.Write([]byte{0})
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:32]
+}
+
+// repoRelativePath returns path relative to its repository root, so fingerprints do not depend on
+// where the repository is checked out
+func repoRelativePath(path string) string {
+	root, err := gitutil.RepoRoot(filepath.Dir(path))
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+func storeSecurityFinding(fileId int, s *SecurityFinding) {
+	now := time.Now()
+	db.GetDatabase().Execute(`INSERT INTO audit_findings (fingerprint, rule_id, file_id, function_name, line_start, line_end, severity, confidence, message, suggestion, suppressed, first_seen_datetime, last_seen_datetime, prompt_version)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(fingerprint) DO UPDATE SET file_id = excluded.file_id, line_start = excluded.line_start, line_end = excluded.line_end,
+			severity = excluded.severity, confidence = excluded.confidence, message = excluded.message, suggestion = excluded.suggestion,
+			suppressed = excluded.suppressed, last_seen_datetime = excluded.last_seen_datetime, prompt_version = excluded.prompt_version`,
+		s.Fingerprint, s.RuleId, fileId, s.FunctionName, s.LineStart, s.LineEnd, s.Severity, s.Confidence, s.Message, s.Suggestion, s.Suppressed, now, now, s.PromptVersion)
+}
+
+// removeSecurityFindings deletes the stored findings of a function that were not reported again
+func removeSecurityFindings(fileId int, functionName string, keep map[string]bool) {
+	rows, err := db.GetDatabase().Query(`SELECT fingerprint FROM audit_findings WHERE file_id = ? AND function_name = ?`, fileId, functionName)
+	if err != nil {
+		log.Printf("Failed to load audit findings of %s: %v", functionName, err)
+		return
+	}
+	var stale []string
+	for rows.Next() {
+		var fingerprint string
+		if err := rows.Scan(&fingerprint); err == nil && !keep[fingerprint] {
+			stale = append(stale, fingerprint)
+		}
+	}
+	rows.Close()
+
+	for _, fingerprint := range stale {
+		db.GetDatabase().Execute(`DELETE FROM audit_findings WHERE fingerprint = ?`, fingerprint)
+	}
+}
+
+// ListSecurityFindings returns the stored audit findings ordered by file and line.
+//
+// Inline suppressed findings are left out unless includeSuppressed is set.
+func ListSecurityFindings(filePath string, includeSuppressed bool, minConfidence float64) ([]SecurityFinding, error) {
+	query := `SELECT a.id, a.fingerprint, a.rule_id, b.file_path, a.function_name, a.line_start, a.line_end, a.severity, a.confidence,
+		a.message, a.suggestion, a.suppressed, a.first_seen_datetime, a.last_seen_datetime, a.prompt_version
+		FROM audit_findings a JOIN files b ON a.file_id = b.id
+		WHERE (? = '' OR b.file_path = ?) AND (? = 1 OR a.suppressed = 0) AND a.confidence >= ?
+		ORDER BY b.file_path, a.line_start`
+	rows, err := db.GetDatabase().Query(query, filePath, filePath, includeSuppressed, minConfidence)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []SecurityFinding
+	for rows.Next() {
+		var s SecurityFinding
+		err := rows.Scan(&s.Id, &s.Fingerprint, &s.RuleId, &s.FilePath, &s.FunctionName, &s.LineStart, &s.LineEnd, &s.Severity, &s.Confidence,
+			&s.Message, &s.Suggestion, &s.Suppressed, &s.FirstSeen, &s.LastSeen, &s.PromptVersion)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, s)
+	}
+	return results, rows.Err()
+}
@@ -0,0 +1,47 @@
+package code_analyzer
+
+import (
+	"code_assistant/src/db"
+	"code_assistant/src/fileutil"
+	"fmt"
+	"time"
+)
+
+// SkippedFileRecord is a file that was not indexed, as stored in the skipped_files table
+type SkippedFileRecord struct {
+	fileutil.SkippedFile
+	SkippedDatetime string
+}
+
+// recordSkippedFile stores why a file was not indexed and drops what was indexed of it before
+func recordSkippedFile(s fileutil.SkippedFile) {
+	fmt.Printf("Skipping %s: %s, %s\n", s.Path, s.Reason, s.Detail)
+	db.GetDatabase().Execute(`INSERT INTO skipped_files (file_path, reason, detail, skipped_datetime) VALUES (?, ?, ?, ?)
+		ON CONFLICT(file_path) DO UPDATE SET reason = excluded.reason, detail = excluded.detail, skipped_datetime = excluded.skipped_datetime`,
+		s.Path, s.Reason, s.Detail, time.Now())
+	removeFileFromDb(s.Path)
+}
+
+// clearSkippedFile forgets that a file was skipped, once it can be read again
+func clearSkippedFile(path string) {
+	db.GetDatabase().Execute("DELETE FROM skipped_files WHERE file_path = ?", path)
+}
+
+// ListSkippedFiles returns the files that were not indexed, ordered by path
+func ListSkippedFiles() ([]SkippedFileRecord, error) {
+	rows, err := db.GetDatabase().Query("SELECT file_path, reason, detail, skipped_datetime FROM skipped_files ORDER BY file_path")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []SkippedFileRecord
+	for rows.Next() {
+		var r SkippedFileRecord
+		if err := rows.Scan(&r.Path, &r.Reason, &r.Detail, &r.SkippedDatetime); err != nil {
+			return nil, err
+		}
+		records = append(records, r)
+	}
+	return records, rows.Err()
+}
@@ -0,0 +1,314 @@
+package code_analyzer
+
+import (
+	"code_assistant/src/config"
+	"code_assistant/src/db"
+	"code_assistant/src/fileutil"
+	"code_assistant/src/gitutil"
+	"code_assistant/src/http_client"
+	"code_assistant/src/index"
+	"code_assistant/src/lang"
+	"code_assistant/src/llm_prompt"
+	"code_assistant/src/policy"
+	"code_assistant/src/util"
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Snapshot is an indexed git revision
+type Snapshot struct {
+	Id             int
+	Revision       string
+	CommitSHA      string
+	CommitDatetime string
+	RepoRoot       string
+	FileCount      int
+	Complete       bool
+}
+
+// FunctionVersion is a function as it was analyzed in a snapshot
+type FunctionVersion struct {
+	Snapshot
+	FilePath    string
+	Signature   string
+	Arguments   string
+	Return      string
+	Description string
+	LineStart   int
+	LineEnd     int
+	BodySHA256  string
+}
+
+// SnapshotRevision indexes the tree of a git revision as a snapshot and returns its id.
+//
+// Files are read from the repository with "git show", the working tree is not touched.
+// Analyses are shared by content hash: a file already analyzed in another snapshot is not sent
+// to the model again, and functions whose body did not change since the previous version of the
+// file keep their analysis. A snapshot is marked complete once every file was indexed, indexing
+// the revision again retries the files that failed.
+func SnapshotRevision(repoDir string, rev string) (int, error) {
+	root, err := gitutil.RepoRoot(repoDir)
+	if err != nil {
+		return 0, err
+	}
+	commit, err := gitutil.ResolveCommit(root, rev)
+	if err != nil {
+		return 0, err
+	}
+
+	var snapshotId int
+	var complete bool
+	if err := db.GetDatabase().QueryRow("SELECT id, complete FROM snapshots WHERE commit_sha = ?", commit).Scan(&snapshotId, &complete); err == nil {
+		if complete {
+			fmt.Printf("Revision %s is already indexed as snapshot %d\n", rev, snapshotId)
+			return snapshotId, nil
+		}
+		fmt.Printf("Completing snapshot %d of revision %s\n", snapshotId, rev)
+	}
+
+	commitDate, err := gitutil.CommitDate(root, commit)
+	if err != nil {
+		return 0, err
+	}
+	files, err := gitutil.ListFiles(root, commit)
+	if err != nil {
+		return 0, err
+	}
+
+	if snapshotId == 0 {
+		result, err := db.GetDatabase().Execute("INSERT INTO snapshots (revision, commit_sha, commit_datetime, repo_root, created_datetime, complete) VALUES (?, ?, ?, ?, ?, 0)",
+			rev, commit, commitDate, root, time.Now())
+		if err != nil {
+			return 0, err
+		}
+		id, _ := result.LastInsertId()
+		snapshotId = int(id)
+	}
+
+	// a file that fails is not recorded as analyzed, so it is analyzed again when another snapshot contains it
+	var errs []error
+	ig := fileutil.NewIgnorer(root)
+	for _, path := range files {
+		if _, ok := lang.ForFile(path); !ok {
+			continue
+		}
+		if policy.ModeFor(filepath.Join(root, path)) == policy.ModeExclude || ig.Ignored(filepath.Join(root, path), false) {
+			continue
+		}
+		content, err := gitutil.ShowFile(root, commit, path)
+		if err != nil {
+			log.Printf("Failed to read %s: %v", path, err)
+			errs = append(errs, fmt.Errorf("%s: %v", path, err))
+			continue
+		}
+		if skipped := fileutil.CheckContent(path, []byte(content)); skipped != nil {
+			fmt.Printf("Skipping %s: %s, %s\n", path, skipped.Reason, skipped.Detail)
+			continue
+		}
+		lines, err := fileutil.ReadLines(strings.NewReader(content))
+		if err != nil {
+			log.Printf("Failed to read %s: %v", path, err)
+			errs = append(errs, fmt.Errorf("%s: %v", path, err))
+			continue
+		}
+		if config.AppConfig.Scan.SkipGenerated && fileutil.IsGeneratedContent(lines) {
+			continue
+		}
+		hash := FileContentHash(lines)
+
+		db.GetDatabase().Execute("INSERT OR REPLACE INTO snapshot_files (snapshot_id, file_path, sha256) VALUES (?, ?, ?)", snapshotId, path, hash)
+
+		var analyzed int
+		db.GetDatabase().QueryRow("SELECT COUNT(*) FROM file_analyses WHERE sha256 = ?", hash).Scan(&analyzed)
+		if analyzed > 0 {
+			fmt.Printf("Reusing analysis of %s\n", path)
+			continue
+		}
+		if err := analyzeSnapshotFile(root, path, lines, hash); err != nil {
+			log.Printf("Failed to analyze %s: %v", path, err)
+			errs = append(errs, fmt.Errorf("%s: %v", path, err))
+		}
+	}
+	if len(errs) > 0 {
+		return snapshotId, errors.Join(errs...)
+	}
+	_, err = db.GetDatabase().Execute("UPDATE snapshots SET complete = 1 WHERE id = ?", snapshotId)
+	return snapshotId, err
+}
+
+// analyzeSnapshotFile analyzes a file version and stores its functions under its content hash
+func analyzeSnapshotFile(root string, path string, lines []string, hash string) error {
+	fa := &FileAnalyzer{FilePath: filepath.Join(root, path), CodeSnippet: lines, StepSize: 100, SHA256: hash,
+		MetadataOnly: policy.ModeFor(filepath.Join(root, path)) == policy.ModeMetadata, unchanged: map[string]bool{}, analyzed: map[string]bool{}}
+	fa.store = func(functionName string, functionInfo *llm_prompt.AnalyzeFunctionResponse, startLine int, endLine int) {
+		insertSnapshotFunction(hash, functionName, functionInfo, startLine, endLine, FunctionBodyHash(lines, startLine, endLine))
+	}
+
+	// carry over functions whose body did not change since the previous version of the file
+	previous, _ := previousSnapshotFunctions(path)
+	for _, f := range previous {
+		start, found := locateBody(lines, f.name, f.lineStart, f.lineEnd, f.bodySHA256)
+		if !found {
+			continue
+		}
+		fa.unchanged[f.name] = true
+		insertSnapshotFunction(hash, f.name, &f.info, start, start+f.lineEnd-f.lineStart, f.bodySHA256)
+	}
+
+	scanLock.Lock()
+	fmt.Printf("Scanning file %s\n", path)
+	var errs []error
+	if fa.MetadataOnly {
+		fa.ScanMetadata()
+	} else {
+		for _, c := range fa.Chunks() {
+			fa.setWindow(c)
+			if err := fa.ScanContent(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	scanLock.Unlock()
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	db.GetDatabase().Execute("INSERT OR REPLACE INTO file_analyses (sha256, analyzed_datetime) VALUES (?, ?)", hash, time.Now())
+	return nil
+}
+
+// snapshotFunction is a function stored for a file version
+type snapshotFunction struct {
+	name       string
+	info       llm_prompt.AnalyzeFunctionResponse
+	lineStart  int
+	lineEnd    int
+	bodySHA256 string
+}
+
+func insertSnapshotFunction(fileHash string, functionName string, functionInfo *llm_prompt.AnalyzeFunctionResponse, startLine int, endLine int, bodyHash string) {
+	db.GetDatabase().Execute(`DELETE FROM snapshot_functions WHERE file_sha256 = ? AND function_name = ?`, fileHash, functionName)
+	db.GetDatabase().Execute(`INSERT INTO snapshot_functions (file_sha256, function_name, signature, arguments, return, description, line_start, line_end, body_sha256, prompt_version) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		fileHash, functionName, functionInfo.Signature, functionInfo.Arguments, functionInfo.Return, functionInfo.Purpose, startLine, endLine, bodyHash, functionInfo.PromptVersion)
+}
+
+// previousSnapshotFunctions returns the functions of the most recently analyzed version of path
+func previousSnapshotFunctions(path string) ([]snapshotFunction, error) {
+	rows, err := db.GetDatabase().Query(`SELECT function_name, signature, arguments, return, description, line_start, line_end, body_sha256, prompt_version
+		FROM snapshot_functions WHERE file_sha256 = (
+			SELECT a.sha256 FROM snapshot_files a JOIN file_analyses b ON a.sha256 = b.sha256
+			WHERE a.file_path = ? ORDER BY b.analyzed_datetime DESC LIMIT 1)`, path)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var functions []snapshotFunction
+	for rows.Next() {
+		var f snapshotFunction
+		err := rows.Scan(&f.name, &f.info.Signature, &f.info.Arguments, &f.info.Return, &f.info.Purpose, &f.lineStart, &f.lineEnd, &f.bodySHA256, &f.info.PromptVersion)
+		if err != nil {
+			return nil, err
+		}
+		functions = append(functions, f)
+	}
+	return functions, rows.Err()
+}
+
+// ListSnapshots returns all snapshots ordered by commit date.
+func ListSnapshots() ([]Snapshot, error) {
+	rows, err := db.GetDatabase().Query(`SELECT a.id, a.revision, a.commit_sha, a.commit_datetime, a.repo_root, COUNT(b.file_path), a.complete
+		FROM snapshots a LEFT JOIN snapshot_files b ON a.id = b.snapshot_id GROUP BY a.id ORDER BY a.commit_datetime, a.id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var snapshots []Snapshot
+	for rows.Next() {
+		var s Snapshot
+		if err := rows.Scan(&s.Id, &s.Revision, &s.CommitSHA, &s.CommitDatetime, &s.RepoRoot, &s.FileCount, &s.Complete); err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	return snapshots, rows.Err()
+}
+
+// FunctionHistory returns every version of a function across all snapshots ordered by commit date.
+func FunctionHistory(functionName string) ([]FunctionVersion, error) {
+	rows, err := db.GetDatabase().Query(`SELECT a.id, a.revision, a.commit_sha, a.commit_datetime, a.repo_root, b.file_path,
+		c.signature, c.arguments, c.return, c.description, c.line_start, c.line_end, c.body_sha256
+		FROM snapshots a JOIN snapshot_files b ON a.id = b.snapshot_id JOIN snapshot_functions c ON b.sha256 = c.file_sha256
+		WHERE c.function_name = ? ORDER BY a.commit_datetime, a.id, b.file_path`, functionName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var versions []FunctionVersion
+	for rows.Next() {
+		var v FunctionVersion
+		err := rows.Scan(&v.Id, &v.Revision, &v.CommitSHA, &v.CommitDatetime, &v.RepoRoot, &v.FilePath,
+			&v.Signature, &v.Arguments, &v.Return, &v.Description, &v.LineStart, &v.LineEnd, &v.BodySHA256)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, v)
+	}
+	return versions, rows.Err()
+}
+
+// functionVersionSource reads the body of a function version from the repository
+func functionVersionSource(v FunctionVersion) (string, error) {
+	content, err := gitutil.ShowFile(v.RepoRoot, v.CommitSHA, v.FilePath)
+	if err != nil {
+		return "", err
+	}
+	lines, err := fileutil.ReadLines(strings.NewReader(content))
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(index.SliceLines(lines, v.LineStart, v.LineEnd), "\n"), nil
+}
+
+// SummarizeFunctionChange asks the chat model how a function changed between two versions.
+func SummarizeFunctionChange(functionName string, oldVersion FunctionVersion, newVersion FunctionVersion) (string, error) {
+	oldCode, err := functionVersionSource(oldVersion)
+	if err != nil {
+		return "", err
+	}
+	newCode, err := functionVersionSource(newVersion)
+	if err != nil {
+		return "", err
+	}
+
+	chatReq := http_client.NewChatRequest()
+	chatReq.SourcePaths = []string{filepath.Join(oldVersion.RepoRoot, oldVersion.FilePath), filepath.Join(newVersion.RepoRoot, newVersion.FilePath)}
+	chatReq.Trace = http_client.Trace{FilePath: chatReq.SourcePaths[1], FunctionName: functionName, Step: "SummarizeFunctionChange"}
+	prompt := llm_prompt.SummarizeFunctionChange(functionName, GetCodeLanguage(newVersion.FilePath),
+		shortSHA(oldVersion.CommitSHA), oldCode, shortSHA(newVersion.CommitSHA), newCode)
+	fmt.Printf("SummarizeFunctionChange\n%s\n\n", prompt) //DEBUG
+	chatReq.Messages = append(chatReq.Messages, http_client.Chat{Role: "user", Content: prompt})
+
+	resp, err := http_client.ChatGenerateRemote(chatReq)
+	if err != nil {
+		return "", fmt.Errorf("error calling ChatGenerateRemote: %v", err)
+	}
+	res, err := util.ParseJsonObject[llm_prompt.AnswerItem](resp.Result.Content)
+	if err != nil {
+		return resp.Result.Content, nil
+	}
+	return res.Answer, nil
+}
+
+func shortSHA(sha string) string {
+	if len(sha) > 10 {
+		return sha[:10]
+	}
+	return sha
+}
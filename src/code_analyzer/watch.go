@@ -0,0 +1,173 @@
+package code_analyzer
+
+import (
+	"code_assistant/src/db"
+	"code_assistant/src/diffutil"
+	"code_assistant/src/fileutil"
+	"code_assistant/src/lang"
+	"code_assistant/src/watcher"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// watchDebounce is how long a burst of saves is collected before files are re-analyzed
+const watchDebounce = 500 * time.Millisecond
+
+// DirectoryWatcher re-analyzes files of a directory as they change
+type DirectoryWatcher struct {
+	Directory string
+
+	w         watcher.Watcher
+	ignore    *fileutil.Ignorer
+	mu        sync.Mutex
+	snapshots map[string][]string
+	done      chan struct{}
+}
+
+// WatchDirectory starts watching directory in the background.
+//
+// The current content of every code file is kept in memory, so that on a change only
+// the windows overlapping the edited lines need to be sent to the model again. That is only done when
+// the kept content is what was indexed, a file edited before watching began is analyzed in full.
+func WatchDirectory(directory string) (*DirectoryWatcher, error) {
+	w, err := watcher.New(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	dw := &DirectoryWatcher{Directory: directory, w: w, ignore: fileutil.NewIgnorer(directory), snapshots: map[string][]string{}, done: make(chan struct{})}
+	codeFilePaths, _ := fileutil.ScanFiles(directory, lang.Extensions())
+	for _, path := range codeFilePaths {
+		if lines, err := fileutil.ReadFileLines(path); err == nil {
+			dw.snapshots[path] = lines
+		}
+	}
+
+	go dw.run()
+	return dw, nil
+}
+
+// Stop stops watching and waits for a running re-analysis to finish.
+func (dw *DirectoryWatcher) Stop() {
+	dw.w.Close()
+	<-dw.done
+}
+
+// FileCount returns the number of code files being tracked
+func (dw *DirectoryWatcher) FileCount() int {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+	return len(dw.snapshots)
+}
+
+func (dw *DirectoryWatcher) run() {
+	defer close(dw.done)
+
+	pending := map[string]bool{}
+	timer := time.NewTimer(watchDebounce)
+	timer.Stop()
+	errs := dw.w.Errors()
+
+	for {
+		select {
+		case path, ok := <-dw.w.Events():
+			if !ok {
+				return
+			}
+			if _, supported := lang.ForFile(path); !supported || dw.ignore.Skip(path) {
+				continue
+			}
+			pending[path] = true
+			timer.Reset(watchDebounce)
+
+		case err, ok := <-errs:
+			if !ok {
+				// a closed channel is always ready, stop selecting it
+				errs = nil
+				continue
+			}
+			log.Printf("[watch] %v", err)
+
+		case <-timer.C:
+			paths := make([]string, 0, len(pending))
+			for path := range pending {
+				paths = append(paths, path)
+			}
+			sort.Strings(paths)
+			pending = map[string]bool{}
+
+			for _, path := range paths {
+				dw.process(path)
+			}
+		}
+	}
+}
+
+// process re-analyzes a single touched file. A failure is logged, the watcher and the prompt keep running.
+func (dw *DirectoryWatcher) process(path string) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("[watch] %s failed: %v", path, r)
+		}
+	}()
+
+	if !fileutil.FileExists(path) {
+		dw.mu.Lock()
+		delete(dw.snapshots, path)
+		dw.mu.Unlock()
+		removeFileFromDb(path)
+		fmt.Printf("[watch] %s removed from index\n", path)
+		return
+	}
+
+	// read before NewFunctionAnalyzer adds a row for a new file
+	_, indexedHash, indexErr := GetFileFromDb(path)
+
+	fa, err := NewFunctionAnalyzer(path)
+	if err != nil {
+		log.Printf("[watch] %v", err)
+		return
+	}
+	if fa == nil {
+		fmt.Printf("[watch] %s unchanged\n", path)
+		return
+	}
+
+	dw.mu.Lock()
+	previous, known := dw.snapshots[path]
+	dw.snapshots[path] = fa.CodeSnippet
+	dw.mu.Unlock()
+
+	start := time.Now()
+	fa.scanId = beginScan(ScanWatch, path)
+	if !known || indexErr != nil || indexedHash != FileContentHash(previous) {
+		// only the changes to what was indexed could be analyzed, the index has a different version of the file or none
+		fmt.Printf("[watch] %s is new or was not indexed in its previous version, analyzing\n", path)
+		err = fa.ScanFile()
+	} else {
+		changed := diffutil.ChangedLines(previous, fa.CodeSnippet)
+		fmt.Printf("[watch] %s changed in %d places, analyzing\n", path, len(changed))
+		err = fa.ScanChangedLines(changed)
+	}
+	finishScan(fa.scanId)
+	if err != nil {
+		log.Printf("[watch] %s failed, it is retried on its next change: %v", path, err)
+		return
+	}
+	fmt.Printf("[watch] %s done in %s\n", path, time.Since(start).Round(time.Millisecond))
+}
+
+// removeFileFromDb drops a deleted file and its functions from the index
+func removeFileFromDb(path string) {
+	fileId, _, err := GetFileFromDb(path)
+	if err != nil {
+		return
+	}
+	db.GetDatabase().Execute("DELETE FROM doc_drift WHERE file_id = ?", fileId)
+	db.GetDatabase().Execute("DELETE FROM functions WHERE file_id = ?", fileId)
+	db.GetDatabase().Execute("DELETE FROM audit_findings WHERE file_id = ?", fileId)
+	db.GetDatabase().Execute("DELETE FROM files WHERE id = ?", fileId)
+}
@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -17,12 +18,93 @@ type Ollama struct {
 	TextGenModel   string
 	ChatModel      string
 	EmbeddingModel string
+
+	// Store every request and reply in the llm_calls table
+	RecordCalls bool
+	// Seed sent with every request so that answers are reproducible, 0 leaves it to the model
+	Seed int
+}
+
+type Cache struct {
+
+	// Answer identical requests from the llm_cache table
+	Enabled bool
+	// Ask the model even if an answer is cached, and cache the new answer
+	Bypass bool
+	// Cached answers older than this are not used, 0 keeps them forever
+	TTL time.Duration
+	// Least recently used answers are dropped once the cache is larger than this many bytes, 0 disables the limit
+	MaxBytes int64
+}
+
+type Redaction struct {
+
+	// Mask secrets and personal data before prompts leave the process
+	Enabled bool
+	// File with additional "kind: regexp" patterns
+	PatternsFile string
+}
+
+type Scan struct {
+
+	// Skip vendor, build output and other well known directories
+	DefaultExcludes bool
+	// Skip files marked as generated, e.g. "// Code generated ... DO NOT EDIT."
+	SkipGenerated bool
+	// Files above this size in bytes are not read, 0 disables the limit
+	MaxFileBytes int64
+	// Files with a longer line are not read, usually minified code. 0 disables the limit
+	MaxLineLength int
+	// Follow symbolic links to files and directories, loops are detected
+	FollowSymlinks bool
+}
+
+type Chunking struct {
+
+	// Context budgets as "model=tokens" pairs separated by commas, "default=tokens" applies to other models
+	ContextTokens string
+}
+
+type Ensemble struct {
+
+	// Number of runs of the function list and function location steps, 1 disables ensemble mode
+	Runs int
+	// Models the runs are spread over, comma separated. Empty runs the configured model with different seeds
+	Models string
+	// Functions with a lower agreement between the runs are analyzed again by the next scan
+	MinConfidence float64
+	// Number of times a function with a low agreement is analyzed again, then the answer with the best agreement is kept
+	MaxAttempts int
+}
+
+type Pipeline struct {
+
+	// Pipeline profile used for models without a profile of their own
+	Profile string
+	// Profiles of models as "model=profile" pairs separated by commas
+	ModelProfiles string
+	// JSON file defining additional profiles
+	File string
+}
+
+type Prompts struct {
+
+	// Directory with prompt templates overriding the built-in ones, per model and language in subdirectories
+	Dir string
 }
 
 type Config struct {
 
 	// Define base configuration variables here
 	Ollama     Ollama
+	Redaction  Redaction
+	PolicyFile string
+	Scan       Scan
+	Chunking   Chunking
+	Ensemble   Ensemble
+	Pipeline   Pipeline
+	Prompts    Prompts
+	Cache      Cache
 	DebugMode  bool
 	DbFilePath string
 
@@ -55,9 +137,32 @@ func LoadConfig() {
 	ollamaTextGenModel := flag.String("ollama_textgen_model", getEnv("OLLAMA_TEXTGEN_MODEL", "mistral:instruct"), "Ollama Text Generation Model")
 	ollamaChatModel := flag.String("ollama_chat_model", getEnv("OLLAMA_CHAT_MODEL", "mistral:instruct"), "Ollama Chat Model")
 	ollamaEmbeddingModel := flag.String("ollama_embedding_model", getEnv("OLLAMA_EMBEDDING_MODEL", "nomic-embed-text:latest"), "Ollama Embedding Model")
+	ollamaSeed := flag.Int("ollama_seed", getIntEnv("OLLAMA_SEED", 0), "Seed sent with every request so answers are reproducible and cached answers match, 0 for none")
+	recordCalls := flag.Bool("record_calls", getBoolEnv("RECORD_CALLS", true), "Store every model request and reply in the llm_calls table")
 
 	debugMode := flag.Bool("debug", getBoolEnv("DEBUG_MODE", false), "Enable debug mode")
 	dbFilePath := flag.String("db_filepath", getEnv("DB_FILEPATH", "./local.db"), "Database File Path")
+	redactionEnabled := flag.Bool("redact", getBoolEnv("REDACT_ENABLED", true), "Redact secrets and personal data before sending prompts")
+	redactionPatternsFile := flag.String("redact_patterns_file", getEnv("REDACT_PATTERNS_FILE", ""), "File with additional redaction patterns, one \"kind: regexp\" per line")
+	policyFile := flag.String("policy_file", getEnv("POLICY_FILE", ""), "Policy file deciding which paths may be sent to a model, defaults to .codeassistant-policy in the working directory")
+	scanDefaultExcludes := flag.Bool("scan_default_excludes", getBoolEnv("SCAN_DEFAULT_EXCLUDES", true), "Skip vendor, node_modules, build output and similar directories")
+	scanSkipGenerated := flag.Bool("scan_skip_generated", getBoolEnv("SCAN_SKIP_GENERATED", true), "Skip files marked as generated")
+	scanMaxFileBytes := flag.Int64("scan_max_file_bytes", int64(getIntEnv("SCAN_MAX_FILE_BYTES", 1<<20)), "Skip files larger than this many bytes, 0 for no limit")
+	scanMaxLineLength := flag.Int("scan_max_line_length", getIntEnv("SCAN_MAX_LINE_LENGTH", 2000), "Skip files with a line longer than this many characters, 0 for no limit")
+	scanFollowSymlinks := flag.Bool("scan_follow_symlinks", getBoolEnv("SCAN_FOLLOW_SYMLINKS", false), "Follow symbolic links when scanning")
+	contextTokens := flag.String("context_tokens", getEnv("CONTEXT_TOKENS", ""), "Context budgets of the models as \"model=tokens,...\", \"default=tokens\" for other models")
+	ensembleRuns := flag.Int("ensemble_runs", getIntEnv("ENSEMBLE_RUNS", 1), "Run the function list and location steps this many times and vote, 1 disables ensemble mode")
+	ensembleModels := flag.String("ensemble_models", getEnv("ENSEMBLE_MODELS", ""), "Comma separated models the ensemble runs are spread over")
+	ensembleMinConfidence := flag.Float64("ensemble_min_confidence", getFloatEnv("ENSEMBLE_MIN_CONFIDENCE", 0.5), "Analyze functions with a lower agreement between the runs again on the next scan")
+	ensembleMaxAttempts := flag.Int("ensemble_max_attempts", getIntEnv("ENSEMBLE_MAX_ATTEMPTS", 3), "Number of times a function with a low agreement is analyzed again before the best answer is kept")
+	pipelineProfile := flag.String("pipeline", getEnv("PIPELINE", "stepwise"), "Pipeline profile locating and analyzing functions, \"stepwise\" or \"single\" or one of the pipeline file")
+	modelPipelines := flag.String("model_pipelines", getEnv("MODEL_PIPELINES", ""), "Pipeline profiles of models as \"model=profile,...\"")
+	pipelineFile := flag.String("pipeline_file", getEnv("PIPELINE_FILE", ""), "JSON file defining additional pipeline profiles")
+	promptDir := flag.String("prompt_dir", getEnv("PROMPT_DIR", ""), "Directory with prompt templates overriding the built-in ones, \"<model>/<language>/<name>.tmpl\" is looked up first")
+	cacheEnabled := flag.Bool("cache", getBoolEnv("CACHE_ENABLED", true), "Answer identical model requests from the response cache")
+	cacheBypass := flag.Bool("cache_bypass", getBoolEnv("CACHE_BYPASS", false), "Ask the model even if an answer is cached, and refresh the cache")
+	cacheTTL := flag.Duration("cache_ttl", getDurationEnv("CACHE_TTL", 7*24*time.Hour), "Cached answers older than this are not used, 0 keeps them forever")
+	cacheMaxBytes := flag.Int64("cache_max_bytes", int64(getIntEnv("CACHE_MAX_BYTES", 256<<20)), "Drop least recently used answers once the cache is larger than this, 0 for no limit")
 	workingDir := flag.String("working_dir", getEnv("WORKING_DIR", ""), "Working Directory for Code Base")
 
 	// Parse command-line arguments
@@ -68,10 +173,40 @@ func LoadConfig() {
 	AppConfig.Ollama.TextGenModel = *ollamaTextGenModel
 	AppConfig.Ollama.ChatModel = *ollamaChatModel
 	AppConfig.Ollama.EmbeddingModel = *ollamaEmbeddingModel
+	AppConfig.Ollama.RecordCalls = *recordCalls
+	AppConfig.Ollama.Seed = *ollamaSeed
 
 	AppConfig.DebugMode = *debugMode
 	AppConfig.DbFilePath = *dbFilePath
 	AppConfig.WorkingDir = *workingDir
+
+	AppConfig.PolicyFile = *policyFile
+	AppConfig.Scan.DefaultExcludes = *scanDefaultExcludes
+	AppConfig.Scan.SkipGenerated = *scanSkipGenerated
+	AppConfig.Scan.MaxFileBytes = *scanMaxFileBytes
+	AppConfig.Scan.MaxLineLength = *scanMaxLineLength
+	AppConfig.Scan.FollowSymlinks = *scanFollowSymlinks
+
+	AppConfig.Chunking.ContextTokens = *contextTokens
+
+	AppConfig.Ensemble.Runs = *ensembleRuns
+	AppConfig.Ensemble.Models = *ensembleModels
+	AppConfig.Ensemble.MinConfidence = *ensembleMinConfidence
+	AppConfig.Ensemble.MaxAttempts = *ensembleMaxAttempts
+
+	AppConfig.Pipeline.Profile = *pipelineProfile
+	AppConfig.Pipeline.ModelProfiles = *modelPipelines
+	AppConfig.Pipeline.File = *pipelineFile
+
+	AppConfig.Prompts.Dir = *promptDir
+
+	AppConfig.Cache.Enabled = *cacheEnabled
+	AppConfig.Cache.Bypass = *cacheBypass
+	AppConfig.Cache.TTL = *cacheTTL
+	AppConfig.Cache.MaxBytes = *cacheMaxBytes
+
+	AppConfig.Redaction.Enabled = *redactionEnabled
+	AppConfig.Redaction.PatternsFile = *redactionPatternsFile
 }
 
 // getEnv gets the value of the environment variable with the specified key.
@@ -95,6 +230,28 @@ func getIntEnv(key string, defaultValue int) int {
 	return defaultValue
 }
 
+// getFloatEnv gets the value of the environment variable with the specified key
+// and converts it to a float. If the variable is not set or cannot be parsed
+// as a float, it returns the default value.
+func getFloatEnv(key string, defaultValue float64) float64 {
+	valueStr := getEnv(key, "")
+	if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// getDurationEnv gets the value of the environment variable with the specified key
+// and converts it to a duration like "24h". If the variable is not set or cannot be parsed
+// as a duration, it returns the default value.
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+	valueStr := getEnv(key, "")
+	if value, err := time.ParseDuration(valueStr); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
 // getBoolEnv gets the value of the environment variable with the specified key
 // and converts it to a boolean. If the variable is not set or cannot be parsed
 // as a boolean, it returns the default value.
@@ -32,7 +32,8 @@ func NewDatabase(dbPath string) (*Database, error) {
 	var err error
 	once.Do(func() {
 		var db *sql.DB
-		db, err = sql.Open("sqlite3", dbPath)
+		// wait for locks instead of failing, the API server scans and reads concurrently
+		db, err = sql.Open("sqlite3", dbPath+"?_busy_timeout=5000")
 		if err == nil {
 			database = &Database{db: db}
 		}
@@ -100,3 +101,46 @@ func (d *Database) Query(query string, args ...interface{}) (*sql.Rows, error) {
 	}
 	return rows, nil
 }
+
+// QueryRow executes a query that is expected to return at most one row.
+//
+// query - the SQL query to be executed
+// args - optional arguments for the query
+// Returns *sql.Row, errors are deferred until Row's Scan method is called.
+func (d *Database) QueryRow(query string, args ...interface{}) *sql.Row {
+	return d.db.QueryRow(query, args...)
+}
+
+// AddColumn adds a column to an existing table if it does not exist yet.
+//
+// Parameters:
+// - table: the name of the table.
+// - column: the name of the column.
+// - definition: the type and constraints of the column.
+// Returns an error.
+func (d *Database) AddColumn(table string, column string, definition string) error {
+	rows, err := d.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = d.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
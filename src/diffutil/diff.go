@@ -0,0 +1,236 @@
+package diffutil
+
+import (
+	"fmt"
+	"strings"
+)
+
+type OpKind int
+
+const (
+	Equal OpKind = iota
+	Delete
+	Insert
+)
+
+// Op is a single step of an edit script.
+// A is the index in the old lines (Equal, Delete), B the index in the new lines (Equal, Insert).
+type Op struct {
+	Kind OpKind
+	A    int
+	B    int
+}
+
+// Diff computes the shortest edit script between two slices of lines using Myers' algorithm.
+func Diff(a []string, b []string) []Op {
+	// Strip common prefix and suffix, they are always Equal
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var ops []Op
+	for i := 0; i < prefix; i++ {
+		ops = append(ops, Op{Kind: Equal, A: i, B: i})
+	}
+	for _, op := range myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]) {
+		op.A += prefix
+		op.B += prefix
+		ops = append(ops, op)
+	}
+	for i := suffix; i > 0; i-- {
+		ops = append(ops, Op{Kind: Equal, A: len(a) - i, B: len(b) - i})
+	}
+	return ops
+}
+
+func myers(a []string, b []string) []Op {
+	n, m := len(a), len(b)
+	max := n + m
+	offset := max + 1
+	v := make([]int, 2*max+3)
+	var trace [][]int
+
+	for d := 0; d <= max; d++ {
+		snapshot := make([]int, len(v))
+		copy(snapshot, v)
+		trace = append(trace, snapshot)
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, offset, n, m)
+			}
+		}
+	}
+	return nil
+}
+
+func backtrack(trace [][]int, offset int, n int, m int) []Op {
+	var ops []Op
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := trace[d]
+		k := x - y
+
+		var prevK int
+		if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+			prevK = k + 1
+		} else {
+			prevK = k - 1
+		}
+		prevX := v[offset+prevK]
+		prevY := prevX - prevK
+
+		for x > prevX && y > prevY {
+			x--
+			y--
+			ops = append(ops, Op{Kind: Equal, A: x, B: y})
+		}
+		if d > 0 {
+			if x == prevX {
+				y--
+				ops = append(ops, Op{Kind: Insert, A: x, B: y})
+			} else {
+				x--
+				ops = append(ops, Op{Kind: Delete, A: x, B: y})
+			}
+		}
+	}
+
+	// reverse into forward order
+	for i, j := 0, len(ops)-1; i < j; i, j = i+1, j-1 {
+		ops[i], ops[j] = ops[j], ops[i]
+	}
+	return ops
+}
+
+// Unified renders the difference between two slices of lines as a unified diff.
+//
+// It returns an empty string when both sides are equal.
+func Unified(fromName string, toName string, a []string, b []string, context int) string {
+	ops := Diff(a, b)
+
+	// Find ranges of ops that belong to the same hunk
+	var hunks [][2]int
+	for i := 0; i < len(ops); i++ {
+		if ops[i].Kind == Equal {
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for end < len(ops) {
+			if ops[end].Kind != Equal {
+				end++
+				continue
+			}
+			// stop once there are more than 2*context equal lines ahead
+			run := end
+			for run < len(ops) && ops[run].Kind == Equal {
+				run++
+			}
+			if run == len(ops) || run-end > 2*context {
+				end += context
+				if end > len(ops) {
+					end = len(ops)
+				}
+				break
+			}
+			end = run
+		}
+		hunks = append(hunks, [2]int{start, end})
+		i = end - 1
+	}
+	if len(hunks) == 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", fromName, toName)
+	for _, h := range hunks {
+		var body strings.Builder
+		aStart, bStart, aCount, bCount := -1, -1, 0, 0
+		for _, op := range ops[h[0]:h[1]] {
+			if aStart < 0 {
+				aStart, bStart = op.A, op.B
+			}
+			switch op.Kind {
+			case Equal:
+				body.WriteString(" " + a[op.A] + "\n")
+				aCount++
+				bCount++
+			case Delete:
+				body.WriteString("-" + a[op.A] + "\n")
+				aCount++
+			case Insert:
+				body.WriteString("+" + b[op.B] + "\n")
+				bCount++
+			}
+		}
+		fmt.Fprintf(&sb, "@@ -%s +%s @@\n%s", hunkRange(aStart, aCount), hunkRange(bStart, bCount), body.String())
+	}
+	return sb.String()
+}
+
+func hunkRange(start int, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	if count == 1 {
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// LineRange is a 0-based inclusive range of lines
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// ChangedLines returns the ranges of lines in b that were inserted or replaced compared to a.
+// A pure deletion is reported as the line of b following the deleted lines.
+func ChangedLines(a []string, b []string) []LineRange {
+	var ranges []LineRange
+	mark := func(line int) {
+		if line >= len(b) {
+			line = len(b) - 1
+		}
+		if line < 0 {
+			return
+		}
+		if n := len(ranges); n > 0 && line <= ranges[n-1].End+1 {
+			if line > ranges[n-1].End {
+				ranges[n-1].End = line
+			}
+			return
+		}
+		ranges = append(ranges, LineRange{Start: line, End: line})
+	}
+
+	for _, op := range Diff(a, b) {
+		switch op.Kind {
+		case Insert, Delete:
+			mark(op.B)
+		}
+	}
+	return ranges
+}
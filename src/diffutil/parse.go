@@ -0,0 +1,166 @@
+package diffutil
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Hunk is a block of changes in a unified diff. Line numbers are 1-based.
+type Hunk struct {
+	OldStart int
+	OldCount int
+	NewStart int
+	NewCount int
+	Lines    []string
+}
+
+// FileDiff holds the hunks of a single file. A path is empty for added or deleted files.
+type FileDiff struct {
+	OldPath string
+	NewPath string
+	Hunks   []Hunk
+}
+
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// Parse parses a unified diff as produced by "git diff" or "diff -u".
+func Parse(text string) ([]FileDiff, error) {
+	var files []FileDiff
+	var current *FileDiff
+	var hunk *Hunk
+
+	flushHunk := func() {
+		if current != nil && hunk != nil {
+			current.Hunks = append(current.Hunks, *hunk)
+		}
+		hunk = nil
+	}
+	flushFile := func() {
+		flushHunk()
+		if current != nil {
+			files = append(files, *current)
+		}
+		current = nil
+	}
+
+	lines := strings.Split(strings.ReplaceAll(text, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "diff --git "):
+			flushFile()
+			current = &FileDiff{}
+
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ ") &&
+			(hunk == nil || hunkDone(hunk)):
+			if current == nil || len(current.Hunks) > 0 || hunk != nil {
+				flushFile()
+				current = &FileDiff{}
+			}
+			current.OldPath = diffPath(line[4:])
+			current.NewPath = diffPath(lines[i+1][4:])
+			i++
+
+		case strings.HasPrefix(line, "@@ "):
+			if current == nil {
+				return nil, fmt.Errorf("hunk without file header at line %d", i+1)
+			}
+			flushHunk()
+			m := hunkHeader.FindStringSubmatch(line)
+			if m == nil {
+				return nil, fmt.Errorf("invalid hunk header at line %d: %s", i+1, line)
+			}
+			hunk = &Hunk{OldStart: atoi(m[1], 0), OldCount: atoi(m[2], 1), NewStart: atoi(m[3], 0), NewCount: atoi(m[4], 1)}
+
+		case hunk != nil && (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "+") || strings.HasPrefix(line, "-")):
+			hunk.Lines = append(hunk.Lines, line)
+
+		case hunk != nil && strings.HasPrefix(line, `\`):
+			// "\ No newline at end of file"
+		}
+	}
+	flushFile()
+	return files, nil
+}
+
+// hunkDone reports whether all lines announced in the hunk header were read
+func hunkDone(h *Hunk) bool {
+	oldLines, newLines := 0, 0
+	for _, l := range h.Lines {
+		switch l[0] {
+		case ' ':
+			oldLines++
+			newLines++
+		case '-':
+			oldLines++
+		case '+':
+			newLines++
+		}
+	}
+	return oldLines >= h.OldCount && newLines >= h.NewCount
+}
+
+// diffPath strips the a/ b/ prefixes and timestamps from a file header path
+func diffPath(path string) string {
+	if idx := strings.Index(path, "\t"); idx >= 0 {
+		path = path[:idx]
+	}
+	path = strings.TrimSpace(path)
+	if path == "/dev/null" {
+		return ""
+	}
+	if strings.HasPrefix(path, "a/") || strings.HasPrefix(path, "b/") {
+		return path[2:]
+	}
+	return path
+}
+
+func atoi(s string, defaultValue int) int {
+	if s == "" {
+		return defaultValue
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return defaultValue
+	}
+	return v
+}
+
+// NewLineRanges returns the 0-based inclusive line ranges of the new file touched by the hunks.
+// A pure deletion is reported as the lines around the deletion point.
+func (f FileDiff) NewLineRanges() []LineRange {
+	var ranges []LineRange
+	for _, h := range f.Hunks {
+		if h.NewCount == 0 {
+			start := h.NewStart - 1
+			if start < 0 {
+				start = 0
+			}
+			ranges = append(ranges, LineRange{Start: start, End: h.NewStart})
+			continue
+		}
+		ranges = append(ranges, LineRange{Start: h.NewStart - 1, End: h.NewStart + h.NewCount - 2})
+	}
+	return ranges
+}
+
+// String renders the hunk in unified diff format, including its header.
+func (h Hunk) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "@@ -%s +%s @@\n", headerRange(h.OldStart, h.OldCount), headerRange(h.NewStart, h.NewCount))
+	for _, line := range h.Lines {
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// headerRange formats a 1-based hunk header range, the count is omitted when it is 1
+func headerRange(start int, count int) string {
+	if count == 1 {
+		return fmt.Sprintf("%d", start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
@@ -0,0 +1,238 @@
+package docgen
+
+import (
+	"code_assistant/src/diffutil"
+	"code_assistant/src/index"
+	"code_assistant/src/lang"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Options controls which functions are documented and whether files are modified
+type Options struct {
+	FilePath  string // only document this file, all indexed files if empty
+	Write     bool   // apply the changes in place instead of only producing a diff
+	Overwrite bool   // replace existing documentation
+}
+
+// Result holds the unified diff of all changes and the files that were changed
+type Result struct {
+	Diff     string
+	Files    []string
+	Inserted int
+	Skipped  []string
+}
+
+type edit struct {
+	at     int
+	remove int
+	insert []string
+}
+
+// sourceFile keeps the lines of a file together with their original line endings
+type sourceFile struct {
+	lines []string
+	ends  []string
+	eol   string
+}
+
+// Generate produces doc comments for undocumented functions from the stored analysis.
+//
+// The purpose, arguments and return fields collected during the scan are rendered in the
+// documentation convention of each language. Functions that already have a leading comment
+// are left untouched unless opts.Overwrite is set.
+func Generate(opts Options) (*Result, error) {
+	files, err := index.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	for _, f := range files {
+		if opts.FilePath != "" && f.FilePath != opts.FilePath {
+			continue
+		}
+		l, ok := lang.ForFile(f.FilePath)
+		if !ok {
+			continue
+		}
+		functions, err := index.FunctionsByFile(f.Id)
+		if err != nil {
+			return nil, err
+		}
+		if len(functions) == 0 {
+			continue
+		}
+
+		raw, err := os.ReadFile(f.FilePath)
+		if err != nil {
+			result.Skipped = append(result.Skipped, fmt.Sprintf("%s: %v", f.FilePath, err))
+			continue
+		}
+		src := splitLines(string(raw))
+
+		var edits []edit
+		for _, fn := range functions {
+			e, reason := planEdit(src, fn, l, opts.Overwrite)
+			if reason != "" {
+				result.Skipped = append(result.Skipped, fmt.Sprintf("%s:%d %s: %s", f.FilePath, fn.LineStart, fn.FunctionName, reason))
+				continue
+			}
+			edits = append(edits, *e)
+		}
+		if len(edits) == 0 {
+			continue
+		}
+
+		updated := applyEdits(src, edits)
+		result.Diff += diffutil.Unified(f.FilePath, f.FilePath, src.lines, updated.lines, 3)
+		result.Files = append(result.Files, f.FilePath)
+		result.Inserted += len(edits)
+
+		if opts.Write {
+			info, err := os.Stat(f.FilePath)
+			if err != nil {
+				return nil, err
+			}
+			if err := os.WriteFile(f.FilePath, []byte(updated.join()), info.Mode().Perm()); err != nil {
+				return nil, fmt.Errorf("failed to write %s: %v", f.FilePath, err)
+			}
+		}
+	}
+	return result, nil
+}
+
+// planEdit decides where the comment of fn goes. It returns a reason when fn must be skipped.
+func planEdit(src *sourceFile, fn index.Function, l lang.Language, overwrite bool) (*edit, string) {
+	declIdx := FindDeclaration(src.lines, fn, l)
+	if declIdx < 0 {
+		return nil, "declaration not found near stored line range"
+	}
+
+	if l.DocStyle == lang.DocStyleDocstring && l.SingleLineDefinition(src.lines, declIdx) {
+		return nil, "single-line definition, its body has to be moved to a new line before a docstring can be added"
+	}
+
+	start, end, documented := l.LeadingComment(src.lines, declIdx)
+	if documented && !overwrite {
+		return nil, "already documented"
+	}
+	if strings.TrimSpace(fn.Description) == "" {
+		return nil, "no stored description"
+	}
+
+	comment := Render(fn, l)
+	var indent string
+	at := declIdx
+	if l.DocStyle == lang.DocStyleDocstring {
+		at = lang.DeclarationEnd(src.lines, declIdx) + 1
+		indent = lang.Indentation(src.lines[declIdx]) + "    "
+		for idx := at; idx < len(src.lines); idx++ {
+			if strings.TrimSpace(src.lines[idx]) != "" {
+				if body := lang.Indentation(src.lines[idx]); len(body) > len(lang.Indentation(src.lines[declIdx])) {
+					indent = body
+				}
+				break
+			}
+		}
+	} else {
+		indent = lang.Indentation(src.lines[declIdx])
+		// keep annotations and decorators directly above the declaration
+		for at > 0 && strings.HasPrefix(strings.TrimSpace(src.lines[at-1]), "@") {
+			at--
+		}
+	}
+
+	e := &edit{at: at}
+	if documented {
+		e.at = start
+		e.remove = end - start + 1
+	}
+	for _, line := range comment {
+		if line == "" {
+			e.insert = append(e.insert, "")
+			continue
+		}
+		e.insert = append(e.insert, indent+line)
+	}
+	return e, ""
+}
+
+// FindDeclaration returns the index of the line declaring fn, -1 if it is not found. It looks a few lines around
+// the stored start line because the model sometimes reports it slightly off, or the file changed since.
+func FindDeclaration(lines []string, fn index.Function, l lang.Language) int {
+	name := index.ShortName(fn.FunctionName)
+	if name == "" {
+		return -1
+	}
+	start := fn.LineStart - 1
+	for _, delta := range []int{0, 1, -1, 2, -2, 3, -3} {
+		idx := start + delta
+		if idx < 0 || idx >= len(lines) {
+			continue
+		}
+		line := lines[idx]
+		if !strings.Contains(line, name) {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if l.LineComment != "" && strings.HasPrefix(trimmed, l.LineComment) {
+			continue
+		}
+		if l.DocStyle == lang.DocStyleDocstring && !strings.Contains(line, "def "+name) {
+			continue
+		}
+		return idx
+	}
+	return -1
+}
+
+func applyEdits(src *sourceFile, edits []edit) *sourceFile {
+	sort.Slice(edits, func(i, j int) bool { return edits[i].at > edits[j].at })
+
+	out := &sourceFile{lines: append([]string{}, src.lines...), ends: append([]string{}, src.ends...), eol: src.eol}
+	for _, e := range edits {
+		ends := make([]string, len(e.insert))
+		for i := range ends {
+			ends[i] = src.eol
+		}
+		out.lines = append(out.lines[:e.at], append(e.insert, out.lines[e.at+e.remove:]...)...)
+		out.ends = append(out.ends[:e.at], append(ends, out.ends[e.at+e.remove:]...)...)
+	}
+	return out
+}
+
+// splitLines splits content into lines, remembering each line ending so they can be restored.
+func splitLines(content string) *sourceFile {
+	src := &sourceFile{eol: "\n"}
+	if strings.Count(content, "\r\n") > strings.Count(content, "\n")/2 {
+		src.eol = "\r\n"
+	}
+	for len(content) > 0 {
+		idx := strings.Index(content, "\n")
+		if idx < 0 {
+			src.lines = append(src.lines, content)
+			src.ends = append(src.ends, "")
+			break
+		}
+		line, end := content[:idx], "\n"
+		if strings.HasSuffix(line, "\r") {
+			line, end = line[:len(line)-1], "\r\n"
+		}
+		src.lines = append(src.lines, line)
+		src.ends = append(src.ends, end)
+		content = content[idx+1:]
+	}
+	return src
+}
+
+func (s *sourceFile) join() string {
+	var sb strings.Builder
+	for i, line := range s.lines {
+		sb.WriteString(line)
+		sb.WriteString(s.ends[i])
+	}
+	return sb.String()
+}
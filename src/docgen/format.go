@@ -0,0 +1,204 @@
+package docgen
+
+import (
+	"code_assistant/src/index"
+	"code_assistant/src/lang"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const wrapWidth = 90
+
+type argDoc struct {
+	Name        string
+	Description string
+}
+
+var argPattern = regexp.MustCompile(`^[-*\s]*([A-Za-z_$][A-Za-z0-9_$]*)\s*(?:\([^)]*\))?\s*[:\-–]\s*(.+)$`)
+
+// splitArguments tries to split the free-text arguments description into one entry per argument.
+// It returns nil when the text does not look like a list of "name: description" pairs.
+func splitArguments(text string) []argDoc {
+	var parts []string
+	for _, line := range strings.Split(text, "\n") {
+		for _, p := range strings.Split(line, ";") {
+			if p = strings.TrimSpace(p); p != "" {
+				parts = append(parts, p)
+			}
+		}
+	}
+
+	var args []argDoc
+	for _, p := range parts {
+		m := argPattern.FindStringSubmatch(p)
+		if m == nil {
+			return nil
+		}
+		args = append(args, argDoc{Name: m[1], Description: strings.TrimSpace(m[2])})
+	}
+	return args
+}
+
+// isEmptyDoc reports whether a field the model returned carries no information
+func isEmptyDoc(text string) bool {
+	switch strings.ToLower(strings.Trim(strings.TrimSpace(text), ".")) {
+	case "", "none", "n/a", "na", "void", "nothing", "no arguments", "no return value", "null":
+		return true
+	}
+	return false
+}
+
+// summary turns the stored purpose into a sentence starting with the function name, as GoDoc expects
+func summary(fn index.Function, startWithName bool) string {
+	purpose := strings.TrimSpace(fn.Description)
+	name := index.ShortName(fn.FunctionName)
+	for _, prefix := range []string{"This function ", "The function ", "this function ", "the function "} {
+		purpose = strings.TrimPrefix(purpose, prefix)
+	}
+	if purpose == "" {
+		purpose = "is undocumented"
+	}
+	if startWithName && !strings.HasPrefix(purpose, name) {
+		first, size := utf8.DecodeRuneInString(purpose)
+		purpose = name + " " + string(unicode.ToLower(first)) + purpose[size:]
+	}
+	if !strings.HasSuffix(purpose, ".") {
+		purpose += "."
+	}
+	return purpose
+}
+
+// wrap breaks text into lines no longer than width, keeping explicit line breaks
+func wrap(text string, width int) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(text, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			if line != "" && len(line)+1+len(word) > width {
+				lines = append(lines, line)
+				line = word
+				continue
+			}
+			if line != "" {
+				line += " "
+			}
+			line += word
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// Render builds the documentation comment of fn in the doc style of l.
+//
+// The returned lines carry no indentation and no line endings.
+func Render(fn index.Function, l lang.Language) []string {
+	args := splitArguments(fn.Arguments)
+
+	switch l.DocStyle {
+	case lang.DocStyleGoDoc:
+		var out []string
+		for _, line := range wrap(summary(fn, true), wrapWidth) {
+			out = append(out, "// "+line)
+		}
+		if !isEmptyDoc(fn.Arguments) || !isEmptyDoc(fn.Return) {
+			out = append(out, "//")
+		}
+		if !isEmptyDoc(fn.Arguments) {
+			if args != nil {
+				out = append(out, "// Parameters:")
+				for _, a := range args {
+					out = append(out, "// - "+a.Name+": "+a.Description)
+				}
+			} else {
+				for _, line := range wrap("Parameters: "+fn.Arguments, wrapWidth) {
+					out = append(out, "// "+line)
+				}
+			}
+		}
+		if !isEmptyDoc(fn.Return) {
+			for _, line := range wrap("Returns: "+fn.Return, wrapWidth) {
+				out = append(out, "// "+line)
+			}
+		}
+		return trimCommentLines(out)
+
+	case lang.DocStyleDocstring:
+		body := wrap(summary(fn, false), wrapWidth)
+		if !isEmptyDoc(fn.Arguments) {
+			body = append(body, "", "Args:")
+			if args != nil {
+				for _, a := range args {
+					body = append(body, "    "+a.Name+": "+a.Description)
+				}
+			} else {
+				for _, line := range wrap(fn.Arguments, wrapWidth) {
+					body = append(body, "    "+line)
+				}
+			}
+		}
+		if !isEmptyDoc(fn.Return) {
+			body = append(body, "", "Returns:")
+			for _, line := range wrap(fn.Return, wrapWidth) {
+				body = append(body, "    "+line)
+			}
+		}
+		if len(body) == 1 {
+			return []string{`"""` + body[0] + `"""`}
+		}
+		out := []string{`"""` + body[0]}
+		out = append(out, body[1:]...)
+		return append(out, `"""`)
+
+	default:
+		// JSDoc, Javadoc and Doxygen share the block comment layout
+		var body []string
+		first := summary(fn, false)
+		if l.DocStyle == lang.DocStyleDoxygen {
+			first = "@brief " + first
+		}
+		body = append(body, wrap(first, wrapWidth)...)
+
+		tags := []string{}
+		if !isEmptyDoc(fn.Arguments) {
+			if args != nil {
+				for _, a := range args {
+					tags = append(tags, "@param "+a.Name+" "+a.Description)
+				}
+			} else if l.DocStyle != lang.DocStyleDoxygen {
+				// without argument names a @param tag would be invalid
+				body = append(body, "", "Arguments: "+fn.Arguments)
+			} else {
+				tags = append(tags, "@details Arguments: "+fn.Arguments)
+			}
+		}
+		if !isEmptyDoc(fn.Return) {
+			returnTag := "@return "
+			if l.DocStyle == lang.DocStyleJSDoc {
+				returnTag = "@returns "
+			}
+			tags = append(tags, returnTag+fn.Return)
+		}
+		if len(tags) > 0 {
+			body = append(body, "")
+			for _, tag := range tags {
+				body = append(body, wrap(tag, wrapWidth)...)
+			}
+		}
+
+		out := []string{"/**"}
+		for _, line := range body {
+			out = append(out, strings.TrimRight(" * "+line, " "))
+		}
+		return append(out, " */")
+	}
+}
+
+func trimCommentLines(lines []string) []string {
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+	return lines
+}
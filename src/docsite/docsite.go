@@ -0,0 +1,232 @@
+package docsite
+
+import (
+	"code_assistant/src/fileutil"
+	"code_assistant/src/index"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const (
+	FormatHTML     = "html"
+	FormatMarkdown = "markdown"
+)
+
+// Options controls how the documentation site is generated
+type Options struct {
+	OutDir string
+	Format string
+}
+
+type site struct {
+	Root  string
+	Dirs  []*dirPage
+	Files []*filePage
+}
+
+type dirPage struct {
+	Name  string
+	Slug  string
+	Files []*filePage
+}
+
+type filePage struct {
+	Path      string
+	AbsPath   string
+	Slug      string
+	Dir       *dirPage
+	Functions []*funcEntry
+	Lines     []string
+	HasSource bool
+}
+
+type funcEntry struct {
+	index.Function
+	File    *filePage
+	Callers []*funcEntry
+	Callees []*funcEntry
+}
+
+// Anchor returns the id of the function's section in its file page
+func (f *funcEntry) Anchor() string {
+	return fmt.Sprintf("fn-%d", f.Id)
+}
+
+// searchEntry is a single record in the client side search index
+type searchEntry struct {
+	Name        string `json:"name"`
+	Kind        string `json:"kind"`
+	Signature   string `json:"signature,omitempty"`
+	Description string `json:"description,omitempty"`
+	Path        string `json:"path"`
+	URL         string `json:"url"`
+}
+
+// Build generates a static documentation site from the SQLite index.
+//
+// It only reads local.db and the source files on disk, the model is never called.
+// One page is written per directory and per file, plus a source page with line anchors,
+// an index page and a search index.
+func Build(opts Options) error {
+	if opts.OutDir == "" {
+		return fmt.Errorf("output directory cannot be empty")
+	}
+	if opts.Format == "" {
+		opts.Format = FormatHTML
+	}
+	if opts.Format != FormatHTML && opts.Format != FormatMarkdown {
+		return fmt.Errorf("unsupported format %s", opts.Format)
+	}
+
+	s, err := loadSite()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(opts.OutDir, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+
+	if opts.Format == FormatMarkdown {
+		return writeMarkdown(s, opts.OutDir)
+	}
+	return writeHTML(s, opts.OutDir)
+}
+
+// loadSite reads files and functions from the index and links them together
+func loadSite() (*site, error) {
+	files, err := index.ListFiles()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files: %v", err)
+	}
+	functions, err := index.ListFunctions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list functions: %v", err)
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, f := range files {
+		paths = append(paths, f.FilePath)
+	}
+	s := &site{Root: commonDir(paths)}
+
+	dirs := map[string]*dirPage{}
+	filesById := map[int]*filePage{}
+	for _, f := range files {
+		rel, err := filepath.Rel(s.Root, f.FilePath)
+		if err != nil {
+			rel = f.FilePath
+		}
+		rel = filepath.ToSlash(rel)
+
+		dirName := filepath.ToSlash(filepath.Dir(rel))
+		dir, ok := dirs[dirName]
+		if !ok {
+			dir = &dirPage{Name: dirName, Slug: slugify(dirName)}
+			dirs[dirName] = dir
+			s.Dirs = append(s.Dirs, dir)
+		}
+
+		fp := &filePage{Path: rel, AbsPath: f.FilePath, Slug: slugify(rel), Dir: dir}
+		if lines, err := fileutil.ReadFileLines(f.FilePath); err == nil {
+			fp.Lines = lines
+			fp.HasSource = true
+		}
+		dir.Files = append(dir.Files, fp)
+		s.Files = append(s.Files, fp)
+		filesById[f.Id] = fp
+	}
+	sort.Slice(s.Dirs, func(i, j int) bool { return s.Dirs[i].Name < s.Dirs[j].Name })
+
+	entries := map[int]*funcEntry{}
+	for _, fn := range functions {
+		fp, ok := filesById[fn.FileId]
+		if !ok {
+			continue
+		}
+		entry := &funcEntry{Function: fn, File: fp}
+		entries[fn.Id] = entry
+		fp.Functions = append(fp.Functions, entry)
+	}
+
+	graph := index.BuildCallGraph(functions)
+	for id, entry := range entries {
+		for _, callerId := range graph.Callers[id] {
+			if caller, ok := entries[callerId]; ok {
+				entry.Callers = append(entry.Callers, caller)
+			}
+		}
+		for _, calleeId := range graph.Callees[id] {
+			if callee, ok := entries[calleeId]; ok {
+				entry.Callees = append(entry.Callees, callee)
+			}
+		}
+	}
+
+	return s, nil
+}
+
+// searchIndex flattens directories, files and functions into search records
+func searchIndex(s *site, ext string) []searchEntry {
+	var entries []searchEntry
+	for _, dir := range s.Dirs {
+		entries = append(entries, searchEntry{Name: dir.Name, Kind: "directory", Path: dir.Name, URL: "dir-" + dir.Slug + ext})
+	}
+	for _, fp := range s.Files {
+		entries = append(entries, searchEntry{Name: filepath.Base(fp.Path), Kind: "file", Path: fp.Path, URL: "file-" + fp.Slug + ext})
+		for _, fn := range fp.Functions {
+			entries = append(entries, searchEntry{
+				Name:        fn.FunctionName,
+				Kind:        "function",
+				Signature:   fn.Signature,
+				Description: fn.Description,
+				Path:        fmt.Sprintf("%s:%d", fp.Path, fn.LineStart),
+				URL:         "file-" + fp.Slug + ext + "#" + fn.Anchor(),
+			})
+		}
+	}
+	return entries
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// commonDir returns the deepest directory shared by all paths
+func commonDir(paths []string) string {
+	if len(paths) == 0 {
+		return ""
+	}
+	common := filepath.Dir(paths[0])
+	for _, p := range paths[1:] {
+		for common != "" && !strings.HasPrefix(p, common+string(filepath.Separator)) && common != string(filepath.Separator) {
+			parent := filepath.Dir(common)
+			if parent == common {
+				break
+			}
+			common = parent
+		}
+	}
+	return common
+}
+
+// slugify turns a relative path into a flat file name.
+// The readable part is not unique, e.g. "a/b__c" and "a__b/c", so a short hash of the path is appended.
+func slugify(path string) string {
+	if path == "." || path == "" {
+		return "root"
+	}
+	replacer := strings.NewReplacer("/", "__", "\\", "__", " ", "_", ":", "_")
+	sum := sha256.Sum256([]byte(filepath.ToSlash(path)))
+	return replacer.Replace(path) + "-" + hex.EncodeToString(sum[:4])
+}
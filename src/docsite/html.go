@@ -0,0 +1,155 @@
+package docsite
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+)
+
+const htmlLayout = `{{define "header"}}<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.}}</title>
+<link rel="stylesheet" href="style.css">
+</head>
+<body>
+<nav><a href="index.html">Index</a> | <a href="search.html">Search</a></nav>
+<h1>{{.}}</h1>
+{{end}}
+{{define "footer"}}</body>
+</html>
+{{end}}
+{{define "funclinks"}}{{range $i, $f := .}}{{if $i}}, {{end}}<a href="file-{{$f.File.Slug}}.html#{{$f.Anchor}}">{{$f.FunctionName}}</a>{{end}}{{end}}
+{{define "index"}}{{template "header" "Code Documentation"}}
+<p>Root: <code>{{.Root}}</code></p>
+<ul>
+{{range .Dirs}}<li><a href="dir-{{.Slug}}.html">{{.Name}}</a> ({{len .Files}} files)</li>
+{{end}}</ul>
+{{template "footer"}}{{end}}
+{{define "dir"}}{{template "header" .Name}}
+<ul>
+{{range .Files}}<li><a href="file-{{.Slug}}.html">{{.Path}}</a> ({{len .Functions}} functions)</li>
+{{end}}</ul>
+{{template "footer"}}{{end}}
+{{define "file"}}{{template "header" .Path}}
+<p>Directory: <a href="dir-{{.Dir.Slug}}.html">{{.Dir.Name}}</a>{{if .HasSource}} | <a href="src-{{.Slug}}.html">Source</a>{{end}}</p>
+<ul>
+{{range .Functions}}<li><a href="#{{.Anchor}}">{{.FunctionName}}</a></li>
+{{end}}</ul>
+{{range .Functions}}
+<section id="{{.Anchor}}">
+<h2>{{.FunctionName}}</h2>
+<pre><code>{{.Signature}}</code></pre>
+<p>{{.Description}}</p>
+<dl>
+<dt>Arguments</dt><dd>{{.Arguments}}</dd>
+<dt>Return</dt><dd>{{.Return}}</dd>
+<dt>Source</dt><dd>{{if $.HasSource}}<a href="src-{{$.Slug}}.html#L{{.LineStart}}">lines {{.LineStart}}-{{.LineEnd}}</a>{{else}}lines {{.LineStart}}-{{.LineEnd}}{{end}}</dd>
+{{if .Callers}}<dt>Called by</dt><dd>{{template "funclinks" .Callers}}</dd>{{end}}
+{{if .Callees}}<dt>Calls</dt><dd>{{template "funclinks" .Callees}}</dd>{{end}}
+</dl>
+</section>
+{{end}}
+{{template "footer"}}{{end}}
+{{define "source"}}{{template "header" .Path}}
+<p><a href="file-{{.Slug}}.html">Back to documentation</a></p>
+<table class="source">
+{{range $i, $line := .Lines}}<tr id="L{{lineno $i}}"><td class="lineno"><a href="#L{{lineno $i}}">{{lineno $i}}</a></td><td><pre>{{$line}}</pre></td></tr>
+{{end}}</table>
+{{template "footer"}}{{end}}
+{{define "search"}}{{template "header" "Search"}}
+<input id="query" type="search" placeholder="Search functions, files and directories" autofocus>
+<ul id="results"></ul>
+<script src="search_index.js"></script>
+<script src="search.js"></script>
+{{template "footer"}}{{end}}
+`
+
+const styleCSS = `body { font-family: sans-serif; margin: 2em; }
+pre { margin: 0; }
+section { border-top: 1px solid #ddd; padding-top: 1em; }
+dt { font-weight: bold; }
+table.source { border-collapse: collapse; font-family: monospace; }
+table.source td.lineno { text-align: right; padding-right: 1em; color: #888; }
+table.source tr:target { background: #ffa; }
+`
+
+const searchJS = `(function () {
+  var input = document.getElementById("query");
+  var results = document.getElementById("results");
+  function render() {
+    var q = input.value.toLowerCase();
+    results.innerHTML = "";
+    if (!q) { return; }
+    window.SEARCH_INDEX.filter(function (e) {
+      return (e.name + " " + (e.signature || "") + " " + (e.description || "") + " " + e.path).toLowerCase().indexOf(q) >= 0;
+    }).slice(0, 100).forEach(function (e) {
+      var li = document.createElement("li");
+      var a = document.createElement("a");
+      a.href = e.url;
+      a.textContent = e.name;
+      li.appendChild(a);
+      li.appendChild(document.createTextNode(" (" + e.kind + ") " + e.path));
+      results.appendChild(li);
+    });
+  }
+  input.addEventListener("input", render);
+})();
+`
+
+// writeHTML renders the site as linked HTML pages
+func writeHTML(s *site, outDir string) error {
+	tmpl, err := template.New("site").Funcs(template.FuncMap{
+		"lineno": func(i int) int { return i + 1 },
+	}).Parse(htmlLayout)
+	if err != nil {
+		return fmt.Errorf("failed to parse templates: %v", err)
+	}
+
+	render := func(name string, page string, data interface{}) error {
+		f, err := os.Create(filepath.Join(outDir, name))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return tmpl.ExecuteTemplate(f, page, data)
+	}
+
+	if err := render("index.html", "index", s); err != nil {
+		return err
+	}
+	if err := render("search.html", "search", nil); err != nil {
+		return err
+	}
+	for _, dir := range s.Dirs {
+		if err := render("dir-"+dir.Slug+".html", "dir", dir); err != nil {
+			return err
+		}
+	}
+	for _, fp := range s.Files {
+		if err := render("file-"+fp.Slug+".html", "file", fp); err != nil {
+			return err
+		}
+		if fp.HasSource {
+			if err := render("src-"+fp.Slug+".html", "source", fp); err != nil {
+				return err
+			}
+		}
+	}
+
+	// The index is wrapped in a script so search also works when opened from file://
+	data, err := json.Marshal(searchIndex(s, ".html"))
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "search_index.js"), []byte("window.SEARCH_INDEX = "+string(data)+";\n"), 0644); err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "search.js"), []byte(searchJS), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outDir, "style.css"), []byte(styleCSS), 0644)
+}
@@ -0,0 +1,67 @@
+package docsite
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// writeMarkdown renders the site as linked Markdown pages
+func writeMarkdown(s *site, outDir string) error {
+	var b strings.Builder
+
+	b.WriteString("# Code Documentation\n\n")
+	fmt.Fprintf(&b, "Root: `%s`\n\n", s.Root)
+	for _, dir := range s.Dirs {
+		fmt.Fprintf(&b, "- [%s](dir-%s.md) (%d files)\n", dir.Name, dir.Slug, len(dir.Files))
+	}
+	if err := os.WriteFile(filepath.Join(outDir, "index.md"), []byte(b.String()), 0644); err != nil {
+		return err
+	}
+
+	for _, dir := range s.Dirs {
+		b.Reset()
+		fmt.Fprintf(&b, "# %s\n\n[Index](index.md)\n\n", dir.Name)
+		for _, fp := range dir.Files {
+			fmt.Fprintf(&b, "- [%s](file-%s.md) (%d functions)\n", fp.Path, fp.Slug, len(fp.Functions))
+		}
+		if err := os.WriteFile(filepath.Join(outDir, "dir-"+dir.Slug+".md"), []byte(b.String()), 0644); err != nil {
+			return err
+		}
+	}
+
+	for _, fp := range s.Files {
+		b.Reset()
+		fmt.Fprintf(&b, "# %s\n\n[Index](index.md) | Directory: [%s](dir-%s.md)\n\n", fp.Path, fp.Dir.Name, fp.Dir.Slug)
+		for _, fn := range fp.Functions {
+			fmt.Fprintf(&b, "- [%s](#%s)\n", fn.FunctionName, fn.Anchor())
+		}
+		for _, fn := range fp.Functions {
+			fmt.Fprintf(&b, "\n<a id=\"%s\"></a>\n## %s\n\n", fn.Anchor(), fn.FunctionName)
+			fmt.Fprintf(&b, "```\n%s\n```\n\n%s\n\n", fn.Signature, fn.Description)
+			fmt.Fprintf(&b, "- **Arguments:** %s\n", fn.Arguments)
+			fmt.Fprintf(&b, "- **Return:** %s\n", fn.Return)
+			fmt.Fprintf(&b, "- **Source:** [lines %d-%d](%s#L%d)\n", fn.LineStart, fn.LineEnd, filepath.ToSlash(fp.AbsPath), fn.LineStart)
+			if len(fn.Callers) > 0 {
+				fmt.Fprintf(&b, "- **Called by:** %s\n", markdownLinks(fn.Callers))
+			}
+			if len(fn.Callees) > 0 {
+				fmt.Fprintf(&b, "- **Calls:** %s\n", markdownLinks(fn.Callees))
+			}
+		}
+		if err := os.WriteFile(filepath.Join(outDir, "file-"+fp.Slug+".md"), []byte(b.String()), 0644); err != nil {
+			return err
+		}
+	}
+
+	return writeJSON(filepath.Join(outDir, "search_index.json"), searchIndex(s, ".md"))
+}
+
+func markdownLinks(functions []*funcEntry) string {
+	links := make([]string, 0, len(functions))
+	for _, fn := range functions {
+		links = append(links, fmt.Sprintf("[%s](file-%s.md#%s)", fn.FunctionName, fn.File.Slug, fn.Anchor()))
+	}
+	return strings.Join(links, ", ")
+}
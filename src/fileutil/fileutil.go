@@ -1,60 +1,157 @@
 package fileutil
 
 import (
-	"bufio"
+	"code_assistant/src/config"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
 // ScanFiles scans files in a folder with specified file extensions and returns their paths.
+// Paths ignored by .gitignore, .codeassistantignore or the built-in exclusions are skipped, and so are generated files.
 func ScanFiles(directory string, extensions []string) ([]string, error) {
-	var files []string
+	files, _, err := ScanFilesWithSkipped(directory, extensions)
+	return files, err
+}
+
+// ScanFilesWithSkipped scans files like ScanFiles and also returns the symbolic links that were not followed.
+//
+// Symbolic links are followed when configured. A directory reached a second time through a link is
+// reported as a loop, a file reached a second time is not returned again.
+func ScanFilesWithSkipped(directory string, extensions []string) ([]string, []SkippedFile, error) {
+	w := &fileWalker{
+		ignore:     NewIgnorer(directory),
+		extensions: extensions,
+		visited:    map[string]bool{},
+	}
+	root, err := filepath.Abs(directory)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := os.Stat(root)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !info.IsDir() {
+		return nil, nil, fmt.Errorf("%s is not a directory", directory)
+	}
+	if err := w.walk(root); err != nil {
+		return nil, nil, err
+	}
+	return w.files, w.skipped, nil
+}
 
-	err := filepath.Walk(directory, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
+// fileWalker collects code files below a directory
+type fileWalker struct {
+	ignore     *Ignorer
+	extensions []string
+	visited    map[string]bool // real paths of directories and files already seen
+	files      []string
+	skipped    []SkippedFile
+}
+
+func (w *fileWalker) walk(dir string) error {
+	if real, err := filepath.EvalSymlinks(dir); err == nil {
+		w.visited[real] = true
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		path := filepath.Join(dir, entry.Name())
+		isDir := entry.IsDir()
+
+		if entry.Type()&os.ModeSymlink != 0 {
+			target, err := os.Stat(path)
+			if err != nil {
+				w.skipped = append(w.skipped, SkippedFile{Path: path, Reason: SkipUnreadable, Detail: err.Error()})
+				continue
+			}
+			if !target.IsDir() && !w.hasExtension(path) {
+				continue
+			}
+			if !config.AppConfig.Scan.FollowSymlinks {
+				w.skipped = append(w.skipped, SkippedFile{Path: path, Reason: SkipSymlink, Detail: "symbolic links are not followed"})
+				continue
+			}
+			real, err := filepath.EvalSymlinks(path)
+			if err != nil {
+				w.skipped = append(w.skipped, SkippedFile{Path: path, Reason: SkipUnreadable, Detail: err.Error()})
+				continue
+			}
+			if target.IsDir() && w.visited[real] {
+				w.skipped = append(w.skipped, SkippedFile{Path: path, Reason: SkipSymlinkLoop, Detail: "links to " + real + ", which is already scanned"})
+				continue
+			}
+			isDir = target.IsDir()
 		}
-		if !info.IsDir() {
-			// Check if the file extension is in the whitelist
-			ext := filepath.Ext(path)
-			for _, allowedExt := range extensions {
-				if strings.EqualFold(ext, allowedExt) {
-					absPath, _ := filepath.Abs(path)
-					files = append(files, absPath)
-					break
-				}
+
+		if isDir {
+			// Skip ignored directories without walking them
+			if w.ignore.Ignored(path, true) {
+				continue
+			}
+			if err := w.walk(path); err != nil {
+				w.skipped = append(w.skipped, SkippedFile{Path: path, Reason: SkipUnreadable, Detail: err.Error()})
 			}
+			continue
 		}
-		return nil
-	})
 
-	if err != nil {
-		return nil, err
+		// Check if the file extension is in the whitelist
+		if !w.hasExtension(path) || w.ignore.Skip(path) {
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			if w.visited[real] {
+				continue
+			}
+			w.visited[real] = true
+		}
+		w.files = append(w.files, path)
 	}
+	return nil
+}
 
-	return files, nil
+func (w *fileWalker) hasExtension(path string) bool {
+	ext := filepath.Ext(path)
+	for _, allowedExt := range w.extensions {
+		if strings.EqualFold(ext, allowedExt) {
+			return true
+		}
+	}
+	return false
 }
 
 // ReadFileLines reads the lines of a file and returns them as a slice of strings.
+// The content is decoded to UTF-8, lines of any length are read.
 func ReadFileLines(filePath string) ([]string, error) {
-	file, err := os.Open(filePath)
+	data, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
-
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
+	text, ok := DecodeText(data)
+	if !ok {
+		return nil, fmt.Errorf("%s is a binary file", filePath)
 	}
+	return SplitLines(text), nil
+}
 
-	if err := scanner.Err(); err != nil {
+// ReadLines reads all lines from r and returns them as a slice of strings.
+// The content is decoded to UTF-8, lines of any length are read.
+func ReadLines(r io.Reader) ([]string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
 		return nil, err
 	}
-
-	return lines, nil
+	text, ok := DecodeText(data)
+	if !ok {
+		return nil, fmt.Errorf("binary content")
+	}
+	return SplitLines(text), nil
 }
 
 func FileExists(filePath string) bool {
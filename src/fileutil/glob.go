@@ -0,0 +1,64 @@
+package fileutil
+
+import (
+	"path"
+	"strings"
+)
+
+// MatchGlob reports whether a slash separated relative path matches a gitignore style pattern.
+//
+// "*", "?" and "[...]" match within a path segment and "**" matches any number of segments.
+// A pattern without a slash matches at any depth, a leading slash anchors it to the root and a
+// trailing slash matches directories only. A path also matches if one of its parent directories does,
+// so "secrets/" covers every file below it.
+func MatchGlob(pattern string, name string) bool {
+	segments := strings.Split(strings.Trim(name, "/"), "/")
+	for i := len(segments); i >= 1; i-- {
+		if matchPath(pattern, strings.Join(segments[:i], "/"), i < len(segments)) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchPath matches a single path against a gitignore style pattern, without looking at its parents
+func matchPath(pattern string, name string, isDir bool) bool {
+	if strings.HasSuffix(pattern, "/") {
+		if !isDir {
+			return false
+		}
+		pattern = strings.TrimSuffix(pattern, "/")
+	}
+	anchored := strings.Contains(pattern, "/")
+	pattern = strings.TrimPrefix(pattern, "/")
+	if pattern == "" {
+		return false
+	}
+
+	patternSegments := strings.Split(pattern, "/")
+	if !anchored {
+		patternSegments = append([]string{"**"}, patternSegments...)
+	}
+	return matchSegments(patternSegments, strings.Split(strings.Trim(name, "/"), "/"))
+}
+
+func matchSegments(pattern []string, name []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(pattern[1:], name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pattern[0], name[0]); !ok {
+			return false
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0
+}
@@ -0,0 +1,226 @@
+package fileutil
+
+import (
+	"bufio"
+	"code_assistant/src/config"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// IgnoreFileName is read in every directory like .gitignore, its rules take precedence over .gitignore
+const IgnoreFileName = ".codeassistantignore"
+
+// DefaultExcludes are skipped unless an ignore file re-includes them with "!"
+var DefaultExcludes = []string{
+	".git/", ".hg/", ".svn/",
+	"node_modules/", "bower_components/", "vendor/", "third_party/",
+	"dist/", "build/", "out/", "target/", "bin/", "obj/",
+	"__pycache__/", ".venv/", "venv/", ".tox/", ".mypy_cache/",
+	".idea/", ".vscode/", ".next/", "coverage/",
+	"*.min.js", "*.pb.go", "*_pb2.py",
+}
+
+// generatedPattern matches the standard markers code generators put at the top of a file: the Go convention
+// "// Code generated by protoc-gen-go. DO NOT EDIT." as a whole line, and "@generated" in a comment.
+// Looser phrases like "do not edit" are common in hand-written files and are not matched.
+var generatedPattern = regexp.MustCompile(`^(?://|#) Code generated .* DO NOT EDIT\.$|^\s*(?://|#|/\*|\*).*@generated\b`)
+
+// generatedHeaderLines is how far from the top a generated marker is looked for
+const generatedHeaderLines = 20
+
+type ignoreRule struct {
+	pattern string
+	negate  bool
+}
+
+// Ignorer decides which paths below a root are skipped, with gitignore semantics: .gitignore files in
+// every directory, negations, .git/info/exclude, .codeassistantignore files and DefaultExcludes.
+// Rules of deeper directories take precedence, and the last matching rule of a file wins.
+type Ignorer struct {
+	root           string
+	defaultRules   []ignoreRule
+	excludeRules   []ignoreRule
+	skipGenerated  bool
+	mu             sync.Mutex
+	directoryRules map[string][]ignoreRule // relative slash path of a directory -> its rules
+}
+
+// NewIgnorer creates an Ignorer for the repository containing directory, or for directory itself
+// outside of a repository. Built-in defaults and generated file detection follow the configuration.
+func NewIgnorer(directory string) *Ignorer {
+	abs, err := filepath.Abs(directory)
+	if err != nil {
+		abs = directory
+	}
+	root := findRepoRoot(abs)
+	if root == "" {
+		root = abs
+	}
+
+	ig := &Ignorer{root: root, skipGenerated: config.AppConfig.Scan.SkipGenerated, directoryRules: map[string][]ignoreRule{}}
+	if config.AppConfig.Scan.DefaultExcludes {
+		for _, pattern := range DefaultExcludes {
+			ig.defaultRules = append(ig.defaultRules, ignoreRule{pattern: pattern})
+		}
+	}
+	ig.excludeRules = readIgnoreFile(filepath.Join(root, ".git", "info", "exclude"))
+	return ig
+}
+
+// findRepoRoot returns the closest directory above dir containing .git, or an empty string
+func findRepoRoot(dir string) string {
+	for {
+		if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// readIgnoreFile parses a gitignore style file, a missing file has no rules
+func readIgnoreFile(path string) []ignoreRule {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var rules []ignoreRule
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		rule := ignoreRule{}
+		if strings.HasPrefix(line, "!") {
+			rule.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+			line = line[1:]
+		}
+		if line == "" {
+			continue
+		}
+		rule.pattern = line
+		rules = append(rules, rule)
+	}
+	return rules
+}
+
+// rulesOf returns the rules of the ignore files in a directory, relative to the root
+func (ig *Ignorer) rulesOf(dir string) []ignoreRule {
+	ig.mu.Lock()
+	defer ig.mu.Unlock()
+	if rules, ok := ig.directoryRules[dir]; ok {
+		return rules
+	}
+	absDir := filepath.Join(ig.root, filepath.FromSlash(dir))
+	rules := append(readIgnoreFile(filepath.Join(absDir, ".gitignore")), readIgnoreFile(filepath.Join(absDir, IgnoreFileName))...)
+	ig.directoryRules[dir] = rules
+	return rules
+}
+
+// match applies the rules to a single path, without looking at its parent directories
+func (ig *Ignorer) match(rel string, isDir bool) bool {
+	ignored := false
+	apply := func(base string, rules []ignoreRule) {
+		name := rel
+		if base != "" {
+			name = strings.TrimPrefix(rel, base+"/")
+		}
+		for _, r := range rules {
+			if matchPath(r.pattern, name, isDir) {
+				ignored = !r.negate
+			}
+		}
+	}
+
+	apply("", ig.defaultRules)
+	apply("", ig.excludeRules)
+	segments := strings.Split(rel, "/")
+	for i := 0; i < len(segments); i++ {
+		dir := strings.Join(segments[:i], "/")
+		apply(dir, ig.rulesOf(dir))
+	}
+	return ignored
+}
+
+// relative returns path relative to the root, false if it is outside of it
+func (ig *Ignorer) relative(path string) (string, bool) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(ig.root, abs)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return filepath.ToSlash(rel), true
+}
+
+// Ignored reports whether path, or one of the directories containing it, is ignored.
+func (ig *Ignorer) Ignored(path string, isDir bool) bool {
+	rel, ok := ig.relative(path)
+	if !ok {
+		return false
+	}
+	segments := strings.Split(rel, "/")
+	for i := 1; i < len(segments); i++ {
+		if ig.match(strings.Join(segments[:i], "/"), true) {
+			return true
+		}
+	}
+	return ig.match(rel, isDir)
+}
+
+// Skip reports whether a file should not be scanned: it is ignored, or generated and generated files are skipped.
+func (ig *Ignorer) Skip(path string) bool {
+	return ig.Ignored(path, false) || (ig.skipGenerated && IsGenerated(path))
+}
+
+// IsGenerated reports whether the top of a file carries a code generator marker.
+func IsGenerated(path string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	var header []string
+	scanner := bufio.NewScanner(f)
+	for i := 0; i < generatedHeaderLines && scanner.Scan(); i++ {
+		header = append(header, scanner.Text())
+	}
+	return IsGeneratedContent(header)
+}
+
+// IsGeneratedContent reports whether the first lines of a file carry a code generator marker.
+func IsGeneratedContent(lines []string) bool {
+	for i := 0; i < generatedHeaderLines && i < len(lines); i++ {
+		if generatedPattern.MatchString(lines[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDefaultExcludedDir reports whether a directory name is one of the built-in exclusions
+func IsDefaultExcludedDir(name string) bool {
+	if !config.AppConfig.Scan.DefaultExcludes {
+		return false
+	}
+	for _, pattern := range DefaultExcludes {
+		if strings.HasSuffix(pattern, "/") && strings.TrimSuffix(pattern, "/") == name {
+			return true
+		}
+	}
+	return false
+}
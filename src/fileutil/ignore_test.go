@@ -0,0 +1,133 @@
+package fileutil
+
+import (
+	"code_assistant/src/config"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsGeneratedContent(t *testing.T) {
+	tests := []struct {
+		line string
+		want bool
+	}{
+		{"// Code generated by protoc-gen-go. DO NOT EDIT.", true},
+		{"// Code generated by mockery v2.20.0. DO NOT EDIT.", true},
+		{"# Code generated by tool. DO NOT EDIT.", true},
+		{"// @generated SignedSource<<abc>>", true},
+		{" * @generated", true},
+		{"/* This file is @generated by cargo */", true},
+		{"# @generated by pip-compile", true},
+		{"// Do not edit the routes below without updating docs/api.md", false},
+		{"// DO NOT EDIT: keep in sync with the server", false},
+		{"// autogenerated ids are used as keys", false},
+		{"// auto-generated documentation lives in docs/", false},
+		{"// code generated by hand. do not edit.", false},
+		{"x := 1 // Code generated by tool. DO NOT EDIT.", false},
+		{"// Code generated by tool. DO NOT EDIT. Or do.", false},
+		{`s := "@generated"`, false},
+	}
+	for _, tt := range tests {
+		if got := IsGeneratedContent([]string{"package a", tt.line}); got != tt.want {
+			t.Errorf("IsGeneratedContent(%q) = %t, want %t", tt.line, got, tt.want)
+		}
+	}
+
+	// only the header is looked at
+	lines := make([]string, generatedHeaderLines)
+	lines = append(lines, "// Code generated by tool. DO NOT EDIT.")
+	if IsGeneratedContent(lines) {
+		t.Errorf("IsGeneratedContent() found a marker below line %d", generatedHeaderLines)
+	}
+}
+
+func TestMatchPath(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		isDir   bool
+		want    bool
+	}{
+		{"*.log", "a.log", false, true},
+		{"*.log", "dir/sub/a.log", false, true},
+		{"build/", "build", true, true},
+		{"build/", "build", false, false},
+		{"build/", "src/build", true, true},
+		{"/build", "build", true, true},
+		{"/build", "src/build", true, false},
+		{"doc/*.txt", "doc/a.txt", false, true},
+		{"doc/*.txt", "src/doc/a.txt", false, false},
+		{"doc/*.txt", "doc/sub/a.txt", false, false},
+		{"**/foo", "foo", false, true},
+		{"**/foo", "a/b/foo", false, true},
+		{"a/**/b", "a/b", false, true},
+		{"a/**/b", "a/x/y/b", false, true},
+		{"a/**/b", "x/a/b", false, false},
+		{"a/**", "a/x/y", false, true},
+	}
+	for _, tt := range tests {
+		if got := matchPath(tt.pattern, tt.name, tt.isDir); got != tt.want {
+			t.Errorf("matchPath(%q, %q, %t) = %t, want %t", tt.pattern, tt.name, tt.isDir, got, tt.want)
+		}
+	}
+}
+
+func TestIgnorer(t *testing.T) {
+	config.AppConfig.Scan.DefaultExcludes = true
+	config.AppConfig.Scan.SkipGenerated = true
+
+	root := t.TempDir()
+	write := func(rel string, content string) {
+		path := filepath.Join(root, filepath.FromSlash(rel))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(root, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	write(".gitignore", "*.log\n!keep.log\n/top.go\ngen/\n")
+	write("src/.gitignore", "local.go\n!debug.log\n")
+	write("src/deep/.codeassistantignore", "!local.go\n")
+	write("vendor/.gitignore", "")
+	write("third_party/.codeassistantignore", "")
+	write(".codeassistantignore", "!third_party/\n")
+
+	tests := []struct {
+		rel  string
+		want bool
+	}{
+		{"a.log", true},
+		{"keep.log", false},
+		{"src/debug.log", false},
+		{"src/other.log", true},
+		{"top.go", true},
+		{"src/top.go", false},
+		{"gen/a.go", true},
+		{"src/local.go", true},
+		{"src/sub/local.go", true},
+		{"src/deep/local.go", false},
+		{"vendor/a.go", true},
+		{"third_party/a.go", false},
+		{"src/main.go", false},
+	}
+	ig := NewIgnorer(root)
+	for _, tt := range tests {
+		if got := ig.Ignored(filepath.Join(root, filepath.FromSlash(tt.rel)), false); got != tt.want {
+			t.Errorf("Ignored(%s) = %t, want %t", tt.rel, got, tt.want)
+		}
+	}
+
+	write("src/gen.go", "// Code generated by stringer. DO NOT EDIT.\n\npackage src\n")
+	write("src/routes.go", "// Do not edit the routes below without updating docs/api.md\n\npackage src\n")
+	if !ig.Skip(filepath.Join(root, "src", "gen.go")) {
+		t.Error("Skip(src/gen.go) = false, want true")
+	}
+	if ig.Skip(filepath.Join(root, "src", "routes.go")) {
+		t.Error("Skip(src/routes.go) = true, want false")
+	}
+}
@@ -0,0 +1,173 @@
+package fileutil
+
+import (
+	"bytes"
+	"code_assistant/src/config"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Reasons a file is not ingested
+const (
+	SkipTooLarge    = "too_large"
+	SkipBinary      = "binary"
+	SkipLongLine    = "line_too_long"
+	SkipSymlink     = "symlink"
+	SkipSymlinkLoop = "symlink_loop"
+	SkipUnreadable  = "unreadable"
+)
+
+// SkippedFile is a file that was found but not read, with the reason why
+type SkippedFile struct {
+	Path   string
+	Reason string
+	Detail string
+}
+
+// binarySniffSize is how many bytes are looked at to tell text from binary content
+const binarySniffSize = 8000
+
+// CheckFile reports why a file must not be ingested, or nil when it can be read as text.
+// Symbolic links are only followed when configured, and files above the size limit are not read.
+func CheckFile(path string) *SkippedFile {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return &SkippedFile{Path: path, Reason: SkipUnreadable, Detail: err.Error()}
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		if !config.AppConfig.Scan.FollowSymlinks {
+			return &SkippedFile{Path: path, Reason: SkipSymlink, Detail: "symbolic links are not followed"}
+		}
+		if info, err = os.Stat(path); err != nil {
+			return &SkippedFile{Path: path, Reason: SkipUnreadable, Detail: err.Error()}
+		}
+	}
+	if maxSize := config.AppConfig.Scan.MaxFileBytes; maxSize > 0 && info.Size() > maxSize {
+		return &SkippedFile{Path: path, Reason: SkipTooLarge, Detail: fmt.Sprintf("%d bytes, the limit is %d", info.Size(), maxSize)}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return &SkippedFile{Path: path, Reason: SkipUnreadable, Detail: err.Error()}
+	}
+	return CheckContent(path, data)
+}
+
+// CheckContent reports why the content of a file must not be ingested, or nil when it is text within the limits.
+func CheckContent(path string, data []byte) *SkippedFile {
+	text, ok := DecodeText(data)
+	if !ok {
+		return &SkippedFile{Path: path, Reason: SkipBinary, Detail: "content is not text"}
+	}
+	if maxLength := config.AppConfig.Scan.MaxLineLength; maxLength > 0 {
+		for idx, line := range SplitLines(text) {
+			if length := utf8.RuneCountInString(line); length > maxLength {
+				return &SkippedFile{Path: path, Reason: SkipLongLine, Detail: fmt.Sprintf("line %d has %d characters, the limit is %d", idx+1, length, maxLength)}
+			}
+		}
+	}
+	return nil
+}
+
+// DecodeText converts file content to UTF-8. UTF-16 is recognized by its byte order mark or by
+// the zero bytes of ASCII characters, content that is not valid UTF-8 is read as Latin-1.
+// Only the encoding changes, line breaks stay where they are so line numbers keep pointing
+// at the same lines. False is returned for binary content.
+func DecodeText(data []byte) (string, bool) {
+	switch {
+	case bytes.HasPrefix(data, []byte{0xEF, 0xBB, 0xBF}):
+		data = data[3:]
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return decodeUTF16(data[2:], binary.LittleEndian), true
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return decodeUTF16(data[2:], binary.BigEndian), true
+	}
+
+	if order, ok := guessUTF16(data); ok {
+		return decodeUTF16(data, order), true
+	}
+	sniff := data[:min(len(data), binarySniffSize)]
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return "", false
+	}
+	if utf8.Valid(data) {
+		return string(data), true
+	}
+	if controlRatio(sniff) > 0.1 {
+		return "", false
+	}
+	return decodeLatin1(data), true
+}
+
+// guessUTF16 detects UTF-16 without a byte order mark: mostly ASCII text has a zero in every other byte
+func guessUTF16(data []byte) (binary.ByteOrder, bool) {
+	sniff := data[:min(len(data), binarySniffSize)]
+	if len(sniff) < 4 || len(sniff)%2 != 0 {
+		return nil, false
+	}
+	evenZeros, oddZeros := 0, 0
+	for i := 0; i < len(sniff); i += 2 {
+		if sniff[i] == 0 {
+			evenZeros++
+		}
+		if sniff[i+1] == 0 {
+			oddZeros++
+		}
+	}
+	pairs := len(sniff) / 2
+	switch {
+	case oddZeros*10 >= pairs*9 && evenZeros == 0:
+		return binary.LittleEndian, true
+	case evenZeros*10 >= pairs*9 && oddZeros == 0:
+		return binary.BigEndian, true
+	}
+	return nil, false
+}
+
+func decodeUTF16(data []byte, order binary.ByteOrder) string {
+	units := make([]uint16, 0, len(data)/2)
+	for i := 0; i+1 < len(data); i += 2 {
+		units = append(units, order.Uint16(data[i:]))
+	}
+	return string(utf16.Decode(units))
+}
+
+func decodeLatin1(data []byte) string {
+	var b strings.Builder
+	b.Grow(len(data) * 2)
+	for _, c := range data {
+		b.WriteRune(rune(c))
+	}
+	return b.String()
+}
+
+// controlRatio returns the share of control characters other than whitespace
+func controlRatio(data []byte) float64 {
+	if len(data) == 0 {
+		return 0
+	}
+	control := 0
+	for _, c := range data {
+		if c < 0x20 && c != '\n' && c != '\r' && c != '\t' && c != '\f' && c != '\v' {
+			control++
+		}
+	}
+	return float64(control) / float64(len(data))
+}
+
+// SplitLines splits text at line breaks, "\r\n" counts as one break and a trailing break adds no empty line.
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	text = strings.TrimSuffix(text, "\n")
+	lines := strings.Split(text, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimSuffix(line, "\r")
+	}
+	return lines
+}
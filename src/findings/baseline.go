@@ -0,0 +1,64 @@
+package findings
+
+import (
+	"encoding/json"
+	"os"
+)
+
+const baselineVersion = 1
+
+// Baseline is a file of accepted findings, identified by fingerprint, that reports leave out
+type Baseline struct {
+	Version  int             `json:"version"`
+	Findings []BaselineEntry `json:"findings"`
+
+	fingerprints map[string]bool
+}
+
+// BaselineEntry keeps the rule and location next to the fingerprint so the file can be reviewed
+type BaselineEntry struct {
+	Fingerprint string `json:"fingerprint"`
+	RuleId      string `json:"rule_id"`
+	FilePath    string `json:"file"`
+	Function    string `json:"function,omitempty"`
+	Message     string `json:"message"`
+}
+
+// LoadBaseline reads a baseline file written by WriteBaseline.
+func LoadBaseline(path string) (*Baseline, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b Baseline
+	if err := json.Unmarshal(content, &b); err != nil {
+		return nil, err
+	}
+	b.fingerprints = map[string]bool{}
+	for _, e := range b.Findings {
+		b.fingerprints[e.Fingerprint] = true
+	}
+	return &b, nil
+}
+
+// Contains reports whether a finding with the given fingerprint is in the baseline.
+func (b *Baseline) Contains(fingerprint string) bool {
+	return b != nil && fingerprint != "" && b.fingerprints[fingerprint]
+}
+
+// WriteBaseline writes the fingerprints of findings to path, findings without a fingerprint are left out.
+func WriteBaseline(path string, findings []Finding) error {
+	b := Baseline{Version: baselineVersion, Findings: []BaselineEntry{}}
+	for _, f := range findings {
+		if f.Fingerprint == "" {
+			continue
+		}
+		b.Findings = append(b.Findings, BaselineEntry{Fingerprint: f.Fingerprint, RuleId: f.RuleId, FilePath: f.FilePath,
+			Function: f.Function, Message: f.Message})
+	}
+	content, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
@@ -0,0 +1,65 @@
+package findings
+
+import (
+	"sort"
+)
+
+// Severity of a finding, from "none" for passed checks to "high"
+type Severity string
+
+const (
+	SeverityNone   Severity = "none"
+	SeverityLow    Severity = "low"
+	SeverityMedium Severity = "medium"
+	SeverityHigh   Severity = "high"
+)
+
+// Rule describes a kind of finding
+type Rule struct {
+	Id          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Finding is a problem reported by one of the analyses, located by file path and 1-based inclusive lines
+type Finding struct {
+	RuleId     string   `json:"rule_id"`
+	FilePath   string   `json:"file"`
+	LineStart  int      `json:"line_start"`
+	LineEnd    int      `json:"line_end"`
+	Function   string   `json:"function,omitempty"`
+	Severity   Severity `json:"severity"`
+	Message    string   `json:"message"`
+	Suggestion string   `json:"suggestion,omitempty"`
+	// Confidence of the model between 0 and 1, 0 if the analysis does not report one
+	Confidence float64 `json:"confidence,omitempty"`
+	// Fingerprint identifies the finding across runs and line moves
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Suppression is SuppressionInSource or SuppressionExternal for findings that were accepted
+	Suppression string `json:"suppression,omitempty"`
+}
+
+// Kinds of suppression, named like the SARIF suppression kinds
+const (
+	SuppressionInSource = "inSource"
+	SuppressionExternal = "external"
+)
+
+// ParseSeverity maps a severity reported by the model to a Severity, unknown values become defaultSeverity.
+func ParseSeverity(severity string, defaultSeverity Severity) Severity {
+	switch s := Severity(severity); s {
+	case SeverityNone, SeverityLow, SeverityMedium, SeverityHigh:
+		return s
+	}
+	return defaultSeverity
+}
+
+// Sort orders findings by file and line.
+func Sort(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].FilePath != findings[j].FilePath {
+			return findings[i].FilePath < findings[j].FilePath
+		}
+		return findings[i].LineStart < findings[j].LineStart
+	})
+}
@@ -0,0 +1,362 @@
+package findings
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v5"
+)
+
+const (
+	sarifVersion = "2.1.0"
+	// id of the official schema, the copy in schema/ is the OASIS 2.1.0 errata 01 version
+	sarifSchema = "https://docs.oasis-open.org/sarif/sarif/v2.1.0/errata01/os/schemas/sarif-schema-2.1.0.json"
+	// uriBaseId of paths relative to the source root
+	srcRoot = "%SRCROOT%"
+	// partialFingerprints key of Finding.Fingerprint
+	fingerprintKey = "codeAssistantFingerprint/v1"
+)
+
+//go:embed schema/sarif-schema-2.1.0.json
+var sarifSchemaJSON []byte
+
+var (
+	compileSchemaOnce sync.Once
+	compiledSchema    *jsonschema.Schema
+	compileSchemaErr  error
+)
+
+// SARIF 2.1.0 object model, limited to the properties written by this tool
+
+type SarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []SarifRun `json:"runs"`
+}
+
+type SarifRun struct {
+	Tool               SarifTool                        `json:"tool"`
+	OriginalUriBaseIds map[string]SarifArtifactLocation `json:"originalUriBaseIds,omitempty"`
+	Results            []SarifResult                    `json:"results"`
+}
+
+type SarifTool struct {
+	Driver SarifDriver `json:"driver"`
+}
+
+type SarifDriver struct {
+	Name           string                     `json:"name"`
+	Version        string                     `json:"version,omitempty"`
+	InformationUri string                     `json:"informationUri,omitempty"`
+	Rules          []SarifReportingDescriptor `json:"rules,omitempty"`
+}
+
+type SarifReportingDescriptor struct {
+	Id                   string                  `json:"id"`
+	Name                 string                  `json:"name,omitempty"`
+	ShortDescription     *SarifMessage           `json:"shortDescription,omitempty"`
+	DefaultConfiguration *SarifRuleConfiguration `json:"defaultConfiguration,omitempty"`
+}
+
+type SarifRuleConfiguration struct {
+	Level string `json:"level"`
+}
+
+type SarifMessage struct {
+	Text string `json:"text"`
+}
+
+type SarifResult struct {
+	RuleId              string                 `json:"ruleId"`
+	RuleIndex           int                    `json:"ruleIndex"`
+	Level               string                 `json:"level"`
+	Message             SarifMessage           `json:"message"`
+	Locations           []SarifLocation        `json:"locations"`
+	PartialFingerprints map[string]string      `json:"partialFingerprints,omitempty"`
+	Suppressions        []SarifSuppression     `json:"suppressions,omitempty"`
+	Properties          map[string]interface{} `json:"properties,omitempty"`
+}
+
+type SarifSuppression struct {
+	Kind string `json:"kind"`
+}
+
+type SarifLocation struct {
+	PhysicalLocation SarifPhysicalLocation  `json:"physicalLocation"`
+	LogicalLocations []SarifLogicalLocation `json:"logicalLocations,omitempty"`
+}
+
+type SarifPhysicalLocation struct {
+	ArtifactLocation SarifArtifactLocation `json:"artifactLocation"`
+	Region           *SarifRegion          `json:"region,omitempty"`
+}
+
+type SarifArtifactLocation struct {
+	Uri       string `json:"uri"`
+	UriBaseId string `json:"uriBaseId,omitempty"`
+}
+
+type SarifRegion struct {
+	StartLine int `json:"startLine"`
+	EndLine   int `json:"endLine,omitempty"`
+}
+
+type SarifLogicalLocation struct {
+	Name string `json:"name"`
+	Kind string `json:"kind,omitempty"`
+}
+
+// SarifOptions configures the SARIF writer
+type SarifOptions struct {
+	ToolName    string
+	ToolVersion string
+	// BaseDir makes file paths under it relative to %SRCROOT%, other paths are written as file URIs
+	BaseDir string
+	// Rules describes the rule ids used by the findings, unknown ids get a descriptor with the id only
+	Rules []Rule
+}
+
+// SarifLevel maps a severity to a SARIF result level.
+func SarifLevel(severity Severity) string {
+	switch severity {
+	case SeverityHigh:
+		return "error"
+	case SeverityMedium:
+		return "warning"
+	case SeverityLow:
+		return "note"
+	}
+	return "none"
+}
+
+// ToSarif converts findings into a SARIF log with a single run.
+func ToSarif(findings []Finding, opts SarifOptions) *SarifLog {
+	run := SarifRun{
+		Tool:    SarifTool{Driver: SarifDriver{Name: opts.ToolName, Version: opts.ToolVersion}},
+		Results: []SarifResult{},
+	}
+	if opts.BaseDir != "" {
+		run.OriginalUriBaseIds = map[string]SarifArtifactLocation{srcRoot: {Uri: fileUri(opts.BaseDir) + "/"}}
+	}
+
+	known := map[string]Rule{}
+	for _, r := range opts.Rules {
+		known[r.Id] = r
+	}
+	ruleIndex := map[string]int{}
+	for _, f := range findings {
+		idx, ok := ruleIndex[f.RuleId]
+		if !ok {
+			r, ok := known[f.RuleId]
+			if !ok {
+				r = Rule{Id: f.RuleId}
+			}
+			descriptor := SarifReportingDescriptor{Id: r.Id, Name: r.Name}
+			if r.Description != "" {
+				descriptor.ShortDescription = &SarifMessage{Text: r.Description}
+			}
+			idx = len(run.Tool.Driver.Rules)
+			ruleIndex[f.RuleId] = idx
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, descriptor)
+		}
+
+		message := f.Message
+		if f.Suggestion != "" {
+			message += "\nSuggestion: " + f.Suggestion
+		}
+		location := SarifLocation{PhysicalLocation: SarifPhysicalLocation{ArtifactLocation: artifactLocation(f.FilePath, opts.BaseDir)}}
+		if f.LineStart > 0 {
+			region := &SarifRegion{StartLine: f.LineStart}
+			if f.LineEnd > f.LineStart {
+				region.EndLine = f.LineEnd
+			}
+			location.PhysicalLocation.Region = region
+		}
+		if f.Function != "" {
+			location.LogicalLocations = []SarifLogicalLocation{{Name: f.Function, Kind: "function"}}
+		}
+		result := SarifResult{RuleId: f.RuleId, RuleIndex: idx, Level: SarifLevel(f.Severity),
+			Message: SarifMessage{Text: message}, Locations: []SarifLocation{location}}
+		if f.Fingerprint != "" {
+			result.PartialFingerprints = map[string]string{fingerprintKey: f.Fingerprint}
+		}
+		if f.Suppression != "" {
+			result.Suppressions = []SarifSuppression{{Kind: f.Suppression}}
+		}
+		if f.Confidence > 0 {
+			result.Properties = map[string]interface{}{"confidence": f.Confidence}
+		}
+		run.Results = append(run.Results, result)
+	}
+
+	return &SarifLog{Schema: sarifSchema, Version: sarifVersion, Runs: []SarifRun{run}}
+}
+
+// WriteSarif validates the SARIF log of findings against the SARIF 2.1.0 schema and the constraints listed at
+// CheckConstraints, and writes it as indented JSON.
+func WriteSarif(w io.Writer, findings []Finding, opts SarifOptions) error {
+	log := ToSarif(findings, opts)
+	if err := log.CheckConstraints(); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(log, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ValidateSarif(data); err != nil {
+		return err
+	}
+	_, err = w.Write(append(data, '\n'))
+	return err
+}
+
+// ValidateSarif validates a SARIF document against the embedded SARIF 2.1.0 JSON schema.
+func ValidateSarif(data []byte) error {
+	compileSchemaOnce.Do(func() {
+		compiler := jsonschema.NewCompiler()
+		if err := compiler.AddResource(sarifSchema, bytes.NewReader(sarifSchemaJSON)); err != nil {
+			compileSchemaErr = err
+			return
+		}
+		compiledSchema, compileSchemaErr = compiler.Compile(sarifSchema)
+	})
+	if compileSchemaErr != nil {
+		return fmt.Errorf("sarif: failed to compile the schema: %v", compileSchemaErr)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return fmt.Errorf("sarif: %v", err)
+	}
+	if err := compiledSchema.Validate(doc); err != nil {
+		return fmt.Errorf("sarif: %v", err)
+	}
+	return nil
+}
+
+// artifactLocation returns the location of path, relative to %SRCROOT% when it is inside baseDir
+func artifactLocation(path string, baseDir string) SarifArtifactLocation {
+	if baseDir != "" {
+		rel, err := filepath.Rel(baseDir, path)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel) {
+			return SarifArtifactLocation{Uri: escapePath(filepath.ToSlash(rel)), UriBaseId: srcRoot}
+		}
+	}
+	if filepath.IsAbs(path) {
+		return SarifArtifactLocation{Uri: fileUri(path)}
+	}
+	return SarifArtifactLocation{Uri: escapePath(filepath.ToSlash(path))}
+}
+
+func fileUri(path string) string {
+	path = filepath.ToSlash(filepath.Clean(path))
+	if !strings.HasPrefix(path, "/") {
+		// windows drive letter
+		path = "/" + path
+	}
+	return "file://" + escapePath(strings.TrimSuffix(path, "/"))
+}
+
+// escapePath percent-encodes each segment of a slash separated path
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return strings.Join(segments, "/")
+}
+
+// CheckConstraints checks the constraints of the SARIF 2.1.0 specification that its JSON schema cannot express,
+// and the required properties, for the properties this tool writes:
+//   - version is 2.1.0 and runs is present
+//   - every run has tool.driver.name, and its rules have an id that is unique within the run
+//   - originalUriBaseIds are absolute URIs ending with a slash
+//   - every result has message.text, a level of none, note, warning or error, a ruleIndex of -1 or
+//     within the rules, and the ruleId of the rule at ruleIndex
+//   - suppression kinds are inSource or external
+//   - locations have a parseable uri, a uriBaseId defined by the run, a startLine of at least 1
+//     and no endLine before startLine
+func (l *SarifLog) CheckConstraints() error {
+	if l.Version != sarifVersion {
+		return fmt.Errorf("sarif: version must be %s", sarifVersion)
+	}
+	if l.Runs == nil {
+		return fmt.Errorf("sarif: runs is required")
+	}
+	for r, run := range l.Runs {
+		driver := run.Tool.Driver
+		if driver.Name == "" {
+			return fmt.Errorf("sarif: runs[%d].tool.driver.name is required", r)
+		}
+		ids := map[string]bool{}
+		for i, rule := range driver.Rules {
+			if rule.Id == "" {
+				return fmt.Errorf("sarif: runs[%d].tool.driver.rules[%d].id is required", r, i)
+			}
+			if ids[rule.Id] {
+				return fmt.Errorf("sarif: runs[%d].tool.driver.rules[%d].id %q is not unique", r, i, rule.Id)
+			}
+			ids[rule.Id] = true
+		}
+		for baseId, base := range run.OriginalUriBaseIds {
+			u, err := url.Parse(base.Uri)
+			if err != nil || !u.IsAbs() || !strings.HasSuffix(base.Uri, "/") {
+				return fmt.Errorf("sarif: runs[%d].originalUriBaseIds[%s] must be an absolute URI ending with a slash", r, baseId)
+			}
+		}
+		for i, res := range run.Results {
+			path := fmt.Sprintf("runs[%d].results[%d]", r, i)
+			if res.Message.Text == "" {
+				return fmt.Errorf("sarif: %s.message.text is required", path)
+			}
+			switch res.Level {
+			case "none", "note", "warning", "error":
+			default:
+				return fmt.Errorf("sarif: %s.level %q is invalid", path, res.Level)
+			}
+			if res.RuleIndex < -1 || res.RuleIndex >= len(driver.Rules) {
+				return fmt.Errorf("sarif: %s.ruleIndex %d is out of range", path, res.RuleIndex)
+			}
+			if res.RuleIndex >= 0 && driver.Rules[res.RuleIndex].Id != res.RuleId {
+				return fmt.Errorf("sarif: %s.ruleId %q does not match rule %d", path, res.RuleId, res.RuleIndex)
+			}
+			for j, s := range res.Suppressions {
+				if s.Kind != SuppressionInSource && s.Kind != SuppressionExternal {
+					return fmt.Errorf("sarif: %s.suppressions[%d].kind %q is invalid", path, j, s.Kind)
+				}
+			}
+			for j, loc := range res.Locations {
+				artifact := loc.PhysicalLocation.ArtifactLocation
+				if artifact.Uri == "" {
+					return fmt.Errorf("sarif: %s.locations[%d] has no uri", path, j)
+				}
+				if _, err := url.Parse(artifact.Uri); err != nil {
+					return fmt.Errorf("sarif: %s.locations[%d].uri is invalid: %v", path, j, err)
+				}
+				if artifact.UriBaseId != "" {
+					if _, ok := run.OriginalUriBaseIds[artifact.UriBaseId]; !ok {
+						return fmt.Errorf("sarif: %s.locations[%d].uriBaseId %s is not defined", path, j, artifact.UriBaseId)
+					}
+				}
+				if region := loc.PhysicalLocation.Region; region != nil {
+					if region.StartLine < 1 {
+						return fmt.Errorf("sarif: %s.locations[%d].region.startLine must be at least 1", path, j)
+					}
+					if region.EndLine != 0 && region.EndLine < region.StartLine {
+						return fmt.Errorf("sarif: %s.locations[%d].region.endLine is before startLine", path, j)
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package findings
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var testOptions = SarifOptions{ToolName: "code_assistant", ToolVersion: "0.1.0", BaseDir: "/src/proj",
+	Rules: []Rule{{Id: "review/bug", Name: "Bug", Description: "A bug found by the review"}, {Id: "drift", Name: "Drift"}}}
+
+func TestToSarifRuleIndex(t *testing.T) {
+	log := ToSarif([]Finding{
+		{RuleId: "drift", FilePath: "/src/proj/a.go", LineStart: 1, Severity: SeverityLow, Message: "m"},
+		{RuleId: "review/bug", FilePath: "/src/proj/a.go", LineStart: 2, Severity: SeverityHigh, Message: "m"},
+		{RuleId: "drift", FilePath: "/src/proj/b.go", LineStart: 3, Severity: SeverityLow, Message: "m"},
+		{RuleId: "security/unknown", FilePath: "/src/proj/b.go", LineStart: 4, Severity: SeverityMedium, Message: "m"},
+	}, testOptions)
+
+	run := log.Runs[0]
+	wantRules := []SarifReportingDescriptor{
+		{Id: "drift", Name: "Drift"},
+		{Id: "review/bug", Name: "Bug", ShortDescription: &SarifMessage{Text: "A bug found by the review"}},
+		{Id: "security/unknown"},
+	}
+	if !reflect.DeepEqual(run.Tool.Driver.Rules, wantRules) {
+		t.Errorf("rules = %+v, want %+v", run.Tool.Driver.Rules, wantRules)
+	}
+	for i, want := range []int{0, 1, 0, 2} {
+		res := run.Results[i]
+		if res.RuleIndex != want || run.Tool.Driver.Rules[res.RuleIndex].Id != res.RuleId {
+			t.Errorf("results[%d] ruleId %s ruleIndex = %d, want %d", i, res.RuleId, res.RuleIndex, want)
+		}
+	}
+	if err := log.CheckConstraints(); err != nil {
+		t.Error(err)
+	}
+
+	run.Results[2].RuleIndex = 1
+	if err := log.CheckConstraints(); err == nil {
+		t.Error("CheckConstraints() accepted a ruleIndex pointing at another rule")
+	}
+}
+
+func TestToSarifSuppressions(t *testing.T) {
+	log := ToSarif([]Finding{
+		{RuleId: "r", FilePath: "a.go", Message: "open"},
+		{RuleId: "r", FilePath: "a.go", Message: "inline", Suppression: SuppressionInSource},
+		{RuleId: "r", FilePath: "a.go", Message: "baseline", Suppression: SuppressionExternal},
+	}, SarifOptions{ToolName: "t"})
+
+	want := [][]SarifSuppression{nil, {{Kind: "inSource"}}, {{Kind: "external"}}}
+	for i, res := range log.Runs[0].Results {
+		if !reflect.DeepEqual(res.Suppressions, want[i]) {
+			t.Errorf("results[%d].suppressions = %+v, want %+v", i, res.Suppressions, want[i])
+		}
+	}
+
+	log.Runs[0].Results[1].Suppressions[0].Kind = "accepted"
+	if err := log.CheckConstraints(); err == nil {
+		t.Error("CheckConstraints() accepted an unknown suppression kind")
+	}
+}
+
+func TestArtifactLocation(t *testing.T) {
+	tests := []struct {
+		path    string
+		baseDir string
+		want    SarifArtifactLocation
+	}{
+		{"/src/proj/a.go", "/src/proj", SarifArtifactLocation{Uri: "a.go", UriBaseId: "%SRCROOT%"}},
+		{"/src/proj/sub dir/c#1.go", "/src/proj", SarifArtifactLocation{Uri: "sub%20dir/c%231.go", UriBaseId: "%SRCROOT%"}},
+		{"/src/proj/..hidden/d.go", "/src/proj", SarifArtifactLocation{Uri: "..hidden/d.go", UriBaseId: "%SRCROOT%"}},
+		{"/src/project/e.go", "/src/proj", SarifArtifactLocation{Uri: "file:///src/project/e.go"}},
+		{"/src/e.go", "/src/proj", SarifArtifactLocation{Uri: "file:///src/e.go"}},
+		{"/tmp/100%/f.go", "", SarifArtifactLocation{Uri: "file:///tmp/100%25/f.go"}},
+		{"rel/g h.go", "", SarifArtifactLocation{Uri: "rel/g%20h.go"}},
+	}
+	for _, tt := range tests {
+		if got := artifactLocation(tt.path, tt.baseDir); got != tt.want {
+			t.Errorf("artifactLocation(%q, %q) = %+v, want %+v", tt.path, tt.baseDir, got, tt.want)
+		}
+	}
+
+	log := ToSarif(nil, SarifOptions{ToolName: "t", BaseDir: "/src/my proj/"})
+	if got := log.Runs[0].OriginalUriBaseIds[srcRoot].Uri; got != "file:///src/my%20proj/" {
+		t.Errorf("originalUriBaseIds[%s] = %q, want file:///src/my%%20proj/", srcRoot, got)
+	}
+}
+
+func TestToSarifRegion(t *testing.T) {
+	tests := []struct {
+		lineStart int
+		lineEnd   int
+		want      *SarifRegion
+	}{
+		{0, 0, nil},
+		{5, 0, &SarifRegion{StartLine: 5}},
+		{5, 5, &SarifRegion{StartLine: 5}},
+		{5, 3, &SarifRegion{StartLine: 5}},
+		{5, 9, &SarifRegion{StartLine: 5, EndLine: 9}},
+	}
+	for _, tt := range tests {
+		log := ToSarif([]Finding{{RuleId: "r", FilePath: "a.go", LineStart: tt.lineStart, LineEnd: tt.lineEnd, Message: "m"}}, SarifOptions{ToolName: "t"})
+		got := log.Runs[0].Results[0].Locations[0].PhysicalLocation.Region
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("lines %d-%d: region = %+v, want %+v", tt.lineStart, tt.lineEnd, got, tt.want)
+		}
+	}
+}
+
+// TestWriteSarifSchema validates the written logs against the embedded SARIF 2.1.0 schema
+func TestWriteSarifSchema(t *testing.T) {
+	all := []Finding{
+		{RuleId: "review/bug", FilePath: "/src/proj/sub dir/a.go", LineStart: 3, LineEnd: 7, Function: "F", Severity: SeverityHigh,
+			Message: "m", Suggestion: "s", Confidence: 0.8, Fingerprint: "abc", Suppression: SuppressionInSource},
+		{RuleId: "drift", FilePath: "/elsewhere/b.go", Severity: SeverityNone, Message: "m", Suppression: SuppressionExternal},
+		{RuleId: "unknown", FilePath: "rel/c.go", LineStart: 1, Severity: SeverityLow, Message: "m"},
+	}
+	for name, findings := range map[string][]Finding{"empty": nil, "all properties": all} {
+		var buf bytes.Buffer
+		if err := WriteSarif(&buf, findings, testOptions); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if !strings.Contains(buf.String(), `"$schema": "`+sarifSchema+`"`) {
+			t.Errorf("%s: log does not reference the schema", name)
+		}
+	}
+
+	invalid := map[string]string{
+		"wrong version":    `{"version": "2.0.0", "runs": []}`,
+		"unknown level":    `{"version": "2.1.0", "runs": [{"tool": {"driver": {"name": "t"}}, "results": [{"message": {"text": "m"}, "level": "fatal"}]}]}`,
+		"no message":       `{"version": "2.1.0", "runs": [{"tool": {"driver": {"name": "t"}}, "results": [{"ruleId": "r"}]}]}`,
+		"start line 0":     `{"version": "2.1.0", "runs": [{"tool": {"driver": {"name": "t"}}, "results": [{"message": {"text": "m"}, "locations": [{"physicalLocation": {"artifactLocation": {"uri": "a.go"}, "region": {"startLine": 0}}}]}]}]}`,
+		"unknown property": `{"version": "2.1.0", "runs": [], "extra": 1}`,
+	}
+	for name, doc := range invalid {
+		if err := ValidateSarif([]byte(doc)); err == nil {
+			t.Errorf("%s: ValidateSarif() accepted an invalid log", name)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package gitutil
+
+import (
+	"bytes"
+	"code_assistant/src/diffutil"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// run executes git in dir and returns its standard output.
+// Only the local repository is read, no command used here talks to a remote.
+func run(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
+
+// RepoRoot returns the top level directory of the repository containing dir.
+func RepoRoot(dir string) (string, error) {
+	out, err := run(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return "", err
+	}
+	return filepath.Clean(strings.TrimSpace(out)), nil
+}
+
+// ResolveCommit returns the full SHA of the commit rev refers to.
+func ResolveCommit(dir string, rev string) (string, error) {
+	out, err := run(dir, "rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("unknown revision %s", rev)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// IsDirty reports whether the working tree copy of path differs from HEAD.
+func IsDirty(dir string, path string) bool {
+	out, err := run(dir, "status", "--porcelain", "--", path)
+	return err == nil && strings.TrimSpace(out) != ""
+}
+
+// FileRevision returns the commit a working tree file corresponds to, suffixed with "+dirty"
+// when it has uncommitted changes. It returns an empty string outside of a git repository.
+func FileRevision(path string) string {
+	dir := filepath.Dir(path)
+	head, err := ResolveCommit(dir, "HEAD")
+	if err != nil {
+		return ""
+	}
+	if IsDirty(dir, path) {
+		return head + "+dirty"
+	}
+	return head
+}
+
+// Diff returns the parsed diff of rangeSpec in the repository at dir, with paths made absolute.
+//
+// rangeSpec is passed to "git diff" as is, e.g. "main...HEAD" for the changes of a branch,
+// or a single commit to compare it with the working tree.
+func Diff(dir string, rangeSpec string) ([]diffutil.FileDiff, error) {
+	root, err := RepoRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	out, err := run(root, "diff", "--unified=0", "--no-color", "--no-ext-diff", "--no-renames", rangeSpec, "--")
+	if err != nil {
+		return nil, err
+	}
+	files, err := diffutil.Parse(out)
+	if err != nil {
+		return nil, err
+	}
+	for i := range files {
+		if files[i].OldPath != "" {
+			files[i].OldPath = filepath.Join(root, filepath.FromSlash(files[i].OldPath))
+		}
+		if files[i].NewPath != "" {
+			files[i].NewPath = filepath.Join(root, filepath.FromSlash(files[i].NewPath))
+		}
+	}
+	return files, nil
+}
+
+// RangeEnd returns the revision on the new side of rangeSpec, e.g. "HEAD" for "main...HEAD".
+// It returns false when the new side is the working tree, as for a single commit.
+func RangeEnd(rangeSpec string) (string, bool) {
+	for _, sep := range []string{"...", ".."} {
+		if i := strings.Index(rangeSpec, sep); i >= 0 {
+			end := rangeSpec[i+len(sep):]
+			if end == "" {
+				end = "HEAD"
+			}
+			return end, true
+		}
+	}
+	return "", false
+}
+
+// RangeBase returns the revision on the old side of rangeSpec: the merge base for "A...B",
+// A for "A..B" and the commit itself for a single commit. An empty side means HEAD.
+func RangeBase(dir string, rangeSpec string) (string, error) {
+	if from, to, found := strings.Cut(rangeSpec, "..."); found {
+		if from == "" {
+			from = "HEAD"
+		}
+		if to == "" {
+			to = "HEAD"
+		}
+		out, err := run(dir, "merge-base", from, to)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(out), nil
+	}
+	if from, _, found := strings.Cut(rangeSpec, ".."); found {
+		if from == "" {
+			from = "HEAD"
+		}
+		return from, nil
+	}
+	return rangeSpec, nil
+}
+
+// MatchesRevision reports whether the working tree copy of the absolute path has the same content as at rev.
+func MatchesRevision(root string, rev string, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return false
+	}
+	committed, err := ShowFile(root, rev, rel)
+	if err != nil {
+		return false
+	}
+	data, err := os.ReadFile(path)
+	return err == nil && string(data) == committed
+}
+
+// ListFiles returns the repository relative paths of all files in the tree of rev.
+func ListFiles(dir string, rev string) ([]string, error) {
+	out, err := run(dir, "ls-tree", "-r", "-z", "--name-only", rev)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, path := range strings.Split(out, "\x00") {
+		if path != "" {
+			files = append(files, path)
+		}
+	}
+	return files, nil
+}
+
+// ShowFile returns the content of a repository relative path at rev.
+func ShowFile(dir string, rev string, path string) (string, error) {
+	return run(dir, "show", rev+":"+filepath.ToSlash(path))
+}
+
+// CommitDate returns the committer date of rev in RFC 3339 format.
+func CommitDate(dir string, rev string) (string, error) {
+	out, err := run(dir, "show", "-s", "--format=%cI", rev)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(out), nil
+}
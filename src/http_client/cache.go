@@ -0,0 +1,174 @@
+package http_client
+
+import (
+	"code_assistant/src/config"
+	"code_assistant/src/db"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/url"
+	"time"
+)
+
+// cacheProvider names the API the cached answers come from, part of the cache key
+const cacheProvider = "ollama"
+
+// DefaultOptions returns the model options sent with every request, nil if none are configured
+func DefaultOptions() *Options {
+	if config.AppConfig.Ollama.Seed == 0 {
+		return nil
+	}
+	return &Options{Seed: config.AppConfig.Ollama.Seed}
+}
+
+// cacheKey returns the content address of a request: a hash of the provider, the endpoint and the request body
+// without the server address, so the same question to another server with the same model is a hit as well.
+// The body carries the model, its options, the system prompt and the full message list.
+func cacheKey(endpoint string, reqBody []byte) (string, string) {
+	path := endpoint
+	if u, err := url.Parse(endpoint); err == nil {
+		path = u.Path
+	}
+
+	// canonical form: keys are sorted when a map is marshalled
+	var fields map[string]any
+	if err := json.Unmarshal(reqBody, &fields); err == nil {
+		delete(fields, "url")
+		if canonical, err := json.Marshal(fields); err == nil {
+			reqBody = canonical
+		}
+	}
+	hash := sha256.New()
+	hash.Write([]byte(cacheProvider + "\x00" + path + "\x00"))
+	hash.Write(reqBody)
+	return hex.EncodeToString(hash.Sum(nil)), path
+}
+
+// cachedPost answers a request from the cache, or sends it and caches a successful answer.
+// It reports whether the answer came from the cache.
+func cachedPost(endpoint string, model string, reqBody []byte) ([]byte, bool, error) {
+	if !config.AppConfig.Cache.Enabled || db.GetDatabase() == nil {
+		body, err := post(endpoint, reqBody)
+		return body, false, err
+	}
+
+	key, path := cacheKey(endpoint, reqBody)
+	if !config.AppConfig.Cache.Bypass {
+		if body, ok := lookupCache(key); ok {
+			return body, true, nil
+		}
+	}
+
+	body, err := post(endpoint, reqBody)
+	if err != nil {
+		return body, false, err
+	}
+	var usage callUsage
+	if json.Unmarshal(body, &usage) == nil && usage.Error == "" {
+		storeCache(key, path, model, body)
+	}
+	return body, false, nil
+}
+
+// lookupCache returns the cached answer of a request unless it expired
+func lookupCache(key string) ([]byte, bool) {
+	var body string
+	var created time.Time
+	err := db.GetDatabase().QueryRow("SELECT response, created_datetime FROM llm_cache WHERE key = ?", key).Scan(&body, &created)
+	if err != nil {
+		return nil, false
+	}
+	if ttl := config.AppConfig.Cache.TTL; ttl > 0 && time.Since(created) > ttl {
+		return nil, false
+	}
+	db.GetDatabase().Execute("UPDATE llm_cache SET hits = hits + 1, last_used_datetime = ? WHERE key = ?", time.Now(), key)
+	return []byte(body), true
+}
+
+// storeCache caches an answer and drops the least recently used ones above the size limit
+func storeCache(key string, endpoint string, model string, body []byte) {
+	now := time.Now()
+	db.GetDatabase().Execute(`INSERT INTO llm_cache (key, provider, endpoint, model, response, size, hits, created_datetime, last_used_datetime) VALUES (?, ?, ?, ?, ?, ?, 0, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET response = excluded.response, size = excluded.size, created_datetime = excluded.created_datetime, last_used_datetime = excluded.last_used_datetime`,
+		key, cacheProvider, endpoint, model, string(body), len(body), now, now)
+
+	maxBytes := config.AppConfig.Cache.MaxBytes
+	if maxBytes <= 0 {
+		return
+	}
+	var total int64
+	if err := db.GetDatabase().QueryRow("SELECT COALESCE(SUM(size), 0) FROM llm_cache").Scan(&total); err != nil || total <= maxBytes {
+		return
+	}
+	rows, err := db.GetDatabase().Query("SELECT key, size FROM llm_cache ORDER BY last_used_datetime")
+	if err != nil {
+		log.Printf("Failed to evict cached answers: %v", err)
+		return
+	}
+	var evict []string
+	for rows.Next() && total > maxBytes {
+		var k string
+		var size int64
+		if err := rows.Scan(&k, &size); err != nil {
+			break
+		}
+		evict = append(evict, k)
+		total -= size
+	}
+	rows.Close()
+	for _, k := range evict {
+		db.GetDatabase().Execute("DELETE FROM llm_cache WHERE key = ?", k)
+	}
+}
+
+// CacheStats describes the cached answers of a model
+type CacheStats struct {
+	Model   string
+	Entries int
+	Bytes   int64
+	Hits    int
+	Expired int
+}
+
+// ListCacheStats returns the size of the cache per model
+func ListCacheStats() ([]CacheStats, error) {
+	expiredBefore := time.Time{}
+	if ttl := config.AppConfig.Cache.TTL; ttl > 0 {
+		expiredBefore = time.Now().Add(-ttl)
+	}
+	rows, err := db.GetDatabase().Query(`SELECT model, COUNT(*), COALESCE(SUM(size), 0), COALESCE(SUM(hits), 0), COALESCE(SUM(created_datetime < ?), 0)
+		FROM llm_cache GROUP BY model ORDER BY model`, expiredBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []CacheStats
+	for rows.Next() {
+		var s CacheStats
+		if err := rows.Scan(&s.Model, &s.Entries, &s.Bytes, &s.Hits, &s.Expired); err != nil {
+			return nil, err
+		}
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// ClearCache removes the cached answers of model, or of all models if it is empty.
+// With expiredOnly only the answers older than the TTL are removed.
+func ClearCache(model string, expiredOnly bool) (int64, error) {
+	expiredBefore := time.Now().Add(time.Hour) // every entry
+	if expiredOnly {
+		ttl := config.AppConfig.Cache.TTL
+		if ttl <= 0 {
+			return 0, nil
+		}
+		expiredBefore = time.Now().Add(-ttl)
+	}
+	result, err := db.GetDatabase().Execute("DELETE FROM llm_cache WHERE (? = '' OR model = ?) AND created_datetime < ?", model, model, expiredBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,306 @@
+package http_client
+
+import (
+	"bytes"
+	"code_assistant/src/config"
+	"code_assistant/src/db"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Trace tells what a call to the model was made for, it is recorded with the call
+type Trace struct {
+	// FilePath defaults to the first source path of the request
+	FilePath     string
+	FunctionName string
+	// Step is the prompt of the call, e.g. "LocateFunctionDefition"
+	Step string
+	// ScanId is the scan the call was made for, 0 outside of a scan
+	ScanId int64
+}
+
+// Usage is the work a reply reports, the durations are measured by the server
+type Usage struct {
+	PromptTokens       int           `json:"prompt_eval_count"`
+	CompletionTokens   int           `json:"eval_count"`
+	LoadDuration       time.Duration `json:"load_duration"`
+	PromptEvalDuration time.Duration `json:"prompt_eval_duration"`
+	EvalDuration       time.Duration `json:"eval_duration"`
+}
+
+// Call is a request to the model and its reply, as stored in the llm_calls table.
+// Request and reply are stored as they were sent and received, with sensitive values still masked.
+type Call struct {
+	Id          int
+	Endpoint    string
+	Model       string
+	Options     string
+	Request     string
+	Reply       string
+	RawResponse string
+	Usage
+	// DurationMs is the wall time of the call, as measured by the client
+	DurationMs   int64
+	Error        string
+	FilePath     string
+	FunctionName string
+	Step         string
+	ScanId       int64
+	// SourcePaths and MetadataPaths are the files the prompt was built from, NoFiles tells that it used none.
+	// A replay is checked against the policy with them.
+	SourcePaths   []string
+	MetadataPaths []string
+	NoFiles       bool
+	// ReplayOf is the id of the call this one replays, 0 for calls made by a scan or a command
+	ReplayOf int
+	// Cached tells that the answer came from the response cache, the model was not asked
+	Cached          bool
+	CreatedDatetime string
+}
+
+// callUsage is the part of an Ollama reply describing the call
+type callUsage struct {
+	Usage
+	Error string `json:"error"`
+}
+
+// post sends a JSON body to url and returns the body of the response
+func post(url string, reqBody []byte) ([]byte, error) {
+	// Create a context with timeout
+	ctx, cancel := context.WithTimeout(context.Background(), HTTP_TIMEOUT_SEC*time.Second)
+	defer cancel() // Ensure cancel is called to release resources
+
+	// Create HTTP client with timeout
+	client := &http.Client{
+		Timeout: HTTP_TIMEOUT_SEC * time.Second,
+	}
+
+	// Create a new request with the context
+	httpRequest, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(reqBody))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create HTTP request: %v", err)
+	}
+
+	// Set content type header
+	httpRequest.Header.Set("Content-Type", "application/json")
+
+	// Send the HTTP request
+	resp, err := client.Do(httpRequest)
+	if err != nil {
+		// Check if the error is due to a timeout
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, fmt.Errorf("request timed out: %v", err)
+		}
+		return nil, fmt.Errorf("failed to send HTTP request: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// Read response body
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	return body, nil
+}
+
+// newCall returns the record of a call about to be sent
+func newCall(url string, model string, options *Options, trace Trace, sourcePaths []string, metadataPaths []string, noFiles bool, reqBody []byte) Call {
+	c := Call{Endpoint: url, Model: model, Request: string(reqBody), FilePath: trace.FilePath, FunctionName: trace.FunctionName, Step: trace.Step,
+		ScanId: trace.ScanId, SourcePaths: sourcePaths, MetadataPaths: metadataPaths, NoFiles: noFiles}
+	if c.FilePath == "" && len(sourcePaths) > 0 {
+		c.FilePath = sourcePaths[0]
+	}
+	if options != nil {
+		if data, err := json.Marshal(options); err == nil {
+			c.Options = string(data)
+		}
+	}
+	return c
+}
+
+// recordCall completes the record of a sent call with its reply and stores it, unless recording is disabled.
+// Token counts, durations and an error reported by the server are read from the raw response.
+func recordCall(c Call, start time.Time, respBody []byte, reply string, callErr error) Call {
+	c.DurationMs = time.Since(start).Milliseconds()
+	c.CreatedDatetime = start.Format(time.RFC3339Nano)
+	c.RawResponse = string(respBody)
+	c.Reply = reply
+
+	var usage callUsage
+	if len(respBody) > 0 && json.Unmarshal(respBody, &usage) == nil {
+		c.Usage = usage.Usage
+		c.Error = usage.Error
+	}
+	if callErr != nil {
+		c.Error = callErr.Error()
+	}
+
+	if !config.AppConfig.Ollama.RecordCalls || db.GetDatabase() == nil {
+		return c
+	}
+	result, err := db.GetDatabase().Execute(`INSERT INTO llm_calls (endpoint, model, options, request, reply, raw_response, prompt_tokens, completion_tokens,
+		load_duration, prompt_eval_duration, eval_duration, duration_ms, error, file_path, function_name, step, scan_id, source_paths, metadata_paths, no_files,
+		replay_of, cached, created_datetime)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		c.Endpoint, c.Model, c.Options, c.Request, c.Reply, c.RawResponse, c.PromptTokens, c.CompletionTokens,
+		c.LoadDuration, c.PromptEvalDuration, c.EvalDuration, c.DurationMs, c.Error, c.FilePath, c.FunctionName, c.Step, c.ScanId,
+		strings.Join(c.SourcePaths, "\n"), strings.Join(c.MetadataPaths, "\n"), c.NoFiles, c.ReplayOf, c.Cached, start)
+	if err == nil {
+		if id, err := result.LastInsertId(); err == nil {
+			c.Id = int(id)
+		}
+	}
+	return c
+}
+
+// CallFilter selects recorded calls, empty fields match every call
+type CallFilter struct {
+	FilePath     string
+	FunctionName string
+	Model        string
+	Step         string
+	ErrorsOnly   bool
+	// Limit is the number of most recent calls returned, 0 for all
+	Limit int
+}
+
+const callColumns = `id, endpoint, model, options, request, reply, raw_response, prompt_tokens, completion_tokens,
+	load_duration, prompt_eval_duration, eval_duration, duration_ms, error, file_path, function_name, step, scan_id, source_paths, metadata_paths, no_files,
+	replay_of, cached, created_datetime`
+
+func scanCall(row interface{ Scan(...any) error }) (Call, error) {
+	var c Call
+	var sourcePaths, metadataPaths string
+	err := row.Scan(&c.Id, &c.Endpoint, &c.Model, &c.Options, &c.Request, &c.Reply, &c.RawResponse, &c.PromptTokens, &c.CompletionTokens,
+		&c.LoadDuration, &c.PromptEvalDuration, &c.EvalDuration, &c.DurationMs, &c.Error, &c.FilePath, &c.FunctionName, &c.Step, &c.ScanId,
+		&sourcePaths, &metadataPaths, &c.NoFiles, &c.ReplayOf, &c.Cached, &c.CreatedDatetime)
+	c.SourcePaths = splitPaths(sourcePaths)
+	c.MetadataPaths = splitPaths(metadataPaths)
+	return c, err
+}
+
+// splitPaths reverses the newline separated list of paths stored with a call
+func splitPaths(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(s, "\n")
+}
+
+// ListCalls returns the recorded calls matching filter, most recent first
+func ListCalls(filter CallFilter) ([]Call, error) {
+	query := `SELECT ` + callColumns + ` FROM llm_calls
+		WHERE (? = '' OR file_path = ?) AND (? = '' OR function_name = ?) AND (? = '' OR model = ?) AND (? = '' OR step = ?) AND (? = 0 OR error != '')
+		ORDER BY id DESC`
+	args := []any{filter.FilePath, filter.FilePath, filter.FunctionName, filter.FunctionName, filter.Model, filter.Model, filter.Step, filter.Step, filter.ErrorsOnly}
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+	rows, err := db.GetDatabase().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var calls []Call
+	for rows.Next() {
+		c, err := scanCall(rows)
+		if err != nil {
+			return nil, err
+		}
+		calls = append(calls, c)
+	}
+	return calls, rows.Err()
+}
+
+// GetCall returns a recorded call by id
+func GetCall(id int) (Call, error) {
+	c, err := scanCall(db.GetDatabase().QueryRow(`SELECT `+callColumns+` FROM llm_calls WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return c, fmt.Errorf("no call with id %d", id)
+	}
+	return c, err
+}
+
+// Prompt returns the system prompt and the messages of the request as text
+func (c Call) Prompt() string {
+	var req struct {
+		System   string `json:"system"`
+		Prompt   string `json:"prompt"`
+		Messages []Chat `json:"messages"`
+	}
+	if err := json.Unmarshal([]byte(c.Request), &req); err != nil {
+		return c.Request
+	}
+	var b strings.Builder
+	if req.System != "" {
+		fmt.Fprintf(&b, "[system]\n%s\n\n", req.System)
+	}
+	if req.Prompt != "" {
+		fmt.Fprintf(&b, "[prompt]\n%s\n\n", req.Prompt)
+	}
+	for _, m := range req.Messages {
+		fmt.Fprintf(&b, "[%s]\n%s\n\n", m.Role, m.Content)
+	}
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// replyText extracts the answer from the raw response of any endpoint, embeddings have no text
+func replyText(respBody []byte) string {
+	var resp struct {
+		Message  Chat   `json:"message"`
+		Response string `json:"response"`
+	}
+	if json.Unmarshal(respBody, &resp) != nil {
+		return ""
+	}
+	if resp.Response != "" {
+		return resp.Response
+	}
+	return resp.Message.Content
+}
+
+// Replay sends a recorded call again, to model instead of the recorded one if it is not empty, and records it as a new call.
+// The request is sent as it was recorded, after checking the files it was built from against the current policy,
+// and masked values in the reply are not restored. The response cache is not used, the point of a replay is a fresh answer.
+func Replay(id int, model string) (Call, error) {
+	original, err := GetCall(id)
+	if err != nil {
+		return Call{}, err
+	}
+	sourcePaths := original.SourcePaths
+	if len(sourcePaths) == 0 && len(original.MetadataPaths) == 0 && !original.NoFiles && original.FilePath != "" {
+		// recorded before the paths were, the file of the call is all there is to check
+		sourcePaths = []string{original.FilePath}
+	}
+	if err := checkPolicy(sourcePaths, original.MetadataPaths, original.NoFiles); err != nil {
+		return Call{}, fmt.Errorf("call %d is not replayed: %v", id, err)
+	}
+
+	var req map[string]any
+	if err := json.Unmarshal([]byte(original.Request), &req); err != nil {
+		return Call{}, fmt.Errorf("call %d has no JSON request: %v", id, err)
+	}
+	if model == "" {
+		model = original.Model
+	}
+	req["model"] = model
+	reqBody, err := json.Marshal(req)
+	if err != nil {
+		return Call{}, fmt.Errorf("failed to marshal request JSON: %v", err)
+	}
+
+	c := Call{Endpoint: original.Endpoint, Model: model, Options: original.Options, Request: string(reqBody),
+		FilePath: original.FilePath, FunctionName: original.FunctionName, Step: original.Step,
+		SourcePaths: sourcePaths, MetadataPaths: original.MetadataPaths, NoFiles: original.NoFiles, ReplayOf: id}
+	start := time.Now()
+	respBody, err := post(original.Endpoint, reqBody)
+	return recordCall(c, start, respBody, replyText(respBody), err), err
+}
@@ -1,14 +1,10 @@
 package http_client
 
 import (
-	"bytes"
 	"code_assistant/src/config"
 	"code_assistant/src/llm_prompt"
-	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"time"
 )
 
@@ -18,21 +14,37 @@ type Chat struct {
 	Content string `json:"content"`
 }
 
+// Options are model parameters passed through to Ollama
+type Options struct {
+	Seed int `json:"seed,omitempty"`
+}
+
 // Request struct represents the input data for ChatGenerateRemote request
 type ChatRequest struct {
-	URL         string  `json:"url"`
-	Model       string  `json:"model"`
-	Temperature float64 `json:"temperature"`
-	Top_p       float64 `json:"top_p"`
-	Messages    []Chat  `json:"messages"`
-	Stream      bool    `json:"stream"`
-	System      string  `json:"system"`
+	URL         string   `json:"url"`
+	Model       string   `json:"model"`
+	Temperature float64  `json:"temperature"`
+	Top_p       float64  `json:"top_p"`
+	Messages    []Chat   `json:"messages"`
+	Stream      bool     `json:"stream"`
+	System      string   `json:"system"`
+	Options     *Options `json:"options,omitempty"`
+
+	// Files whose source code, or only names and signatures, are part of the prompt, checked against the policy
+	SourcePaths   []string `json:"-"`
+	MetadataPaths []string `json:"-"`
+	// NoFiles declares a prompt built without anything from a file, requests naming no files are denied otherwise
+	NoFiles bool `json:"-"`
+	// What the call is made for, recorded with it
+	Trace Trace `json:"-"`
 }
 
 // Response struct represents the output data from ChatGenerateRemote response
 type ChatResponse struct {
 	Result Chat  `json:"message"`
 	Token  int32 `json:"eval_count"`
+	// Usage is the work the server reports for the call
+	Usage Usage `json:"-"`
 }
 
 // Create a ChatGenerateRemote request with default value
@@ -45,6 +57,7 @@ func NewChatRequest() ChatRequest {
 		Stream:      false,
 		Messages:    []Chat{},
 		System:      llm_prompt.SystemPrompt(),
+		Options:     DefaultOptions(),
 	}
 	return req
 }
@@ -52,6 +65,17 @@ func NewChatRequest() ChatRequest {
 // ChatGenerateRemote sends a POST request to the remote server with the provided data
 // It takes a ChatRequest struct as input and returns a ChatResponse struct
 func ChatGenerateRemote(req ChatRequest) (ChatResponse, error) {
+	if err := checkPolicy(req.SourcePaths, req.MetadataPaths, req.NoFiles); err != nil {
+		return ChatResponse{}, err
+	}
+
+	// Mask secrets before the prompt leaves the process, the caller keeps its own copy of the messages
+	messages := make([]Chat, len(req.Messages))
+	for i, m := range req.Messages {
+		messages[i] = Chat{Role: m.Role, Content: redactText(req.URL, m.Content)}
+	}
+	req.Messages = messages
+	req.System = redactText(req.URL, req.System)
 
 	// Convert ChatRequest struct to JSON
 	reqBody, err := json.MarshalIndent(req, "", "  ")
@@ -61,46 +85,23 @@ func ChatGenerateRemote(req ChatRequest) (ChatResponse, error) {
 
 	// fmt.Println(string(reqBody)) //DEBUG
 
-	// Create a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), HTTP_TIMEOUT_SEC*time.Second)
-	defer cancel() // Ensure cancel is called to release resources
-
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: HTTP_TIMEOUT_SEC * time.Second,
-	}
-
-	// Create a new request with the context
-	httpRequest, err := http.NewRequestWithContext(ctx, "POST", req.URL, bytes.NewBuffer(reqBody))
-	if err != nil {
-		return ChatResponse{}, fmt.Errorf("failed to create HTTP request: %v", err)
-	}
+	call := newCall(req.URL, req.Model, req.Options, req.Trace, req.SourcePaths, req.MetadataPaths, req.NoFiles, reqBody)
+	start := time.Now()
+	body, cached, err := cachedPost(req.URL, req.Model, reqBody)
+	call.Cached = cached
 
-	// Set content type header
-	httpRequest.Header.Set("Content-Type", "application/json")
-
-	// Send the HTTP request
-	resp, err := client.Do(httpRequest)
-	if err != nil {
-		// Check if the error is due to a timeout
-		if ctx.Err() == context.DeadlineExceeded {
-			return ChatResponse{}, fmt.Errorf("request timed out: %v", err)
+	// Unmarshal response JSON into Response struct
+	var response ChatResponse
+	if err == nil {
+		if err = json.Unmarshal(body, &response); err != nil {
+			err = fmt.Errorf("failed to unmarshal response JSON: %v", err)
 		}
-		return ChatResponse{}, fmt.Errorf("failed to send HTTP request: %v", err)
 	}
-	defer resp.Body.Close()
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	response.Usage = recordCall(call, start, body, response.Result.Content, err).Usage
 	if err != nil {
-		return ChatResponse{}, fmt.Errorf("failed to read response body: %v", err)
-	}
-
-	// Unmarshal response JSON into Response struct
-	var response ChatResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return ChatResponse{}, fmt.Errorf("failed to unmarshal response JSON: %v", err)
+		return ChatResponse{}, err
 	}
+	response.Result.Content = restoreText(response.Result.Content)
 
 	return response, nil
 }
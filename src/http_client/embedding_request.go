@@ -1,13 +1,9 @@
 package http_client
 
 import (
-	"bytes"
 	"code_assistant/src/config"
-	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"time"
 )
 
@@ -16,11 +12,21 @@ type EmbeddingRequest struct {
 	URL    string `json:"url"`
 	Model  string `json:"model"`
 	Prompt string `json:"prompt"`
+
+	// Files whose source code, or only names and signatures, are part of the prompt, checked against the policy
+	SourcePaths   []string `json:"-"`
+	MetadataPaths []string `json:"-"`
+	// NoFiles declares a prompt built without anything from a file, requests naming no files are denied otherwise
+	NoFiles bool `json:"-"`
+	// What the call is made for, recorded with it
+	Trace Trace `json:"-"`
 }
 
 // Response struct represents the output data from EmbeddingGenerateRemote response
 type EmbeddingResponse struct {
 	Result []float32 `json:"embedding"`
+	// Usage is the work the server reports for the call
+	Usage Usage `json:"-"`
 }
 
 // Create a ChatGenerateRemote request with default value
@@ -36,51 +42,33 @@ func NewEmbeddingRequest() EmbeddingRequest {
 // EmbeddingGenerateRemote sends a POST request to the remote server with the provided data
 // It takes a EmbeddingRequest struct as input and returns a EmbeddingResponse struct
 func EmbeddingGenerateRemote(req EmbeddingRequest) (EmbeddingResponse, error) {
+	if err := checkPolicy(req.SourcePaths, req.MetadataPaths, req.NoFiles); err != nil {
+		return EmbeddingResponse{}, err
+	}
+	// Mask secrets before the text leaves the process
+	req.Prompt = redactText(req.URL, req.Prompt)
+
 	// Convert EmbeddingRequest struct to JSON
 	reqBody, err := json.Marshal(req)
 	if err != nil {
 		return EmbeddingResponse{}, fmt.Errorf("failed to marshal request JSON: %v", err)
 	}
 
-	// Create a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), HTTP_TIMEOUT_SEC*time.Second)
-	defer cancel() // Ensure cancel is called to release resources
-
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: HTTP_TIMEOUT_SEC * time.Second,
-	}
-
-	// Create a new request with the context
-	httpRequest, err := http.NewRequestWithContext(ctx, "POST", req.URL, bytes.NewBuffer(reqBody))
-	if err != nil {
-		return EmbeddingResponse{}, fmt.Errorf("failed to create HTTP request: %v", err)
-	}
-
-	// Set content type header
-	httpRequest.Header.Set("Content-Type", "application/json")
+	call := newCall(req.URL, req.Model, nil, req.Trace, req.SourcePaths, req.MetadataPaths, req.NoFiles, reqBody)
+	start := time.Now()
+	body, cached, err := cachedPost(req.URL, req.Model, reqBody)
+	call.Cached = cached
 
-	// Send the HTTP request
-	resp, err := client.Do(httpRequest)
-	if err != nil {
-		// Check if the error is due to a timeout
-		if ctx.Err() == context.DeadlineExceeded {
-			return EmbeddingResponse{}, fmt.Errorf("request timed out: %v", err)
+	// Unmarshal response JSON into Response struct
+	var response EmbeddingResponse
+	if err == nil {
+		if err = json.Unmarshal(body, &response); err != nil {
+			err = fmt.Errorf("failed to unmarshal response JSON: %v", err)
 		}
-		return EmbeddingResponse{}, fmt.Errorf("failed to send HTTP request: %v", err)
 	}
-	defer resp.Body.Close()
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	response.Usage = recordCall(call, start, body, "", err).Usage
 	if err != nil {
-		return EmbeddingResponse{}, fmt.Errorf("failed to read response body: %v", err)
-	}
-
-	// Unmarshal response JSON into Response struct
-	var response EmbeddingResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return EmbeddingResponse{}, fmt.Errorf("failed to unmarshal response JSON: %v", err)
+		return EmbeddingResponse{}, err
 	}
 
 	return response, nil
@@ -0,0 +1,16 @@
+package http_client
+
+import (
+	"code_assistant/src/policy"
+	"fmt"
+)
+
+// checkPolicy returns an error if a request may not be sent under the current policy.
+// A request must name the files its prompt was built from, or declare that it was built without any,
+// so a caller that forgets the paths is denied instead of sending unchecked source code.
+func checkPolicy(sourcePaths []string, metadataPaths []string, noFiles bool) error {
+	if len(sourcePaths) == 0 && len(metadataPaths) == 0 && !noFiles {
+		return fmt.Errorf("policy: the request names no files, set SourcePaths or MetadataPaths, or NoFiles if the prompt has nothing from a file")
+	}
+	return policy.Current().Check(sourcePaths, metadataPaths)
+}
@@ -0,0 +1,61 @@
+package http_client
+
+import (
+	"code_assistant/src/config"
+	"code_assistant/src/db"
+	"code_assistant/src/redact"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	redactor     *redact.Redactor
+	redactorOnce sync.Once
+)
+
+// getRedactor returns the process wide redactor, or nil if redaction is disabled
+func getRedactor() *redact.Redactor {
+	if !config.AppConfig.Redaction.Enabled {
+		return nil
+	}
+	redactorOnce.Do(func() {
+		patterns := redact.DefaultPatterns()
+		if path := config.AppConfig.Redaction.PatternsFile; path != "" {
+			custom, err := redact.LoadPatterns(path)
+			if err != nil {
+				log.Printf("Failed to load redaction patterns: %v", err)
+			}
+			// custom patterns first, they are usually more specific
+			patterns = append(custom, patterns...)
+		}
+		redactor = redact.New(patterns)
+	})
+	return redactor
+}
+
+// redactText masks sensitive values in text before it is sent to endpoint and records what was masked
+func redactText(endpoint string, text string) string {
+	r := getRedactor()
+	if r == nil || text == "" {
+		return text
+	}
+	redacted, matches := r.Redact(text)
+	if len(matches) > 0 && db.GetDatabase() != nil {
+		now := time.Now()
+		for _, m := range matches {
+			db.GetDatabase().Execute("INSERT INTO redactions (endpoint, kind, placeholder, occurrences, created_datetime) VALUES (?, ?, ?, ?, ?)",
+				endpoint, m.Kind, m.Placeholder, m.Count, now)
+		}
+	}
+	return redacted
+}
+
+// restoreText puts the original values back into an answer
+func restoreText(text string) string {
+	r := getRedactor()
+	if r == nil {
+		return text
+	}
+	return r.Restore(text)
+}
@@ -0,0 +1,108 @@
+package http_client
+
+import (
+	"code_assistant/src/db"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Columns the recorded calls can be grouped by
+const (
+	ByModel = "model"
+	ByStep  = "step"
+	ByFile  = "file_path"
+	ByScan  = "scan_id"
+)
+
+// UsageStats sums up the calls a group of recorded calls sent to the model.
+// Calls answered from the cache and failed calls are left out.
+type UsageStats struct {
+	// Key is the model, step, file or scan id of the group, empty for the totals
+	Key   string
+	Calls int
+	Usage
+	// Wall is the time the client waited for the replies
+	Wall time.Duration
+
+	// tokens of the calls whose reply reported the evaluation time, calls recorded by older versions did not
+	timedPromptTokens     int
+	timedCompletionTokens int
+}
+
+// TokensPerSecond returns the completion tokens generated per second of evaluation
+func (s UsageStats) TokensPerSecond() float64 {
+	if s.EvalDuration <= 0 {
+		return 0
+	}
+	return float64(s.timedCompletionTokens) / s.EvalDuration.Seconds()
+}
+
+// PromptTokensPerSecond returns the prompt tokens read per second of prompt evaluation
+func (s UsageStats) PromptTokensPerSecond() float64 {
+	if s.PromptEvalDuration <= 0 {
+		return 0
+	}
+	return float64(s.timedPromptTokens) / s.PromptEvalDuration.Seconds()
+}
+
+// UsageFilter selects the calls that are summed up, empty fields match every call
+type UsageFilter struct {
+	ScanId int64
+	Model  string
+	// Limit is the number of groups with the longest wall time returned, 0 for all
+	Limit int
+}
+
+// ListUsage sums up the recorded calls per value of groupBy, one of the By constants, longest wall time first.
+// An empty groupBy returns the totals as a single group.
+func ListUsage(groupBy string, filter UsageFilter) ([]UsageStats, error) {
+	key := "''"
+	switch groupBy {
+	case "":
+	case ByModel, ByStep, ByFile, ByScan:
+		key = groupBy
+	default:
+		return nil, fmt.Errorf("cannot group calls by %q", groupBy)
+	}
+
+	query := `SELECT ` + key + `, COUNT(*), COALESCE(SUM(prompt_tokens), 0), COALESCE(SUM(completion_tokens), 0),
+		COALESCE(SUM(load_duration), 0), COALESCE(SUM(prompt_eval_duration), 0), COALESCE(SUM(eval_duration), 0), COALESCE(SUM(duration_ms), 0),
+		COALESCE(SUM(CASE WHEN prompt_eval_duration > 0 THEN prompt_tokens END), 0), COALESCE(SUM(CASE WHEN eval_duration > 0 THEN completion_tokens END), 0)
+		FROM llm_calls WHERE cached = 0 AND error = '' AND (? = 0 OR scan_id = ?) AND (? = '' OR model = ?)`
+	args := []any{filter.ScanId, filter.ScanId, filter.Model, filter.Model}
+	if groupBy != "" {
+		query += ` GROUP BY ` + key + ` ORDER BY SUM(duration_ms) DESC`
+	}
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+	rows, err := db.GetDatabase().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stats []UsageStats
+	for rows.Next() {
+		var s UsageStats
+		var key any
+		var wallMs int64
+		if err := rows.Scan(&key, &s.Calls, &s.PromptTokens, &s.CompletionTokens, &s.LoadDuration, &s.PromptEvalDuration, &s.EvalDuration, &wallMs,
+			&s.timedPromptTokens, &s.timedCompletionTokens); err != nil {
+			return nil, err
+		}
+		switch k := key.(type) {
+		case int64:
+			s.Key = strconv.FormatInt(k, 10)
+		case []byte:
+			s.Key = string(k)
+		case string:
+			s.Key = k
+		}
+		s.Wall = time.Duration(wallMs) * time.Millisecond
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
@@ -1,32 +1,39 @@
 package http_client
 
 import (
-	"bytes"
 	"code_assistant/src/config"
 	"code_assistant/src/llm_prompt"
-	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"net/http"
 	"time"
 )
 
 // Request struct represents the input data for TextGenerateRemote request
 type TextGenRequest struct {
-	URL         string  `json:"url"`
-	Model       string  `json:"model"`
-	Temperature float64 `json:"temperature"`
-	Top_p       float64 `json:"top_p"`
-	Prompt      string  `json:"prompt"`
-	Stream      bool    `json:"stream"`
-	System      string  `json:"system"`
+	URL         string   `json:"url"`
+	Model       string   `json:"model"`
+	Temperature float64  `json:"temperature"`
+	Top_p       float64  `json:"top_p"`
+	Prompt      string   `json:"prompt"`
+	Stream      bool     `json:"stream"`
+	System      string   `json:"system"`
+	Options     *Options `json:"options,omitempty"`
+
+	// Files whose source code, or only names and signatures, are part of the prompt, checked against the policy
+	SourcePaths   []string `json:"-"`
+	MetadataPaths []string `json:"-"`
+	// NoFiles declares a prompt built without anything from a file, requests naming no files are denied otherwise
+	NoFiles bool `json:"-"`
+	// What the call is made for, recorded with it
+	Trace Trace `json:"-"`
 }
 
 // Response struct represents the output data from TextGenerateRemote response
 type TextGenResponse struct {
 	Result string `json:"response"`
 	Token  int32  `json:"eval_count"`
+	// Usage is the work the server reports for the call
+	Usage Usage `json:"-"`
 }
 
 // Create a TextGenerateRemote request with default value
@@ -39,6 +46,7 @@ func NewTextGenRequest() TextGenRequest {
 		Prompt:      "",
 		Stream:      false,
 		System:      llm_prompt.SystemPrompt(),
+		Options:     DefaultOptions(),
 	}
 	return req
 }
@@ -46,6 +54,13 @@ func NewTextGenRequest() TextGenRequest {
 // TextGenerateRemote sends a POST request to the remote server with the provided data
 // It takes a TextGenRequest struct as input and returns a TextGenResponse struct
 func TextGenerateRemote(req TextGenRequest) (TextGenResponse, error) {
+	if err := checkPolicy(req.SourcePaths, req.MetadataPaths, req.NoFiles); err != nil {
+		return TextGenResponse{}, err
+	}
+
+	// Mask secrets before the prompt leaves the process
+	req.Prompt = redactText(req.URL, req.Prompt)
+	req.System = redactText(req.URL, req.System)
 
 	// Convert TextGenRequest struct to JSON
 	reqBody, err := json.Marshal(req)
@@ -55,46 +70,23 @@ func TextGenerateRemote(req TextGenRequest) (TextGenResponse, error) {
 
 	fmt.Println(string(reqBody)) //DEBUG
 
-	// Create a context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), HTTP_TIMEOUT_SEC*time.Second)
-	defer cancel() // Ensure cancel is called to release resources
-
-	// Create HTTP client with timeout
-	client := &http.Client{
-		Timeout: HTTP_TIMEOUT_SEC * time.Second,
-	}
-
-	// Create a new request with the context
-	httpRequest, err := http.NewRequestWithContext(ctx, "POST", req.URL, bytes.NewBuffer(reqBody))
-	if err != nil {
-		return TextGenResponse{}, fmt.Errorf("failed to create HTTP request: %v", err)
-	}
-
-	// Set content type header
-	httpRequest.Header.Set("Content-Type", "application/json")
+	call := newCall(req.URL, req.Model, req.Options, req.Trace, req.SourcePaths, req.MetadataPaths, req.NoFiles, reqBody)
+	start := time.Now()
+	body, cached, err := cachedPost(req.URL, req.Model, reqBody)
+	call.Cached = cached
 
-	// Send the HTTP request
-	resp, err := client.Do(httpRequest)
-	if err != nil {
-		// Check if the error is due to a timeout
-		if ctx.Err() == context.DeadlineExceeded {
-			return TextGenResponse{}, fmt.Errorf("request timed out: %v", err)
+	// Unmarshal response JSON into Response struct
+	var response TextGenResponse
+	if err == nil {
+		if err = json.Unmarshal(body, &response); err != nil {
+			err = fmt.Errorf("failed to unmarshal response JSON: %v", err)
 		}
-		return TextGenResponse{}, fmt.Errorf("failed to send HTTP request: %v", err)
 	}
-	defer resp.Body.Close()
-
-	// Read response body
-	body, err := io.ReadAll(resp.Body)
+	response.Usage = recordCall(call, start, body, response.Result, err).Usage
 	if err != nil {
-		return TextGenResponse{}, fmt.Errorf("failed to read response body: %v", err)
-	}
-
-	// Unmarshal response JSON into Response struct
-	var response TextGenResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return TextGenResponse{}, fmt.Errorf("failed to unmarshal response JSON: %v", err)
+		return TextGenResponse{}, err
 	}
+	response.Result = restoreText(response.Result)
 
 	return response, nil
 }
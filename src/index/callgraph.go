@@ -0,0 +1,80 @@
+package index
+
+import (
+	"code_assistant/src/fileutil"
+	"regexp"
+	"strings"
+)
+
+// CallGraph holds caller/callee relations between indexed functions, keyed by function id
+type CallGraph struct {
+	Callees map[int][]int
+	Callers map[int][]int
+}
+
+var callPattern = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\s*\(`)
+
+// ShortName strips receivers, namespaces and packages from a function name,
+// e.g. "FileAnalyzer.ScanFile" and "ns::Scan" become "ScanFile" and "Scan".
+func ShortName(name string) string {
+	if idx := strings.LastIndexAny(name, ".:"); idx >= 0 {
+		name = name[idx+1:]
+	}
+	return strings.TrimSpace(name)
+}
+
+// BuildCallGraph derives a call graph from the stored line ranges without calling the model.
+//
+// The body of every function is read from disk and scanned for identifiers followed by "(".
+// An identifier that matches the name of an indexed function is treated as a call.
+// When a name is defined in several files, a definition in the caller's own file is preferred.
+func BuildCallGraph(functions []Function) *CallGraph {
+	graph := &CallGraph{Callees: map[int][]int{}, Callers: map[int][]int{}}
+
+	byName := map[string][]Function{}
+	for _, fn := range functions {
+		name := ShortName(fn.FunctionName)
+		byName[name] = append(byName[name], fn)
+	}
+
+	fileCache := map[string][]string{}
+	for _, fn := range functions {
+		lines, ok := fileCache[fn.FilePath]
+		if !ok {
+			lines, _ = fileutil.ReadFileLines(fn.FilePath)
+			fileCache[fn.FilePath] = lines
+		}
+
+		seen := map[int]bool{}
+		for idx, line := range SliceLines(lines, fn.LineStart, fn.LineEnd) {
+			for _, match := range callPattern.FindAllStringSubmatch(line, -1) {
+				candidates := byName[match[1]]
+				if len(candidates) == 0 {
+					continue
+				}
+				// skip the declaration of the function itself
+				if idx == 0 && match[1] == ShortName(fn.FunctionName) {
+					continue
+				}
+				for _, callee := range preferSameFile(candidates, fn.FileId) {
+					if callee.Id == fn.Id || seen[callee.Id] {
+						continue
+					}
+					seen[callee.Id] = true
+					graph.Callees[fn.Id] = append(graph.Callees[fn.Id], callee.Id)
+					graph.Callers[callee.Id] = append(graph.Callers[callee.Id], fn.Id)
+				}
+			}
+		}
+	}
+	return graph
+}
+
+func preferSameFile(candidates []Function, fileId int) []Function {
+	for _, c := range candidates {
+		if c.FileId == fileId {
+			return []Function{c}
+		}
+	}
+	return candidates
+}
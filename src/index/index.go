@@ -0,0 +1,202 @@
+package index
+
+import (
+	"code_assistant/src/db"
+	"code_assistant/src/fileutil"
+	"code_assistant/src/lang"
+	"code_assistant/src/policy"
+	"fmt"
+)
+
+// File represents a row in the files table
+type File struct {
+	Id                 int    `json:"id"`
+	FilePath           string `json:"file_path"`
+	SHA256             string `json:"sha256"`
+	LastUpdateDatetime string `json:"last_update_datetime"`
+	CommitSHA          string `json:"commit_sha"`
+}
+
+// Function represents a row in the functions table joined with its file path
+type Function struct {
+	Id           int    `json:"id"`
+	FunctionName string `json:"function_name"`
+	Signature    string `json:"signature"`
+	Arguments    string `json:"arguments"`
+	Return       string `json:"return"`
+	Namespace    string `json:"namespace"`
+	Description  string `json:"description"`
+	FileId       int    `json:"file_id"`
+	FilePath     string `json:"file_path"`
+	LineStart    int    `json:"line_start"`
+	LineEnd      int    `json:"line_end"`
+}
+
+// Type is a type, struct, class or interface declared in an indexed file
+type Type struct {
+	Name      string `json:"name"`
+	Kind      string `json:"kind"`
+	Signature string `json:"signature"`
+	FileId    int    `json:"file_id"`
+	FilePath  string `json:"file_path"`
+	LineStart int    `json:"line_start"`
+	LineEnd   int    `json:"line_end"`
+}
+
+const functionColumns = `a.id, a.function_name, a.signature, a.arguments, a.return, a.namespace, a.description,
+	a.file_id, b.file_path, a.line_start, a.line_end`
+
+// ListFiles returns all indexed files ordered by path.
+func ListFiles() ([]File, error) {
+	rows, err := db.GetDatabase().Query("SELECT id, file_path, sha256, last_update_datetime, commit_sha FROM files ORDER BY file_path")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var files []File
+	for rows.Next() {
+		var f File
+		if err := rows.Scan(&f.Id, &f.FilePath, &f.SHA256, &f.LastUpdateDatetime, &f.CommitSHA); err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+	}
+	return files, rows.Err()
+}
+
+// GetFile returns the indexed file with the given id.
+func GetFile(id int) (*File, error) {
+	var f File
+	err := db.GetDatabase().QueryRow("SELECT id, file_path, sha256, last_update_datetime, commit_sha FROM files WHERE id = ?", id).
+		Scan(&f.Id, &f.FilePath, &f.SHA256, &f.LastUpdateDatetime, &f.CommitSHA)
+	if err != nil {
+		return nil, fmt.Errorf("file %d not found: %v", id, err)
+	}
+	return &f, nil
+}
+
+// GetFileByPath returns the indexed file with the given absolute path.
+func GetFileByPath(filePath string) (*File, error) {
+	var f File
+	err := db.GetDatabase().QueryRow("SELECT id, file_path, sha256, last_update_datetime, commit_sha FROM files WHERE file_path = ?", filePath).
+		Scan(&f.Id, &f.FilePath, &f.SHA256, &f.LastUpdateDatetime, &f.CommitSHA)
+	if err != nil {
+		return nil, fmt.Errorf("file %s not found: %v", filePath, err)
+	}
+	return &f, nil
+}
+
+// ListFunctions returns all indexed functions ordered by file path and start line.
+func ListFunctions() ([]Function, error) {
+	return queryFunctions("SELECT " + functionColumns + " FROM functions a JOIN files b ON a.file_id = b.id ORDER BY b.file_path, a.line_start")
+}
+
+// FunctionsByFile returns the functions of a single file ordered by start line.
+func FunctionsByFile(fileId int) ([]Function, error) {
+	return queryFunctions("SELECT "+functionColumns+" FROM functions a JOIN files b ON a.file_id = b.id WHERE a.file_id = ? ORDER BY a.line_start", fileId)
+}
+
+// GetFunction returns the indexed function with the given id.
+func GetFunction(id int) (*Function, error) {
+	functions, err := queryFunctions("SELECT "+functionColumns+" FROM functions a JOIN files b ON a.file_id = b.id WHERE a.id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(functions) == 0 {
+		return nil, fmt.Errorf("function %d not found", id)
+	}
+	return &functions[0], nil
+}
+
+// FindFunctions returns the functions whose name matches the given name exactly.
+func FindFunctions(name string) ([]Function, error) {
+	return queryFunctions("SELECT "+functionColumns+" FROM functions a JOIN files b ON a.file_id = b.id WHERE a.function_name = ? ORDER BY b.file_path", name)
+}
+
+func queryFunctions(query string, args ...interface{}) ([]Function, error) {
+	rows, err := db.GetDatabase().Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var functions []Function
+	for rows.Next() {
+		var f Function
+		err := rows.Scan(&f.Id, &f.FunctionName, &f.Signature, &f.Arguments, &f.Return, &f.Namespace, &f.Description,
+			&f.FileId, &f.FilePath, &f.LineStart, &f.LineEnd)
+		if err != nil {
+			return nil, err
+		}
+		functions = append(functions, f)
+	}
+	return functions, rows.Err()
+}
+
+// FileTypes finds the types declared in an indexed file with the local parser of its language.
+//
+// Types are not stored in the index, the file is read from disk. Files of languages without a parser have no types.
+func FileTypes(file File) ([]Type, error) {
+	l, ok := lang.ForFile(file.FilePath)
+	if !ok {
+		return nil, nil
+	}
+	lines, err := fileutil.ReadFileLines(file.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	var types []Type
+	for _, t := range l.FindTypes(lines) {
+		types = append(types, Type{Name: t.Name, Kind: t.Kind, Signature: t.Signature, FileId: file.Id, FilePath: file.FilePath,
+			LineStart: t.LineStart, LineEnd: t.LineEnd})
+	}
+	return types, nil
+}
+
+// FunctionSource reads the lines of a function from disk using its stored line range.
+//
+// Line numbers in the index are 1-based and inclusive.
+func FunctionSource(fn Function) ([]string, error) {
+	lines, err := fileutil.ReadFileLines(fn.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	return SliceLines(lines, fn.LineStart, fn.LineEnd), nil
+}
+
+// SliceLines returns lines[start-1:end] clamped to the bounds of lines.
+func SliceLines(lines []string, start int, end int) []string {
+	if start < 1 {
+		start = 1
+	}
+	if end > len(lines) {
+		end = len(lines)
+	}
+	if start > end {
+		return []string{}
+	}
+	return lines[start-1 : end]
+}
+
+// VisibleFiles drops the files the policy excludes, nothing about them may be handed to a model
+func VisibleFiles(files []File) []File {
+	visible := []File{}
+	for _, f := range files {
+		if policy.ModeFor(f.FilePath) != policy.ModeExclude {
+			visible = append(visible, f)
+		}
+	}
+	return visible
+}
+
+// VisibleFunctions drops the functions of files the policy excludes
+func VisibleFunctions(functions []Function) []Function {
+	visible := []Function{}
+	for _, fn := range functions {
+		if policy.ModeFor(fn.FilePath) != policy.ModeExclude {
+			visible = append(visible, fn)
+		}
+	}
+	return visible
+}
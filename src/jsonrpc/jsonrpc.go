@@ -0,0 +1,212 @@
+package jsonrpc
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// Standard JSON-RPC 2.0 error codes
+const (
+	ParseError     = -32700
+	InvalidRequest = -32600
+	MethodNotFound = -32601
+	InvalidParams  = -32602
+	InternalError  = -32603
+)
+
+// Request is an incoming request or notification. Notifications have no Id.
+type Request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// IsNotification reports whether the sender expects no response
+func (r *Request) IsNotification() bool {
+	return len(r.Id) == 0
+}
+
+// Error is a JSON-RPC error object, it can be returned by a Handler to control the error code
+type Error struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("jsonrpc error %d: %s", e.Code, e.Message)
+}
+
+// Errorf creates an Error with the given code
+func Errorf(code int, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Message: fmt.Sprintf(format, args...)}
+}
+
+type resultResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result"`
+}
+
+type errorResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Id      json.RawMessage `json:"id"`
+	Error   *Error          `json:"error"`
+}
+
+// ErrStop can be returned by a Handler to make Serve return after the current message
+var ErrStop = errors.New("stop serving")
+
+// Handler handles a single method call. The result is ignored for notifications.
+type Handler func(method string, params json.RawMessage) (interface{}, error)
+
+// Stream reads and writes framed messages
+type Stream interface {
+	ReadMessage() ([]byte, error)
+	WriteMessage(data []byte) error
+}
+
+// lineStream frames messages as newline delimited JSON, as used by the MCP stdio transport
+type lineStream struct {
+	reader *bufio.Reader
+	writer io.Writer
+	mu     sync.Mutex
+}
+
+// NewLineStream creates a Stream of newline delimited messages
+func NewLineStream(r io.Reader, w io.Writer) Stream {
+	return &lineStream{reader: bufio.NewReader(r), writer: w}
+}
+
+func (s *lineStream) ReadMessage() ([]byte, error) {
+	for {
+		line, err := s.reader.ReadBytes('\n')
+		line = bytes.TrimSpace(line)
+		if len(line) > 0 {
+			return line, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *lineStream) WriteMessage(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.writer.Write(append(data, '\n'))
+	return err
+}
+
+// headerStream frames messages with a Content-Length header, as used by the Language Server Protocol
+type headerStream struct {
+	reader *textproto.Reader
+	writer io.Writer
+	mu     sync.Mutex
+}
+
+// NewHeaderStream creates a Stream of messages prefixed with a Content-Length header
+func NewHeaderStream(r io.Reader, w io.Writer) Stream {
+	return &headerStream{reader: textproto.NewReader(bufio.NewReader(r)), writer: w}
+}
+
+func (s *headerStream) ReadMessage() ([]byte, error) {
+	header, err := s.reader.ReadMIMEHeader()
+	if err != nil {
+		if err == io.EOF || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, io.EOF
+		}
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length %q", header.Get("Content-Length"))
+	}
+	data := make([]byte, length)
+	if _, err := io.ReadFull(s.reader.R, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+func (s *headerStream) WriteMessage(data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, err := fmt.Fprintf(s.writer, "Content-Length: %d\r\n\r\n", len(data)); err != nil {
+		return err
+	}
+	_, err := s.writer.Write(data)
+	return err
+}
+
+// Serve reads requests from stream until it is closed and answers them with handler.
+//
+// It returns nil when the stream ends cleanly.
+func Serve(stream Stream, handler Handler) error {
+	for {
+		data, err := stream.ReadMessage()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req Request
+		if err := json.Unmarshal(data, &req); err != nil {
+			writeResponse(stream, nil, nil, Errorf(ParseError, "failed to parse message: %v", err))
+			continue
+		}
+		if req.Method == "" {
+			if !req.IsNotification() {
+				writeResponse(stream, req.Id, nil, Errorf(InvalidRequest, "missing method"))
+			}
+			continue
+		}
+
+		result, err := handler(req.Method, req.Params)
+		if err == ErrStop {
+			return nil
+		}
+		if req.IsNotification() {
+			if err != nil {
+				log.Printf("Notification %s failed: %v", req.Method, err)
+			}
+			continue
+		}
+		writeResponse(stream, req.Id, result, err)
+	}
+}
+
+func writeResponse(stream Stream, id json.RawMessage, result interface{}, err error) {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+
+	var data []byte
+	var marshalErr error
+	if err != nil {
+		rpcErr, ok := err.(*Error)
+		if !ok {
+			rpcErr = &Error{Code: InternalError, Message: err.Error()}
+		}
+		data, marshalErr = json.Marshal(errorResponse{JSONRPC: "2.0", Id: id, Error: rpcErr})
+	} else {
+		data, marshalErr = json.Marshal(resultResponse{JSONRPC: "2.0", Id: id, Result: result})
+	}
+	if marshalErr != nil {
+		log.Printf("Failed to marshal response: %v", marshalErr)
+		return
+	}
+	if err := stream.WriteMessage(data); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package lang
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// boundsSearchDistance is how many lines around a reported start line the declaration is looked for
+const boundsSearchDistance = 5
+
+// VerifyBounds checks a 1-based inclusive line range reported by a model for function name against the source.
+//
+// The declaration of name is looked for on the reported start line first, then on the closest lines around it.
+// The end is found from the declaration by matching braces, or by indentation for Python. The returned range is
+// the one found in the source; verified is false when the end could not be determined and the reported end is
+// kept. An error means the range does not point at the function and is rejected.
+func (l Language) VerifyBounds(lines []string, name string, lineStart int, lineEnd int) (start int, end int, verified bool, err error) {
+	if lineStart < 1 || lineStart > len(lines) {
+		return 0, 0, false, fmt.Errorf("start line %d is outside of the file of %d lines", lineStart, len(lines))
+	}
+
+	declIdx := -1
+	for distance := 0; distance <= boundsSearchDistance && declIdx < 0; distance++ {
+		for _, idx := range []int{lineStart - 1 - distance, lineStart - 1 + distance} {
+			if idx >= 0 && idx < len(lines) && l.declares(lines[idx], name) {
+				declIdx = idx
+				break
+			}
+		}
+	}
+	if declIdx < 0 {
+		return 0, 0, false, fmt.Errorf("'%s' is not declared within %d lines of line %d", name, boundsSearchDistance, lineStart)
+	}
+
+	if endIdx, ok := l.blockEnd(lines, declIdx); ok {
+		return declIdx + 1, endIdx + 1, true, nil
+	}
+	if lineEnd < declIdx+1 || lineEnd > len(lines) {
+		return 0, 0, false, fmt.Errorf("end line %d does not follow the declaration on line %d", lineEnd, declIdx+1)
+	}
+	return declIdx + 1, lineEnd, false, nil
+}
+
+// declares reports whether line is the first line of the declaration of function name.
+// A name qualified by its type or class, like "Server.Start" or "Start()", is matched by its last part.
+func (l Language) declares(line string, name string) bool {
+	name = strings.TrimSuffix(name, "()")
+	if idx := strings.LastIndexAny(name, ".:"); idx >= 0 {
+		name = name[idx+1:]
+	}
+	patterns, ok := declarationPatterns[l.Name]
+	if !ok {
+		// no parser for the language, the name followed by a parenthesis has to do
+		return regexp.MustCompile(`\b` + regexp.QuoteMeta(name) + `\s*\(`).MatchString(line)
+	}
+	for _, pattern := range patterns {
+		if m := pattern.FindStringSubmatch(line); m != nil && m[pattern.SubexpIndex("name")] == name {
+			return true
+		}
+	}
+	return false
+}
+
+// blockEnd returns the index of the last line of the block declared at declIdx
+func (l Language) blockEnd(lines []string, declIdx int) (int, bool) {
+	if l.DocStyle == DocStyleDocstring {
+		return indentedBlockEnd(lines, declIdx), true
+	}
+	return l.braceBlockEnd(lines, declIdx)
+}
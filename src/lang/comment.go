@@ -0,0 +1,136 @@
+package lang
+
+import (
+	"strings"
+)
+
+// LeadingComment finds the documentation attached to the declaration at index declIdx.
+//
+// For languages with comments above the declaration, it walks upwards over annotations and decorators
+// and returns the 0-based inclusive range of the comment block.
+// For Python, it returns the range of the docstring following the "def" line instead.
+// ok is false when the declaration is undocumented.
+func (l Language) LeadingComment(lines []string, declIdx int) (start int, end int, ok bool) {
+	if declIdx < 0 || declIdx >= len(lines) {
+		return 0, 0, false
+	}
+	if l.DocStyle == DocStyleDocstring {
+		return docstring(lines, declIdx)
+	}
+
+	idx := declIdx - 1
+	for idx >= 0 && strings.HasPrefix(strings.TrimSpace(lines[idx]), "@") {
+		idx--
+	}
+	if idx < 0 {
+		return 0, 0, false
+	}
+
+	line := strings.TrimSpace(lines[idx])
+	switch {
+	case l.BlockCommentEnd != "" && strings.HasSuffix(line, l.BlockCommentEnd):
+		end = idx
+		for idx >= 0 && !strings.Contains(lines[idx], l.BlockCommentStart) {
+			idx--
+		}
+		if idx < 0 {
+			return 0, 0, false
+		}
+		return idx, end, true
+
+	case l.LineComment != "" && strings.HasPrefix(line, l.LineComment):
+		end = idx
+		for idx >= 0 && strings.HasPrefix(strings.TrimSpace(lines[idx]), l.LineComment) {
+			idx--
+		}
+		return idx + 1, end, true
+	}
+	return 0, 0, false
+}
+
+// Comment returns the text of the comment in the given range with comment markers removed.
+func (l Language) Comment(lines []string, start int, end int) string {
+	var text []string
+	for _, line := range lines[start : end+1] {
+		line = strings.TrimSpace(line)
+		for _, marker := range []string{l.BlockCommentStart + "*", l.BlockCommentStart, l.BlockCommentEnd, `"""`, `'''`, "///", l.LineComment, "*"} {
+			if marker == "" {
+				continue
+			}
+			line = strings.TrimPrefix(line, marker)
+			line = strings.TrimSuffix(line, marker)
+		}
+		text = append(text, strings.TrimSpace(line))
+	}
+	return strings.TrimSpace(strings.Join(text, "\n"))
+}
+
+// DeclarationEnd returns the index of the line that ends the header of a Python "def",
+// which may span several lines.
+func DeclarationEnd(lines []string, declIdx int) int {
+	for idx := declIdx; idx < len(lines); idx++ {
+		line := strings.TrimSpace(lines[idx])
+		if hash := strings.Index(line, "#"); hash >= 0 {
+			line = strings.TrimSpace(line[:hash])
+		}
+		if strings.HasSuffix(line, ":") {
+			return idx
+		}
+	}
+	return declIdx
+}
+
+// SingleLineDefinition reports whether the Python "def" at declIdx has its body on the same line as the
+// colon ending its header, like "def f(): return x". A docstring cannot be inserted into such a definition.
+func (l Language) SingleLineDefinition(lines []string, declIdx int) bool {
+	depth := 0
+	scanner := codeScanner{l: l}
+	for idx := declIdx; idx < len(lines); idx++ {
+		code := scanner.code(lines[idx])
+		for i, c := range code {
+			switch c {
+			case '(', '[', '{':
+				depth++
+			case ')', ']', '}':
+				depth--
+			case ':':
+				if depth == 0 {
+					return strings.TrimSpace(code[i+1:]) != ""
+				}
+			}
+		}
+	}
+	return false
+}
+
+func docstring(lines []string, declIdx int) (int, int, bool) {
+	idx := DeclarationEnd(lines, declIdx) + 1
+	for idx < len(lines) && strings.TrimSpace(lines[idx]) == "" {
+		idx++
+	}
+	if idx >= len(lines) {
+		return 0, 0, false
+	}
+
+	line := strings.TrimSpace(lines[idx])
+	line = strings.TrimLeft(line, "rRuUbB")
+	for _, quote := range []string{`"""`, `'''`} {
+		if !strings.HasPrefix(line, quote) {
+			continue
+		}
+		if strings.Count(line, quote) >= 2 {
+			return idx, idx, true
+		}
+		for end := idx + 1; end < len(lines); end++ {
+			if strings.Contains(lines[end], quote) {
+				return idx, end, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// Indentation returns the leading whitespace of a line.
+func Indentation(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
@@ -0,0 +1,192 @@
+package lang
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Declaration is a function found by a local parser, line numbers are 1-based and inclusive
+type Declaration struct {
+	Name      string
+	Signature string
+	LineStart int
+	LineEnd   int
+}
+
+// declarationPatterns match the first line of a function declaration, the name is the group "name"
+var declarationPatterns = map[string][]*regexp.Regexp{
+	"golang": {regexp.MustCompile(`^func\s+(?:\([^)]*\)\s*)?(?P<name>[A-Za-z_]\w*)\s*[\[(]`)},
+	"python": {regexp.MustCompile(`^\s*(?:async\s+)?def\s+(?P<name>[A-Za-z_]\w*)\s*\(`)},
+	"javascript": {
+		regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*(?P<name>[A-Za-z_$][\w$]*)\s*\(`),
+		regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+(?P<name>[A-Za-z_$][\w$]*)\s*=\s*(?:async\s*)?(?:function\b|\([^)]*\)\s*=>|[A-Za-z_$][\w$]*\s*=>)`),
+	},
+	"typescript": {
+		regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:async\s+)?function\s*\*?\s*(?P<name>[A-Za-z_$][\w$]*)\s*[<(]`),
+		regexp.MustCompile(`^\s*(?:export\s+)?(?:const|let|var)\s+(?P<name>[A-Za-z_$][\w$]*)\s*(?::[^=]+)?=\s*(?:async\s*)?(?:function\b|\([^)]*\)\s*(?::[^=]+)?=>|[A-Za-z_$][\w$]*\s*=>)`),
+	},
+	"java": {regexp.MustCompile(`^\s*(?:(?:public|protected|private|static|final|abstract|synchronized|native|default)\s+)*(?:<[^>]+>\s+)?[\w<>\[\],.?\s]+\s+(?P<name>[A-Za-z_]\w*)\s*\([^;]*$`)},
+	"cpp":  {regexp.MustCompile(`^\s*(?:(?:static|inline|virtual|constexpr|extern|explicit)\s+)*[\w:<>,*&\s]+?[\s*&](?P<name>[A-Za-z_~][\w:~]*)\s*\([^;]*$`)},
+}
+
+// keywords that look like calls to the C-like patterns
+var controlKeywords = map[string]bool{"if": true, "for": true, "while": true, "switch": true, "catch": true, "return": true, "else": true, "new": true, "sizeof": true}
+
+// FindDeclarations lists the functions of a file without calling a model.
+//
+// It is a line based heuristic: declarations are matched with a pattern per language and their end is
+// found by matching braces, or by indentation for Python. It is used for files whose source may not be
+// sent to a model, so only names, signatures and line ranges are indexed.
+func (l Language) FindDeclarations(lines []string) []Declaration {
+	patterns := declarationPatterns[l.Name]
+	inLiteral := l.literalLines(lines)
+
+	var declarations []Declaration
+	for idx := 0; idx < len(lines); idx++ {
+		if inLiteral[idx] {
+			continue
+		}
+		for _, pattern := range patterns {
+			m := pattern.FindStringSubmatch(lines[idx])
+			if m == nil {
+				continue
+			}
+			name := m[pattern.SubexpIndex("name")]
+			if controlKeywords[name] {
+				continue
+			}
+
+			end, ok := l.blockEnd(lines, idx)
+			if !ok {
+				// a prototype or an abstract method
+				continue
+			}
+			declarations = append(declarations, Declaration{Name: name, Signature: strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(lines[idx]), "{")),
+				LineStart: idx + 1, LineEnd: end + 1})
+			if l.DocStyle != DocStyleDocstring {
+				// nested functions are part of the body
+				idx = end
+			}
+			break
+		}
+	}
+	return declarations
+}
+
+// literalLines reports for each line whether it starts inside a block comment or a raw string, such lines are not code
+func (l Language) literalLines(lines []string) []bool {
+	inLiteral := make([]bool, len(lines))
+	scanner := codeScanner{l: l}
+	for idx, line := range lines {
+		inLiteral[idx] = scanner.inBlockComment || scanner.rawQuote != 0
+		scanner.code(line)
+	}
+	return inLiteral
+}
+
+// braceBlockEnd returns the index of the line closing the first brace opened at or after declIdx.
+// Braces in comments and in string and rune literals are not counted.
+func (l Language) braceBlockEnd(lines []string, declIdx int) (int, bool) {
+	depth := 0
+	opened := false
+	scanner := codeScanner{l: l}
+	for idx := declIdx; idx < len(lines); idx++ {
+		line := scanner.code(lines[idx])
+		if !opened && idx > declIdx+3 {
+			return 0, false
+		}
+		if !opened && strings.HasSuffix(strings.TrimSpace(line), ";") && !strings.Contains(line, "{") {
+			return 0, false
+		}
+		for _, c := range line {
+			switch c {
+			case '{':
+				depth++
+				opened = true
+			case '}':
+				depth--
+			}
+		}
+		if opened && depth <= 0 {
+			return idx, true
+		}
+	}
+	return 0, false
+}
+
+// rawStringQuotes are the quotes of string literals that may span lines and have no escapes, per language
+var rawStringQuotes = map[string]byte{"golang": '`', "javascript": '`', "typescript": '`'}
+
+// codeScanner removes comments and the contents of string and rune literals from the lines of a file.
+// Block comments and raw strings may span lines, the scanner keeps its state from one line to the next.
+type codeScanner struct {
+	l              Language
+	inBlockComment bool
+	rawQuote       byte // quote of the raw string the scanner is in, 0 outside
+}
+
+// code returns line without comments and with empty literals, quotes are kept
+func (s *codeScanner) code(line string) string {
+	var b strings.Builder
+	for i := 0; i < len(line); {
+		switch {
+		case s.inBlockComment:
+			end := strings.Index(line[i:], s.l.BlockCommentEnd)
+			if end < 0 {
+				return b.String()
+			}
+			i += end + len(s.l.BlockCommentEnd)
+			s.inBlockComment = false
+		case s.rawQuote != 0:
+			end := strings.IndexByte(line[i:], s.rawQuote)
+			if end < 0 {
+				return b.String()
+			}
+			b.WriteByte(s.rawQuote)
+			i += end + 1
+			s.rawQuote = 0
+		case s.l.LineComment != "" && strings.HasPrefix(line[i:], s.l.LineComment):
+			return b.String()
+		case s.l.BlockCommentStart != "" && strings.HasPrefix(line[i:], s.l.BlockCommentStart):
+			s.inBlockComment = true
+			i += len(s.l.BlockCommentStart)
+		case line[i] == '"' || line[i] == '\'':
+			// ends at the matching quote, or at the end of the line if it is not closed
+			quote := line[i]
+			b.WriteByte(quote)
+			for i++; i < len(line) && line[i] != quote; i++ {
+				if line[i] == '\\' {
+					i++
+				}
+			}
+			if i < len(line) {
+				b.WriteByte(quote)
+				i++
+			}
+		case line[i] == rawStringQuotes[s.l.Name] && line[i] != 0:
+			b.WriteByte(line[i])
+			s.rawQuote = line[i]
+			i++
+		default:
+			b.WriteByte(line[i])
+			i++
+		}
+	}
+	return b.String()
+}
+
+// indentedBlockEnd returns the index of the last line indented deeper than the declaration at declIdx
+func indentedBlockEnd(lines []string, declIdx int) int {
+	indent := len(Indentation(lines[declIdx]))
+	end := DeclarationEnd(lines, declIdx)
+	for idx := end + 1; idx < len(lines); idx++ {
+		if strings.TrimSpace(lines[idx]) == "" {
+			continue
+		}
+		if len(Indentation(lines[idx])) <= indent {
+			break
+		}
+		end = idx
+	}
+	return end
+}
@@ -0,0 +1,253 @@
+package lang
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestVerifyBoundsIgnoresBracesInLiterals(t *testing.T) {
+	golang, _ := ByName("golang")
+	javascript, _ := ByName("javascript")
+	python, _ := ByName("python")
+
+	tests := []struct {
+		name      string
+		l         Language
+		source    string
+		function  string
+		lineStart int
+		lineEnd   int
+		wantStart int
+		wantEnd   int
+	}{
+		{"brace in string", golang, `package a
+
+func render(w io.Writer) {
+	fmt.Fprint(w, "}")
+	fmt.Fprint(w, "done")
+	return
+}`, "render", 3, 7, 3, 7},
+		{"escaped quote before brace", golang, `package a
+
+func quote() string {
+	s := "\"}"
+	return s
+}`, "quote", 3, 6, 3, 6},
+		{"brace in rune", golang, `package a
+
+func isClose(c rune) bool {
+	return c == '}'
+}`, "isClose", 3, 5, 3, 5},
+		{"brace in line comment", golang, `package a
+
+func f() {
+	// a } in a comment
+	g()
+}`, "f", 3, 6, 3, 6},
+		{"brace in block comment over several lines", golang, `package a
+
+func f() {
+	/* {
+	   }} */
+	g()
+}`, "f", 3, 7, 3, 7},
+		{"brace in raw string over several lines", golang, "package a\n\nfunc tmpl() string {\n\treturn `{{range .}}\n}}\n{{end}}`\n}", "tmpl", 3, 7, 3, 7},
+		{"brace in template literal", javascript, "function f(x) {\n  const s = `}${x}`;\n  return s;\n}", "f", 1, 4, 1, 4},
+		{"comment marker in string", golang, `package a
+
+func url() string {
+	return "http://a/{"
+}`, "url", 3, 5, 3, 5},
+		{"python braces do not matter", python, "def f():\n    return \"}\"\n\nx = 1", "f", 1, 2, 1, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, verified, err := tt.l.VerifyBounds(strings.Split(tt.source, "\n"), tt.function, tt.lineStart, tt.lineEnd)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if start != tt.wantStart || end != tt.wantEnd || !verified {
+				t.Errorf("VerifyBounds() = %d %d %t, want %d %d true", start, end, verified, tt.wantStart, tt.wantEnd)
+			}
+		})
+	}
+}
+
+func TestFindDeclarations(t *testing.T) {
+	golang, _ := ByName("golang")
+	cpp, _ := ByName("cpp")
+
+	tests := []struct {
+		name   string
+		l      Language
+		source string
+		want   []Declaration
+	}{
+		{"braces in literals", golang, "package a\n\nfunc a() {\n\ts := \"{\"\n}\n\nfunc b() {\n\tc := '{'\n}\n", []Declaration{
+			{Name: "a", Signature: "func a()", LineStart: 3, LineEnd: 5},
+			{Name: "b", Signature: "func b()", LineStart: 7, LineEnd: 9},
+		}},
+		{"declaration in block comment", golang, "package a\n\n/*\nfunc old() {\n}\n*/\nfunc a() {\n}\n", []Declaration{
+			{Name: "a", Signature: "func a()", LineStart: 7, LineEnd: 8},
+		}},
+		{"declaration in raw string", golang, "package a\n\nvar src = `\nfunc old() {\n`\n\nfunc a() {\n}\n", []Declaration{
+			{Name: "a", Signature: "func a()", LineStart: 7, LineEnd: 8},
+		}},
+		{"prototype", cpp, "int f(int x);\nint g(int x) {\n  return x;\n}\n", []Declaration{
+			{Name: "g", Signature: "int g(int x)", LineStart: 2, LineEnd: 4},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.l.FindDeclarations(strings.Split(tt.source, "\n"))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindDeclarations() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFindDeclarationsOwnSource compares the declarations found in this package with the ones of the Go parser
+func TestFindDeclarationsOwnSource(t *testing.T) {
+	golang, _ := ByName("golang")
+	for _, fileName := range []string{"declarations.go", "bounds.go", "comment.go"} {
+		data, err := os.ReadFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, fileName, data, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []Declaration
+		for _, d := range file.Decls {
+			if fn, ok := d.(*ast.FuncDecl); ok {
+				want = append(want, Declaration{Name: fn.Name.Name, LineStart: fset.Position(fn.Pos()).Line, LineEnd: fset.Position(fn.End()).Line})
+			}
+		}
+
+		var got []Declaration
+		for _, d := range golang.FindDeclarations(strings.Split(string(data), "\n")) {
+			d.Signature = ""
+			got = append(got, d)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: FindDeclarations() = %+v, want %+v", fileName, got, want)
+		}
+	}
+}
+
+func TestFindTypes(t *testing.T) {
+	golang, _ := ByName("golang")
+	python, _ := ByName("python")
+	typescript, _ := ByName("typescript")
+	java, _ := ByName("java")
+	cpp, _ := ByName("cpp")
+
+	tests := []struct {
+		name   string
+		l      Language
+		source string
+		want   []TypeDeclaration
+	}{
+		{"go struct, interface and named type", golang, "package a\n\ntype A struct {\n\tb B\n}\n\ntype I interface{ M() }\n\ntype N int\n\nfunc f() {\n}\n", []TypeDeclaration{
+			{Name: "A", Kind: "struct", Signature: "type A struct", LineStart: 3, LineEnd: 5},
+			{Name: "I", Kind: "interface", Signature: "type I interface{ M() }", LineStart: 7, LineEnd: 7},
+			{Name: "N", Kind: "type", Signature: "type N int", LineStart: 9, LineEnd: 9},
+		}},
+		{"go group", golang, "package a\n\ntype (\n\t// A is a point\n\tA struct {\n\t\tx int\n\t}\n\tB = A\n)\n", []TypeDeclaration{
+			{Name: "A", Kind: "struct", Signature: "A struct", LineStart: 5, LineEnd: 7},
+			{Name: "B", Kind: "type", Signature: "B = A", LineStart: 8, LineEnd: 8},
+		}},
+		{"go type in raw string", golang, "package a\n\nvar src = `\ntype Old struct {\n}\n`\n", nil},
+		{"python nested class", python, "class A(Base):\n    class B:\n        pass\n\n    def f(self):\n        pass\n\nx = 1\n", []TypeDeclaration{
+			{Name: "A", Kind: "class", Signature: "class A(Base):", LineStart: 1, LineEnd: 6},
+			{Name: "B", Kind: "class", Signature: "class B:", LineStart: 2, LineEnd: 3},
+		}},
+		{"typescript alias, interface and enum", typescript, "export type Id = string;\nexport interface User {\n  id: Id;\n}\nenum Color { Red }\n", []TypeDeclaration{
+			{Name: "Id", Kind: "type", Signature: "export type Id = string;", LineStart: 1, LineEnd: 1},
+			{Name: "User", Kind: "interface", Signature: "export interface User", LineStart: 2, LineEnd: 4},
+			{Name: "Color", Kind: "enum", Signature: "enum Color { Red }", LineStart: 5, LineEnd: 5},
+		}},
+		{"java inner record", java, "public final class A {\n  private record P(int x) {}\n}\n", []TypeDeclaration{
+			{Name: "A", Kind: "class", Signature: "public final class A", LineStart: 1, LineEnd: 3},
+			{Name: "P", Kind: "record", Signature: "private record P(int x) {}", LineStart: 2, LineEnd: 2},
+		}},
+		{"cpp forward declaration and enum class", cpp, "class Fwd;\ntemplate <typename T>\nclass Box : public Base<T>\n{\n  T v;\n};\nenum class Color : int { Red };\n", []TypeDeclaration{
+			{Name: "Box", Kind: "class", Signature: "class Box : public Base<T>", LineStart: 3, LineEnd: 6},
+			{Name: "Color", Kind: "enum class", Signature: "enum class Color : int { Red };", LineStart: 7, LineEnd: 7},
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.l.FindTypes(strings.Split(tt.source, "\n"))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("FindTypes() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestFindTypesOwnSource compares the types found in this package with the ones of the Go parser
+func TestFindTypesOwnSource(t *testing.T) {
+	golang, _ := ByName("golang")
+	for _, fileName := range []string{"lang.go", "declarations.go", "types.go"} {
+		data, err := os.ReadFile(fileName)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		fset := token.NewFileSet()
+		file, err := parser.ParseFile(fset, fileName, data, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want []TypeDeclaration
+		for _, d := range file.Decls {
+			if gen, ok := d.(*ast.GenDecl); ok && gen.Tok == token.TYPE {
+				for _, spec := range gen.Specs {
+					want = append(want, TypeDeclaration{Name: spec.(*ast.TypeSpec).Name.Name,
+						LineStart: fset.Position(spec.Pos()).Line, LineEnd: fset.Position(spec.End()).Line})
+				}
+			}
+		}
+
+		var got []TypeDeclaration
+		for _, d := range golang.FindTypes(strings.Split(string(data), "\n")) {
+			d.Kind, d.Signature = "", ""
+			got = append(got, d)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: FindTypes() = %+v, want %+v", fileName, got, want)
+		}
+	}
+}
+
+func TestSingleLineDefinition(t *testing.T) {
+	python, _ := ByName("python")
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		{"def f(): return x", true},
+		{"def f(x: int) -> Dict[str, int]: return {}", true},
+		{"def f(a,\n      b): return a", true},
+		{"def f():\n    return x", false},
+		{"def f(x: int = 1) -> int:  # returns: x\n    return x", false},
+		{"def f(s=\"a: b\"):\n    return s", false},
+		{"def f(cb=lambda: 1):\n    return cb()", false},
+		{"def f(\n    a: int,\n) -> int:\n    return a\n\ndef g(): pass", false},
+	}
+	for _, tt := range tests {
+		if got := python.SingleLineDefinition(strings.Split(tt.source, "\n"), 0); got != tt.want {
+			t.Errorf("SingleLineDefinition(%q) = %t, want %t", tt.source, got, tt.want)
+		}
+	}
+}
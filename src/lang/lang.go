@@ -0,0 +1,66 @@
+package lang
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Documentation comment conventions
+const (
+	DocStyleGoDoc     = "godoc"
+	DocStyleJSDoc     = "jsdoc"
+	DocStyleJavadoc   = "javadoc"
+	DocStyleDocstring = "docstring"
+	DocStyleDoxygen   = "doxygen"
+)
+
+// Language describes how source code of a supported language is laid out
+type Language struct {
+	Name              string
+	Extensions        []string
+	LineComment       string
+	BlockCommentStart string
+	BlockCommentEnd   string
+	DocStyle          string
+}
+
+var registry = []Language{
+	{Name: "cpp", Extensions: []string{".cpp", ".h", ".hpp"}, LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", DocStyle: DocStyleDoxygen},
+	{Name: "javascript", Extensions: []string{".js"}, LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", DocStyle: DocStyleJSDoc},
+	{Name: "typescript", Extensions: []string{".ts"}, LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", DocStyle: DocStyleJSDoc},
+	{Name: "golang", Extensions: []string{".go"}, LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", DocStyle: DocStyleGoDoc},
+	{Name: "java", Extensions: []string{".java"}, LineComment: "//", BlockCommentStart: "/*", BlockCommentEnd: "*/", DocStyle: DocStyleJavadoc},
+	{Name: "python", Extensions: []string{".py"}, LineComment: "#", DocStyle: DocStyleDocstring},
+}
+
+// ForFile returns the language of a file based on its extension.
+func ForFile(filePath string) (Language, bool) {
+	ext := filepath.Ext(filePath)
+	for _, l := range registry {
+		for _, e := range l.Extensions {
+			if strings.EqualFold(e, ext) {
+				return l, true
+			}
+		}
+	}
+	return Language{}, false
+}
+
+// ByName returns the language registered under the given name.
+func ByName(name string) (Language, bool) {
+	for _, l := range registry {
+		if l.Name == name {
+			return l, true
+		}
+	}
+	return Language{}, false
+}
+
+// Extensions returns the file extensions of all registered languages.
+func Extensions() []string {
+	var ext []string
+	for _, l := range registry {
+		ext = append(ext, l.Extensions...)
+	}
+	return ext
+}
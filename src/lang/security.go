@@ -0,0 +1,81 @@
+package lang
+
+// Security checks run by the audit pass, see SecurityHints
+const (
+	CheckInjection       = "injection"
+	CheckSQLInjection    = "sql-injection"
+	CheckPathTraversal   = "path-traversal"
+	CheckDeserialization = "unsafe-deserialization"
+	CheckSecrets         = "hardcoded-secret"
+	CheckWeakCrypto      = "weak-crypto"
+)
+
+// SecurityChecks lists the checks in the order they are presented to the model
+var SecurityChecks = []string{CheckInjection, CheckSQLInjection, CheckPathTraversal, CheckDeserialization, CheckSecrets, CheckWeakCrypto}
+
+var genericSecurityHints = map[string]string{
+	CheckInjection:       "untrusted input reaching a shell, an interpreter or a template without escaping",
+	CheckSQLInjection:    "SQL statements built by string formatting or concatenation instead of bound parameters",
+	CheckPathTraversal:   "file paths built from untrusted input without cleaning and checking them against a base directory",
+	CheckDeserialization: "deserialization of untrusted data into arbitrary types or objects",
+	CheckSecrets:         "passwords, tokens, API keys or private keys written in the source",
+	CheckWeakCrypto:      "MD5 or SHA-1 for security purposes, ECB mode, static IVs, non-cryptographic random numbers for secrets",
+}
+
+// securityHints adds language specific patterns to the generic hints
+var securityHints = map[string]map[string]string{
+	"golang": {
+		CheckInjection:       `exec.Command("sh", "-c", ...) with formatted input, text/template used for HTML`,
+		CheckSQLInjection:    `fmt.Sprintf or + used to build a query passed to Query, Exec or QueryRow, including table or column names`,
+		CheckPathTraversal:   `filepath.Join with request input without filepath.Clean and a prefix check, http.ServeFile on user paths`,
+		CheckDeserialization: `gob or encoding/json into interface{} values that are later type asserted and trusted`,
+		CheckWeakCrypto:      `crypto/md5, crypto/sha1, crypto/des, math/rand used for tokens, InsecureSkipVerify: true`,
+	},
+	"python": {
+		CheckInjection:       `os.system, subprocess with shell=True, eval or exec on input`,
+		CheckSQLInjection:    `cursor.execute with an f-string, % formatting or .format()`,
+		CheckPathTraversal:   `open() or send_file() with paths joined from request input`,
+		CheckDeserialization: `pickle.loads, yaml.load without SafeLoader, marshal on untrusted data`,
+		CheckWeakCrypto:      `hashlib.md5 or sha1 for passwords, random instead of secrets`,
+	},
+	"javascript": {
+		CheckInjection:       `eval, new Function, child_process.exec with template strings, innerHTML with input`,
+		CheckSQLInjection:    `template literals or + concatenation passed to query()`,
+		CheckPathTraversal:   `path.join or fs calls with req.params or req.query values`,
+		CheckDeserialization: `node-serialize, unvalidated JSON.parse results merged into objects (prototype pollution)`,
+		CheckWeakCrypto:      `crypto.createHash("md5"), Math.random for tokens`,
+	},
+	"typescript": {
+		CheckInjection:       `eval, new Function, child_process.exec with template strings, innerHTML with input`,
+		CheckSQLInjection:    `template literals or + concatenation passed to query()`,
+		CheckPathTraversal:   `path.join or fs calls with req.params or req.query values`,
+		CheckDeserialization: `unvalidated JSON.parse results cast to trusted types or merged into objects`,
+		CheckWeakCrypto:      `crypto.createHash("md5"), Math.random for tokens`,
+	},
+	"java": {
+		CheckInjection:       `Runtime.exec or ProcessBuilder with concatenated input, ScriptEngine.eval`,
+		CheckSQLInjection:    `Statement.execute with concatenated strings instead of PreparedStatement`,
+		CheckPathTraversal:   `new File(base, input) or Paths.get with input and no normalize/startsWith check`,
+		CheckDeserialization: `ObjectInputStream.readObject, XMLDecoder, polymorphic Jackson typing on untrusted data`,
+		CheckWeakCrypto:      `MessageDigest.getInstance("MD5"), Cipher "AES/ECB", java.util.Random for secrets`,
+	},
+	"cpp": {
+		CheckInjection:       `system() or popen() with formatted input, format strings taken from input`,
+		CheckSQLInjection:    `sprintf or string concatenation passed to sqlite3_exec or similar`,
+		CheckPathTraversal:   `fopen or std::ifstream on paths built from input`,
+		CheckDeserialization: `memcpy or reinterpret_cast of untrusted buffers into structs without size checks`,
+		CheckWeakCrypto:      `MD5, SHA1, rand() or srand(time(0)) used for secrets`,
+	},
+}
+
+// SecurityHints returns what each security check looks for in code of this language.
+func (l Language) SecurityHints() map[string]string {
+	hints := map[string]string{}
+	for check, hint := range genericSecurityHints {
+		hints[check] = hint
+		if specific, ok := securityHints[l.Name][check]; ok {
+			hints[check] = hint + ", e.g. " + specific
+		}
+	}
+	return hints
+}
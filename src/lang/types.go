@@ -0,0 +1,103 @@
+package lang
+
+import (
+	"regexp"
+	"strings"
+)
+
+// TypeDeclaration is a type, struct, class or interface found by a local parser, line numbers are 1-based and inclusive
+type TypeDeclaration struct {
+	Name      string
+	Kind      string
+	Signature string
+	LineStart int
+	LineEnd   int
+}
+
+// typeKind is the kind of declarations whose pattern has no "kind" group, like a Go named type
+const typeKind = "type"
+
+// typePatterns match the first line of a type declaration, the name is the group "name" and the kind the group "kind"
+var typePatterns = map[string][]*regexp.Regexp{
+	"golang": {regexp.MustCompile(`^type\s+(?P<name>[A-Za-z_]\w*)(?:\[[^\]]*\])?\s*=?\s*(?P<kind>struct|interface)?\b`)},
+	"python": {regexp.MustCompile(`^\s*(?P<kind>class)\s+(?P<name>[A-Za-z_]\w*)\s*[(:]`)},
+	"javascript": {
+		regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?P<kind>class)\s+(?P<name>[A-Za-z_$][\w$]*)`),
+	},
+	"typescript": {
+		regexp.MustCompile(`^\s*(?:export\s+)?(?:default\s+)?(?:declare\s+)?(?:abstract\s+)?(?:const\s+)?(?P<kind>class|interface|enum|type)\s+(?P<name>[A-Za-z_$][\w$]*)`),
+	},
+	"java": {regexp.MustCompile(`^\s*(?:(?:public|protected|private|static|final|abstract|sealed|non-sealed|strictfp)\s+)*(?P<kind>class|interface|enum|record|@interface)\s+(?P<name>[A-Za-z_]\w*)`)},
+	"cpp":  {regexp.MustCompile(`^\s*(?:template\s*<.*>\s*)?(?P<kind>class|struct|union|enum\s+class|enum)\s+(?:\w+\s+)*?(?P<name>[A-Za-z_]\w*)\s*(?:final\s*)?(?::[^;{]*)?(?:\{.*)?$`)},
+}
+
+// goTypeGroupMember matches a type declared inside a "type ( ... )" group
+var goTypeGroupMember = regexp.MustCompile(`^\s+(?P<name>[A-Za-z_]\w*)(?:\[[^\]]*\])?\s*=?\s*(?P<kind>struct|interface)?\b`)
+
+// FindTypes lists the types, structs, classes, interfaces and enums of a file without calling a model.
+//
+// Like FindDeclarations it is a line based heuristic. Declarations with a body end where their braces are
+// matched, or by indentation for Python; type aliases and named types without a body end on their first line.
+// Nested types are listed as well.
+func (l Language) FindTypes(lines []string) []TypeDeclaration {
+	patterns := typePatterns[l.Name]
+	inLiteral := l.literalLines(lines)
+
+	var types []TypeDeclaration
+	inGoGroup := false
+	for idx := 0; idx < len(lines); idx++ {
+		if inLiteral[idx] {
+			continue
+		}
+		if l.Name == "golang" {
+			// a group ends at its closing parenthesis, its members are declared without the keyword
+			trimmed := strings.TrimSpace(lines[idx])
+			if strings.HasPrefix(lines[idx], "type") && strings.TrimSpace(strings.TrimPrefix(trimmed, "type")) == "(" {
+				inGoGroup = true
+				continue
+			}
+			if inGoGroup {
+				if strings.HasPrefix(lines[idx], ")") {
+					inGoGroup = false
+				} else if t, ok := l.typeAt(lines, idx, goTypeGroupMember); ok {
+					types = append(types, t)
+					idx = t.LineEnd - 1
+				}
+				continue
+			}
+		}
+		for _, pattern := range patterns {
+			if t, ok := l.typeAt(lines, idx, pattern); ok {
+				types = append(types, t)
+				break
+			}
+		}
+	}
+	return types
+}
+
+// typeAt returns the type declared by pattern on the line at idx
+func (l Language) typeAt(lines []string, idx int, pattern *regexp.Regexp) (TypeDeclaration, bool) {
+	m := pattern.FindStringSubmatch(lines[idx])
+	if m == nil {
+		return TypeDeclaration{}, false
+	}
+	t := TypeDeclaration{Name: m[pattern.SubexpIndex("name")], Kind: typeKind, LineStart: idx + 1, LineEnd: idx + 1,
+		Signature: strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(lines[idx]), "{"))}
+	if kind := m[pattern.SubexpIndex("kind")]; kind != "" {
+		t.Kind = strings.Join(strings.Fields(kind), " ")
+	}
+
+	// a named type or an alias only has a body if it opens one on its own line
+	scanner := codeScanner{l: l}
+	if t.Kind == typeKind && !strings.Contains(scanner.code(lines[idx]), "{") {
+		return t, true
+	}
+	end, ok := l.blockEnd(lines, idx)
+	if !ok {
+		// a forward declaration
+		return TypeDeclaration{}, false
+	}
+	t.LineEnd = end + 1
+	return t, true
+}
@@ -1,14 +1,14 @@
 package llm_prompt
 
 import (
-	"fmt"
+	"code_assistant/src/config"
 )
 
+// The prompts are rendered from the templates in templates/, see Render for overriding them.
+// Prompts about a window of a file show it with 1-based line numbers, lineStart and lineEnd are 0-based with an exclusive end.
+
 func SystemPrompt() string {
-	prompt := `You are an AGI agent responsible for assisting users. You will be given an instruction from a user paired with a response format template.
-You always comply with the user's request and respond following the most recent given format template. If you violate the format or instructions, a kitten will be killed.
-Beware of the spelling, typos and numbers.`
-	return prompt
+	return mustRender("system", "", Data{})
 }
 
 // Get Function in a code snippet
@@ -17,33 +17,7 @@ type FunctionListItem struct {
 }
 
 func GetFunctionList(language string, codeSnippetList []string, lineStart int, lineEnd int) string {
-
-	codeSnippetList = codeSnippetList[lineStart:lineEnd]
-	codeSnippetList = append(codeSnippetList, []string{"", ""}...) // add some empty lines
-
-	codeSnippet := "line |\n----------------------------------\n"
-	for idx, line := range codeSnippetList {
-		codeSnippet += fmt.Sprintf("%4d |	%s\n", lineStart+idx+1, line)
-	}
-
-	instruction := `The code snippet above is a small chuck from a file.
-	Please identify all functions (including "main") which are defined here with function implementation in the file.
-Ignore all variables and constant definitions.
-DO NOT add any description or explanation.
-You must only respond in following JSON format.`
-
-	formatTemplate := `[
-{
-	"function_name": function 1 name (string)
-},
-{
-	"function_name": function 2 name (string)
-}
-...
-]`
-
-	prompt := fmt.Sprintf("```%s\n%s\n```\n\n%s\n```json\n%s```", language, codeSnippet, instruction, formatTemplate)
-	return prompt
+	return mustRender("function_list", config.AppConfig.Ollama.TextGenModel, FunctionData("", language, codeSnippetList, lineStart, lineEnd))
 }
 
 type BooleanItem struct {
@@ -55,28 +29,7 @@ type AnswerItem struct {
 }
 
 func LocateFunctionDefition(functionName string, language string, codeSnippetList []string, lineStart int, lineEnd int) string {
-
-	codeSnippetList = codeSnippetList[lineStart:lineEnd]
-	codeSnippetList = append(codeSnippetList, []string{"", ""}...) // add some empty lines
-
-	codeSnippet := "line |\n----------------------------------\n"
-	for idx, line := range codeSnippetList {
-		codeSnippet += fmt.Sprintf("%4d |	%s\n", lineStart+idx+1, line)
-	}
-
-	instruction := fmt.Sprintf(`The code snippet above is a small chuck from a %s file.
-DO NOT judge the code or make any changes to code snippet.
-Find the start line and ending line of '%s' function defition.
-Briefly explain your answer.
-You must only respond in following JSON format.
-DO NOT add anything other than JSON.`, language, functionName)
-
-	formatTemplate := `{
-	"answer": string
-}`
-
-	prompt := fmt.Sprintf("```%s\n%s\n```\n\n%s\n```json\n%s\n```", language, codeSnippet, instruction, formatTemplate)
-	return prompt
+	return mustRender("locate_function", config.AppConfig.Ollama.ChatModel, FunctionData(functionName, language, codeSnippetList, lineStart, lineEnd))
 }
 
 type LocateFunctionResponse struct {
@@ -85,51 +38,15 @@ type LocateFunctionResponse struct {
 }
 
 func LocateFunctionDefitionFinal(functionName string, language string, codeSnippetList []string, lineStart int, lineEnd int) string {
-
-	instruction := fmt.Sprintf(`Finalize your answer.
-Find the start line and ending line of '%s' function defition.
-You must only respond in following JSON format.
-DO NOT add any description or explanation.`, functionName)
-
-	formatTemplate := `{
-	"start_line": integer,
-	"end_line": integer
-}`
-
-	prompt := fmt.Sprintf("%s\n```json\n%s\n```", instruction, formatTemplate)
-	return prompt
+	return mustRender("locate_function_final", config.AppConfig.Ollama.ChatModel, FunctionData(functionName, language, codeSnippetList, lineStart, lineEnd))
 }
 
 func CheckFunctionDefition(functionName string, language string, codeSnippetList []string, lineStart int, lineEnd int) string {
-
-	instruction := fmt.Sprintf(`DO NOT judge the code or make any changes to code snippet.
-Analyze the provided %s code snippet to determine if function definition and implementation of '%s' is entirely shown in the code snippet.
-Briefly explain your answer.
-Beware of opening brace and closing brace if the language supports it.
-You must only respond in following JSON format.
-DO NOT add anything other than JSON.`, language, functionName)
-
-	formatTemplate := `{
-	"answer": string
-}`
-
-	prompt := fmt.Sprintf("%s\n```json\n%s\n```", instruction, formatTemplate)
-	return prompt
+	return mustRender("check_function", config.AppConfig.Ollama.ChatModel, FunctionData(functionName, language, codeSnippetList, lineStart, lineEnd))
 }
 
 func CheckFunctionDefitionFinal(functionName string, language string, codeSnippetList []string, lineStart int, lineEnd int) string {
-
-	instruction := fmt.Sprintf(`Finalize your answer.
-Analyze the provided %s code snippet to determine if function body of '%s' is entirely shown in the code snippet.
-You must only respond in following JSON format.
-DO NOT add any description or explanation.`, language, functionName)
-
-	formatTemplate := `{
-	"result": boolean
-}`
-
-	prompt := fmt.Sprintf("%s\n```json\n%s\n```", instruction, formatTemplate)
-	return prompt
+	return mustRender("check_function_final", config.AppConfig.Ollama.ChatModel, FunctionData(functionName, language, codeSnippetList, lineStart, lineEnd))
 }
 
 type AnalyzeFunctionResponse struct {
@@ -137,49 +54,88 @@ type AnalyzeFunctionResponse struct {
 	Signature string `json:"signature"`
 	Arguments string `json:"arguments"`
 	Return    string `json:"return"`
+
+	// PromptVersion is the version of the prompts the analysis was generated with, empty if no model was asked
+	PromptVersion string `json:"-"`
 }
 
 func AnalyzeFunction(functionName string, language string, codeSnippetList []string, lineStart int, lineEnd int) string {
+	return mustRender("analyze_function", config.AppConfig.Ollama.ChatModel, FunctionData(functionName, language, codeSnippetList, lineStart, lineEnd))
+}
 
-	codeSnippetList = codeSnippetList[lineStart:lineEnd]
-	codeSnippetList = append(codeSnippetList, []string{"", ""}...) // add some empty lines
+func AnalyzeFunctionFinal(functionName string, language string, codeSnippetList []string, lineStart int, lineEnd int) string {
+	return mustRender("analyze_function_final", config.AppConfig.Ollama.ChatModel, FunctionData(functionName, language, codeSnippetList, lineStart, lineEnd))
+}
 
-	codeSnippet := "line |\n----------------------------------\n"
-	for idx, line := range codeSnippetList {
-		codeSnippet += fmt.Sprintf("%4d |	%s\n", lineStart+idx+1, line)
-	}
+// IdentifyAndAnalyzeFunction asks for the location, completeness and analysis of a function in a single answer,
+// for models that follow a larger format template reliably
+func IdentifyAndAnalyzeFunction(functionName string, language string, codeSnippetList []string, lineStart int, lineEnd int) string {
+	return mustRender("identify_and_analyze_function", config.AppConfig.Ollama.ChatModel, FunctionData(functionName, language, codeSnippetList, lineStart, lineEnd))
+}
 
-	instruction := fmt.Sprintf(`The code snippet above is a small chuck from a %s file.
-DO NOT judge the code or make any changes to code snippet.
-Analyze the function '%s' in the provided %s code snippet. Describle the purpose of the function and briefly explain your answer.
-You must only respond in following JSON format.
-DO NOT add anything other than JSON.`, language, functionName, language)
+type DocDriftResponse struct {
+	Consistent  bool   `json:"consistent"`
+	Severity    string `json:"severity"`
+	Explanation string `json:"explanation"`
+}
 
-	formatTemplate := `{
-	"answer": string
-}`
+func CheckDocumentationDrift(functionName string, language string, comment string, codeSnippetList []string, lineStart int, lineEnd int) string {
+	data := FunctionData(functionName, language, codeSnippetList, lineStart, lineEnd)
+	data.Comment = comment
+	return mustRender("check_documentation_drift", config.AppConfig.Ollama.ChatModel, data)
+}
 
-	prompt := fmt.Sprintf("```%s\n%s\n```\n\n%s\n```json\n%s\n```", language, codeSnippet, instruction, formatTemplate)
-	return prompt
+func CheckDocumentationDriftFinal(functionName string, language string) string {
+	return mustRender("check_documentation_drift_final", config.AppConfig.Ollama.ChatModel, Data{FunctionName: functionName, Language: language})
 }
 
-func AnalyzeFunctionFinal(functionName string, language string, codeSnippetList []string, lineStart int, lineEnd int) string {
+func AskQuestion(question string, context string) string {
+	return mustRender("ask_question", config.AppConfig.Ollama.ChatModel, Data{Question: question, Context: context})
+}
+
+func ExplainFunction(functionName string, language string, codeSnippetList []string, lineStart int, lineEnd int) string {
+	return mustRender("explain_function", config.AppConfig.Ollama.ChatModel, FunctionData(functionName, language, codeSnippetList, lineStart, lineEnd))
+}
+
+func SummarizeFunctionChange(functionName string, language string, oldRevision string, oldCode string, newRevision string, newCode string) string {
+	return mustRender("summarize_function_change", config.AppConfig.Ollama.ChatModel, Data{FunctionName: functionName, Language: language,
+		OldRevision: oldRevision, OldCode: oldCode, NewRevision: newRevision, NewCode: newCode})
+}
+
+type ReviewFindingItem struct {
+	Line       int    `json:"line"`
+	Severity   string `json:"severity"`
+	Category   string `json:"category"`
+	Message    string `json:"message"`
+	Suggestion string `json:"suggestion"`
+}
+
+func ReviewChange(functionName string, language string, diff string, codeSnippetList []string, lineStart int, lineEnd int, context string) string {
+	data := FunctionData(functionName, language, codeSnippetList, lineStart, lineEnd)
+	data.Diff = diff
+	data.Context = context
+	return mustRender("review_change", config.AppConfig.Ollama.ChatModel, data)
+}
+
+func ReviewChangeFinal(functionName string, language string) string {
+	return mustRender("review_change_final", config.AppConfig.Ollama.ChatModel, Data{FunctionName: functionName, Language: language})
+}
+
+type SecurityFindingItem struct {
+	Rule       string  `json:"rule"`
+	Line       int     `json:"line"`
+	Severity   string  `json:"severity"`
+	Confidence float64 `json:"confidence"`
+	Message    string  `json:"message"`
+	Suggestion string  `json:"suggestion"`
+}
+
+func SecurityAudit(functionName string, language string, checks string, codeSnippetList []string, lineStart int, lineEnd int) string {
+	data := FunctionData(functionName, language, codeSnippetList, lineStart, lineEnd)
+	data.Checks = checks
+	return mustRender("security_audit", config.AppConfig.Ollama.ChatModel, data)
+}
 
-	instruction := fmt.Sprintf(`Analyze the function '%s' in the provided %s code snippet.
-- Describle the purpose of the function.
-- Extract the function signature of '%s'.
-- Describle the arguments of the function.
-- Describle the return type of the function.
-You must only respond in following JSON format.
-DO NOT add anything other than JSON.`, functionName, language, functionName)
-
-	formatTemplate := `{
-	"purpose": string,
-	"signature": string,
-	"arguments": string,
-	"return": string
-}`
-
-	prompt := fmt.Sprintf("%s\n```json\n%s\n```", instruction, formatTemplate)
-	return prompt
+func SecurityAuditFinal(functionName string, language string) string {
+	return mustRender("security_audit_final", config.AppConfig.Ollama.ChatModel, Data{FunctionName: functionName, Language: language})
 }
@@ -0,0 +1,307 @@
+package llm_prompt
+
+import (
+	"bytes"
+	"code_assistant/src/config"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Built-in prompt templates. Files starting with "_" only define templates shared by the others, like "snippet".
+//
+//go:embed templates/*.tmpl
+var builtinTemplates embed.FS
+
+const templateExt = ".tmpl"
+
+// NumberedLine is a line of a code snippet with its 1-based line number
+type NumberedLine struct {
+	Number int
+	Text   string
+}
+
+// Data is what prompt templates are rendered with, each prompt uses the fields it needs
+type Data struct {
+	FunctionName string
+	Language     string
+	// Snippet is the window of the file shown to the model, rendered by the "snippet" template
+	Snippet []NumberedLine
+	// Comment is the documentation comment of the function
+	Comment  string
+	Question string
+	// Context is additional text for the model, e.g. related functions or callers
+	Context     string
+	OldRevision string
+	OldCode     string
+	NewRevision string
+	NewCode     string
+	Diff        string
+	// Checks lists the security problems an audit looks for
+	Checks string
+}
+
+// FunctionData returns the data of a prompt about a function in the window lines[lineStart:lineEnd], 0-based with an exclusive end
+func FunctionData(functionName string, language string, lines []string, lineStart int, lineEnd int) Data {
+	snippet := make([]NumberedLine, 0, lineEnd-lineStart+2)
+	for idx, line := range lines[lineStart:lineEnd] {
+		snippet = append(snippet, NumberedLine{Number: lineStart + idx + 1, Text: line})
+	}
+	// add some empty lines
+	snippet = append(snippet, NumberedLine{Number: lineEnd + 1}, NumberedLine{Number: lineEnd + 2})
+	return Data{FunctionName: functionName, Language: language, Snippet: snippet}
+}
+
+// templateSource is the text of a template and the file it was read from
+type templateSource struct {
+	Name string
+	Path string
+	Text string
+}
+
+// overrideDirs returns the directories of the prompt directory a template is looked up in, most specific first:
+// <model>/<language>, <model>, <language> and the prompt directory itself. A model is looked up with and without its tag.
+func overrideDirs(language string, model string) []string {
+	dir := config.AppConfig.Prompts.Dir
+	if dir == "" {
+		return nil
+	}
+	var models []string
+	if model != "" {
+		models = append(models, model)
+		if name, _, found := strings.Cut(model, ":"); found {
+			models = append(models, name)
+		}
+	}
+
+	var dirs []string
+	for _, m := range models {
+		if language != "" {
+			dirs = append(dirs, filepath.Join(dir, m, language))
+		}
+		dirs = append(dirs, filepath.Join(dir, m))
+	}
+	if language != "" {
+		dirs = append(dirs, filepath.Join(dir, language))
+	}
+	return append(dirs, dir)
+}
+
+// lookupTemplate returns the most specific template of the prompt directory, or the built-in one
+func lookupTemplate(name string, language string, model string, overrides bool) (templateSource, bool) {
+	if overrides {
+		for _, dir := range overrideDirs(language, model) {
+			path := filepath.Join(dir, name+templateExt)
+			if data, err := os.ReadFile(path); err == nil {
+				return templateSource{Name: name, Path: path, Text: strings.TrimSuffix(string(data), "\n")}, true
+			}
+		}
+	}
+	data, err := builtinTemplates.ReadFile("templates/" + name + templateExt)
+	if err != nil {
+		return templateSource{}, false
+	}
+	return templateSource{Name: name, Path: "built-in", Text: strings.TrimSuffix(string(data), "\n")}, true
+}
+
+// partialNames returns the names of the built-in templates shared by the others
+func partialNames() []string {
+	var names []string
+	entries, _ := builtinTemplates.ReadDir("templates")
+	for _, e := range entries {
+		if name := strings.TrimSuffix(e.Name(), templateExt); strings.HasPrefix(name, "_") {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// TemplateNames returns the names of the built-in prompts
+func TemplateNames() []string {
+	var names []string
+	entries, _ := builtinTemplates.ReadDir("templates")
+	for _, e := range entries {
+		if name := strings.TrimSuffix(e.Name(), templateExt); !strings.HasPrefix(name, "_") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// HasTemplate reports whether name is a built-in prompt or a prompt of the prompt directory itself
+func HasTemplate(name string) bool {
+	_, ok := lookupTemplate(name, "", "", true)
+	return ok && !strings.HasPrefix(name, "_")
+}
+
+// compiledTemplate is a parsed prompt with the sources it was parsed from
+type compiledTemplate struct {
+	tmpl    *template.Template
+	sources []templateSource
+}
+
+var (
+	compiledMu        sync.Mutex
+	compiledTemplates = map[string]*compiledTemplate{}
+	// reportedErrors remembers the template errors already logged
+	reportedErrors sync.Map
+)
+
+// compile parses the prompt name together with the shared templates. Templates are read once, a changed
+// prompt directory takes effect on the next start.
+func compile(name string, language string, model string, overrides bool) (*compiledTemplate, error) {
+	key := fmt.Sprintf("%s\x00%s\x00%s\x00%t", name, language, model, overrides)
+	compiledMu.Lock()
+	defer compiledMu.Unlock()
+	if c, ok := compiledTemplates[key]; ok {
+		return c, nil
+	}
+
+	main, ok := lookupTemplate(name, language, model, overrides)
+	if !ok {
+		return nil, fmt.Errorf("unknown prompt template %q", name)
+	}
+	c := &compiledTemplate{tmpl: template.New(name), sources: []templateSource{main}}
+	if _, err := c.tmpl.Parse(main.Text); err != nil {
+		return nil, fmt.Errorf("%s: %v", main.Path, err)
+	}
+	for _, partial := range partialNames() {
+		source, _ := lookupTemplate(partial, language, model, overrides)
+		if _, err := c.tmpl.New(partial).Parse(source.Text); err != nil {
+			return nil, fmt.Errorf("%s: %v", source.Path, err)
+		}
+		c.sources = append(c.sources, source)
+	}
+	compiledTemplates[key] = c
+	return c, nil
+}
+
+// Render renders the prompt template name for a prompt about code in language sent to model.
+// A template of the prompt directory that cannot be parsed or rendered is reported and the built-in one is used.
+func Render(name string, language string, model string, data Data) (string, error) {
+	prompt, err := render(name, language, model, data, true)
+	if err == nil || len(overrideDirs(language, model)) == 0 {
+		return prompt, err
+	}
+	if _, reported := reportedErrors.LoadOrStore(err.Error(), true); !reported {
+		log.Printf("Failed to render prompt template, using the built-in one: %v", err)
+	}
+	return render(name, language, model, data, false)
+}
+
+func render(name string, language string, model string, data Data, overrides bool) (string, error) {
+	c, err := compile(name, language, model, overrides)
+	if err != nil {
+		return "", err
+	}
+	var b bytes.Buffer
+	if err := c.tmpl.Execute(&b, data); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// mustRender renders a prompt of this package, whose built-in templates always render as the tests check.
+// An error is a bug, it panics rather than exiting so that a server recovers and reports it.
+func mustRender(name string, model string, data Data) string {
+	prompt, err := Render(name, data.Language, model, data)
+	if err != nil {
+		panic(fmt.Sprintf("error rendering prompt %s: %v", name, err))
+	}
+	return prompt
+}
+
+// Version returns the prompt version of the templates name, a hash of their sources and the shared templates.
+// Rows produced with a prompt record its version, so a changed template can be told from the descriptions it generated.
+func Version(language string, model string, names ...string) string {
+	var versions []string
+	for _, name := range names {
+		c, err := compile(name, language, model, true)
+		if err != nil {
+			if c, err = compile(name, language, model, false); err != nil {
+				continue
+			}
+		}
+		h := sha256.New()
+		for _, source := range c.sources {
+			fmt.Fprintf(h, "%s\x00%s\x00", source.Name, source.Text)
+		}
+		versions = append(versions, hex.EncodeToString(h.Sum(nil))[:12])
+	}
+	return CombineVersions(versions...)
+}
+
+// CombineVersions returns the version of a result produced by several prompts, a single version is kept as it is
+func CombineVersions(versions ...string) string {
+	var distinct []string
+	seen := map[string]bool{}
+	for _, v := range versions {
+		if v != "" && !seen[v] {
+			seen[v] = true
+			distinct = append(distinct, v)
+		}
+	}
+	switch len(distinct) {
+	case 0:
+		return ""
+	case 1:
+		return distinct[0]
+	}
+	h := sha256.Sum256([]byte(strings.Join(distinct, ",")))
+	return hex.EncodeToString(h[:])[:12]
+}
+
+// TemplateInfo tells which file the template of a prompt is read from
+type TemplateInfo struct {
+	Name    string
+	Path    string
+	Version string
+}
+
+// Templates returns where the built-in prompts are read from for language and model
+func Templates(language string, model string) []TemplateInfo {
+	var infos []TemplateInfo
+	for _, name := range TemplateNames() {
+		source, _ := lookupTemplate(name, language, model, true)
+		path := source.Path
+		if _, err := compile(name, language, model, true); err != nil {
+			path += " (invalid, the built-in template is used)"
+		}
+		infos = append(infos, TemplateInfo{Name: name, Path: path, Version: Version(language, model, name)})
+	}
+	return infos
+}
+
+// ExportTemplates writes the built-in templates to dir, as a starting point for a prompt directory.
+// Existing files are kept unless overwrite is set.
+func ExportTemplates(dir string, overwrite bool) ([]string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	entries, err := builtinTemplates.ReadDir("templates")
+	if err != nil {
+		return nil, err
+	}
+	var written []string
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Name())
+		if _, err := os.Stat(path); err == nil && !overwrite {
+			continue
+		}
+		data, _ := builtinTemplates.ReadFile("templates/" + e.Name())
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			return written, err
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
@@ -0,0 +1,33 @@
+package llm_prompt
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestBuiltinTemplatesRender renders every built-in prompt, mustRender relies on them never failing
+func TestBuiltinTemplatesRender(t *testing.T) {
+	lines := []string{"package a", "", "func A() {", "\tprintln(1)", "}"}
+	data := FunctionData("A", "golang", lines, 0, len(lines))
+	data.Comment = "// A prints one"
+	data.Question = "What does A print?"
+	data.Context = "A is called by main"
+	data.OldRevision, data.NewRevision = "v1", "v2"
+	data.OldCode, data.NewCode = "func A() {}", "func A() {\n\tprintln(1)\n}"
+	data.Diff = "+\tprintln(1)"
+	data.Checks = "- injection"
+
+	for _, name := range TemplateNames() {
+		for _, language := range []string{"", "golang", "python"} {
+			data.Language = language
+			prompt, err := Render(name, language, "mistral:instruct", data)
+			if err != nil {
+				t.Errorf("Render(%s, %q) failed: %v", name, language, err)
+				continue
+			}
+			if strings.TrimSpace(prompt) == "" {
+				t.Errorf("Render(%s, %q) is empty", name, language)
+			}
+		}
+	}
+}
@@ -0,0 +1,285 @@
+package lsp
+
+import (
+	"code_assistant/src/index"
+	"code_assistant/src/jsonrpc"
+	"code_assistant/src/lang"
+	"code_assistant/src/search"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+)
+
+const maxSymbols = 100
+
+// semanticThreshold is the minimum cosine similarity of an embedding match in workspace/symbol
+const semanticThreshold = 0.5
+
+type server struct {
+	shutdown bool
+
+	// embedding is set while the missing function embeddings are computed in the background
+	embedding atomic.Bool
+}
+
+// ServeStdio runs the language server on stdin and stdout until the client exits.
+//
+// Everything else the program prints to stdout is redirected to stderr,
+// so it cannot corrupt the protocol stream.
+func ServeStdio() error {
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = out }()
+
+	return Serve(os.Stdin, out)
+}
+
+// Serve runs the language server on the given reader and writer.
+func Serve(r io.Reader, w io.Writer) error {
+	s := &server{}
+	return jsonrpc.Serve(jsonrpc.NewHeaderStream(r, w), s.handle)
+}
+
+func (s *server) handle(method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "initialize":
+		return map[string]interface{}{
+			"capabilities": map[string]interface{}{
+				"hoverProvider":           true,
+				"workspaceSymbolProvider": true,
+				"codeLensProvider":        map[string]interface{}{"resolveProvider": false},
+				"textDocumentSync":        0,
+			},
+			"serverInfo": map[string]interface{}{"name": "code_assistant", "version": "0.1.0"},
+		}, nil
+
+	case "shutdown":
+		s.shutdown = true
+		return nil, nil
+
+	case "exit":
+		if !s.shutdown {
+			log.Printf("Exit received before shutdown")
+		}
+		return nil, jsonrpc.ErrStop
+
+	case "textDocument/hover":
+		var p TextDocumentPositionParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc.Errorf(jsonrpc.InvalidParams, "invalid params: %v", err)
+		}
+		return hover(p)
+
+	case "workspace/symbol":
+		var p WorkspaceSymbolParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc.Errorf(jsonrpc.InvalidParams, "invalid params: %v", err)
+		}
+		return s.workspaceSymbols(p.Query)
+
+	case "textDocument/codeLens":
+		var p CodeLensParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc.Errorf(jsonrpc.InvalidParams, "invalid params: %v", err)
+		}
+		return codeLenses(p.TextDocument.URI)
+
+	default:
+		// notifications such as initialized, didOpen or didChange need no handling
+		if strings.HasPrefix(method, "$/") || method == "initialized" || strings.HasPrefix(method, "textDocument/did") {
+			return nil, nil
+		}
+		return nil, jsonrpc.Errorf(jsonrpc.MethodNotFound, "method %s not found", method)
+	}
+}
+
+func uriToPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported uri %s", uri)
+	}
+	return filepath.FromSlash(u.Path), nil
+}
+
+func pathToURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// functionRange converts the 1-based inclusive line range stored in the index to an LSP range
+func functionRange(fn index.Function) Range {
+	end := fn.LineEnd - 1
+	if end < fn.LineStart-1 {
+		end = fn.LineStart - 1
+	}
+	return Range{Start: Position{Line: fn.LineStart - 1}, End: Position{Line: end, Character: 1 << 16}}
+}
+
+func fileFunctions(uri string) ([]index.Function, error) {
+	path, err := uriToPath(uri)
+	if err != nil {
+		return nil, err
+	}
+	file, err := index.GetFileByPath(path)
+	if err != nil {
+		// not indexed, nothing to show
+		return nil, nil
+	}
+	return index.FunctionsByFile(file.Id)
+}
+
+// hover shows the stored analysis of the innermost function containing the cursor
+func hover(p TextDocumentPositionParams) (*Hover, error) {
+	functions, err := fileFunctions(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	line := p.Position.Line + 1
+	var match *index.Function
+	for i := range functions {
+		fn := &functions[i]
+		if line < fn.LineStart || line > fn.LineEnd {
+			continue
+		}
+		if match == nil || fn.LineEnd-fn.LineStart < match.LineEnd-match.LineStart {
+			match = fn
+		}
+	}
+	if match == nil {
+		return nil, nil
+	}
+
+	language := ""
+	if l, ok := lang.ForFile(match.FilePath); ok {
+		language = l.Name
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "**%s**\n\n", match.FunctionName)
+	if match.Signature != "" {
+		fmt.Fprintf(&sb, "```%s\n%s\n```\n\n", language, match.Signature)
+	}
+	fmt.Fprintf(&sb, "%s\n\n", match.Description)
+	fmt.Fprintf(&sb, "*Arguments:* %s\n\n*Returns:* %s\n", match.Arguments, match.Return)
+
+	r := functionRange(*match)
+	return &Hover{Contents: MarkupContent{Kind: "markdown", Value: sb.String()}, Range: &r}, nil
+}
+
+// workspaceSymbols matches function names first and completes the list with embedding matches.
+//
+// Semantic search needs an embedding of every function. Until they exist the list is completed with keyword
+// matches instead, and the embeddings are computed in the background, the requests are not held up by them.
+func (s *server) workspaceSymbols(query string) ([]SymbolInformation, error) {
+	functions, err := index.ListFunctions()
+	if err != nil {
+		return nil, err
+	}
+
+	symbols := []SymbolInformation{}
+	seen := map[int]bool{}
+	add := func(fn index.Function) {
+		if seen[fn.Id] || len(symbols) >= maxSymbols {
+			return
+		}
+		seen[fn.Id] = true
+		symbols = append(symbols, SymbolInformation{
+			Name:          fn.FunctionName,
+			Kind:          SymbolKindFunction,
+			Location:      Location{URI: pathToURI(fn.FilePath), Range: functionRange(fn)},
+			ContainerName: filepath.Base(fn.FilePath),
+		})
+	}
+
+	lowerQuery := strings.ToLower(query)
+	for _, fn := range functions {
+		if strings.Contains(strings.ToLower(fn.FunctionName), lowerQuery) {
+			add(fn)
+		}
+	}
+
+	// only worth a model call for descriptive queries that names alone do not answer
+	if len(strings.TrimSpace(query)) >= 3 && len(symbols) < 5 {
+		missing, err := search.MissingEmbeddings()
+		if err != nil {
+			return symbols, err
+		}
+		if len(missing) > 0 {
+			s.computeEmbeddings(missing)
+			results, err := search.Keyword(query, 20)
+			if err != nil {
+				return symbols, err
+			}
+			for _, r := range results {
+				add(r.Function)
+			}
+			return symbols, nil
+		}
+
+		results, err := search.Semantic(query, 20)
+		if err != nil {
+			log.Printf("Semantic symbol search failed: %v", err)
+		}
+		for _, r := range results {
+			if r.Score >= semanticThreshold {
+				add(r.Function)
+			}
+		}
+	}
+	return symbols, nil
+}
+
+// computeEmbeddings computes the embeddings of functions in the background, unless it is already running
+func (s *server) computeEmbeddings(functions []index.Function) {
+	if !s.embedding.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer s.embedding.Store(false)
+		log.Printf("Computing the embeddings of %d functions for semantic symbol search", len(functions))
+		for _, fn := range functions {
+			if _, err := search.FunctionEmbedding(fn); err != nil {
+				log.Printf("Failed to compute embeddings, symbol search matches keywords until they exist: %v", err)
+				return
+			}
+		}
+		log.Printf("Embeddings computed, symbol search matches descriptions")
+	}()
+}
+
+// codeLenses shows the number of callers above every function of the file
+func codeLenses(uri string) ([]CodeLens, error) {
+	functions, err := fileFunctions(uri)
+	if err != nil {
+		return nil, err
+	}
+	lenses := []CodeLens{}
+	if len(functions) == 0 {
+		return lenses, nil
+	}
+
+	all, err := index.ListFunctions()
+	if err != nil {
+		return nil, err
+	}
+	graph := index.BuildCallGraph(all)
+
+	for _, fn := range functions {
+		count := len(graph.Callers[fn.Id])
+		title := fmt.Sprintf("%d callers", count)
+		if count == 1 {
+			title = "1 caller"
+		}
+		start := Position{Line: fn.LineStart - 1}
+		lenses = append(lenses, CodeLens{
+			Range:   Range{Start: start, End: start},
+			Command: &Command{Title: title, Command: "code_assistant.showCallers", Arguments: []interface{}{fn.Id}},
+		})
+	}
+	return lenses, nil
+}
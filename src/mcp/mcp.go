@@ -0,0 +1,94 @@
+package mcp
+
+import (
+	"code_assistant/src/jsonrpc"
+	"encoding/json"
+	"io"
+	"os"
+)
+
+const protocolVersion = "2024-11-05"
+
+type serverInfo struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type initializeResult struct {
+	ProtocolVersion string                 `json:"protocolVersion"`
+	Capabilities    map[string]interface{} `json:"capabilities"`
+	ServerInfo      serverInfo             `json:"serverInfo"`
+}
+
+// ServeStdio runs the MCP server on stdin and stdout until stdin is closed.
+//
+// Everything else the program prints to stdout, like the debug output of the analyzer,
+// is redirected to stderr so it cannot corrupt the protocol stream.
+func ServeStdio() error {
+	out := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = out }()
+
+	return Serve(os.Stdin, out)
+}
+
+// Serve runs the MCP server on the given reader and writer.
+func Serve(r io.Reader, w io.Writer) error {
+	return jsonrpc.Serve(jsonrpc.NewLineStream(r, w), handle)
+}
+
+func handle(method string, params json.RawMessage) (interface{}, error) {
+	switch method {
+	case "initialize":
+		var p struct {
+			ProtocolVersion string `json:"protocolVersion"`
+		}
+		json.Unmarshal(params, &p)
+		version := protocolVersion
+		if p.ProtocolVersion != "" {
+			version = p.ProtocolVersion
+		}
+		return initializeResult{
+			ProtocolVersion: version,
+			Capabilities: map[string]interface{}{
+				"tools":     map[string]interface{}{},
+				"resources": map[string]interface{}{},
+			},
+			ServerInfo: serverInfo{Name: "code_assistant", Version: "0.1.0"},
+		}, nil
+
+	case "notifications/initialized", "notifications/cancelled":
+		return nil, nil
+
+	case "ping":
+		return map[string]interface{}{}, nil
+
+	case "tools/list":
+		return map[string]interface{}{"tools": tools}, nil
+
+	case "tools/call":
+		var p struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc.Errorf(jsonrpc.InvalidParams, "invalid params: %v", err)
+		}
+		return callTool(p.Name, p.Arguments)
+
+	case "resources/list":
+		return listResources()
+
+	case "resources/read":
+		var p struct {
+			URI string `json:"uri"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, jsonrpc.Errorf(jsonrpc.InvalidParams, "invalid params: %v", err)
+		}
+		return readResource(p.URI)
+
+	default:
+		return nil, jsonrpc.Errorf(jsonrpc.MethodNotFound, "method %s not found", method)
+	}
+}
@@ -0,0 +1,118 @@
+package mcp
+
+import (
+	"code_assistant/src/config"
+	"code_assistant/src/db"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// setupIndex indexes a full, a metadata-only and an excluded file, each with a function calling Target
+func setupIndex(t *testing.T) string {
+	dir := t.TempDir()
+	write := func(name string, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	files := []string{
+		write("full.go", "package a\n\nfunc Target() {\n}\n\nfunc Full() {\n\tTarget()\n}\n"),
+		write("meta.go", "package a\n\nfunc Meta() {\n\tTarget()\n}\n"),
+		write("excl.go", "package a\n\nfunc Excl() {\n\tTarget()\n}\n"),
+	}
+	config.AppConfig.PolicyFile = write(".codeassistant-policy", "metadata meta.go\nexclude excl.go\n")
+
+	database, err := db.NewDatabase(filepath.Join(dir, "index.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range []string{
+		`CREATE TABLE files (id INTEGER PRIMARY KEY, file_path TEXT, sha256 TEXT, last_update_datetime TEXT, commit_sha TEXT)`,
+		`CREATE TABLE functions (id INTEGER PRIMARY KEY, function_name TEXT, signature TEXT, arguments TEXT, return TEXT, namespace TEXT,
+			description TEXT, file_id INT, line_start INT, line_end INT)`,
+	} {
+		if _, err := database.Execute(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+	functions := []struct {
+		name      string
+		fileId    int
+		lineStart int
+		lineEnd   int
+	}{{"Target", 1, 3, 4}, {"Full", 1, 6, 8}, {"Meta", 2, 3, 5}, {"Excl", 3, 3, 5}}
+	for i, path := range files {
+		database.Execute("INSERT INTO files VALUES (?, ?, '', '', '')", i+1, path)
+	}
+	for i, fn := range functions {
+		database.Execute("INSERT INTO functions VALUES (?, ?, '', '', '', '', '', ?, ?, ?)", i+1, fn.name, fn.fileId, fn.lineStart, fn.lineEnd)
+	}
+	return dir
+}
+
+// TestPolicyModes checks that excluded files are hidden and only files sent in full have their source returned
+func TestPolicyModes(t *testing.T) {
+	dir := setupIndex(t)
+
+	call := func(name string, args string) (string, bool) {
+		result, err := callTool(name, json.RawMessage(args))
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		r := result.(toolResult)
+		return r.Content[0].Text, r.IsError
+	}
+
+	tools := []struct {
+		name      string
+		args      string
+		wantError bool
+		contains  []string
+		omits     []string
+	}{
+		{"list_files", `{}`, false, []string{"full.go", "meta.go"}, []string{"excl.go"}},
+		{"list_functions", `{}`, false, []string{"Full", "Meta"}, []string{"Excl"}},
+		{"get_function_source", `{"name": "Full"}`, false, []string{"Target()"}, nil},
+		{"get_function_source", `{"name": "Meta"}`, true, []string{"policy"}, []string{"Target()"}},
+		{"get_function_source", `{"name": "Excl"}`, true, []string{"not found"}, []string{"Target()"}},
+		{"get_function_source", `{"function_id": 4}`, true, []string{"not found"}, []string{"Target()"}},
+		{"callers", `{"name": "Target"}`, false, []string{"Full", "Meta"}, []string{"Excl"}},
+	}
+	for _, tt := range tools {
+		text, isError := call(tt.name, tt.args)
+		if isError != tt.wantError {
+			t.Errorf("%s %s: error = %t, want %t: %s", tt.name, tt.args, isError, tt.wantError, text)
+		}
+		for _, s := range tt.contains {
+			if !strings.Contains(text, s) {
+				t.Errorf("%s %s: %q does not contain %q", tt.name, tt.args, text, s)
+			}
+		}
+		for _, s := range tt.omits {
+			if strings.Contains(text, s) {
+				t.Errorf("%s %s: %q contains %q", tt.name, tt.args, text, s)
+			}
+		}
+	}
+
+	listed, err := listResources()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resources := listed.(map[string]interface{})["resources"].([]resource)
+	if len(resources) != 1 || resources[0].Name != "full.go" {
+		t.Errorf("listResources() = %+v, want only full.go", resources)
+	}
+
+	for name, wantOk := range map[string]bool{"full.go": true, "meta.go": false, "excl.go": false} {
+		_, err := readResource(fileURI(filepath.Join(dir, name)))
+		if (err == nil) != wantOk {
+			t.Errorf("readResource(%s) error = %v, want ok %t", name, err, wantOk)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package mcp
+
+import (
+	"code_assistant/src/index"
+	"code_assistant/src/jsonrpc"
+	"code_assistant/src/policy"
+	"net/url"
+	"os"
+	"path/filepath"
+)
+
+type resource struct {
+	URI      string `json:"uri"`
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType"`
+}
+
+type resourceContent struct {
+	URI      string `json:"uri"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+func fileURI(path string) string {
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// listResources exposes the indexed files the policy allows to send in full as resources
+func listResources() (interface{}, error) {
+	files, err := index.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+	resources := []resource{}
+	for _, f := range files {
+		if !policy.AllowsSource(f.FilePath) {
+			continue
+		}
+		resources = append(resources, resource{URI: fileURI(f.FilePath), Name: filepath.Base(f.FilePath), MimeType: "text/plain"})
+	}
+	return map[string]interface{}{"resources": resources}, nil
+}
+
+// readResource returns the content of an indexed file, other paths and files the policy does not allow to send in full are rejected
+func readResource(uri string) (interface{}, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return nil, jsonrpc.Errorf(jsonrpc.InvalidParams, "unsupported resource uri %s", uri)
+	}
+
+	files, err := index.ListFiles()
+	if err != nil {
+		return nil, err
+	}
+	path := filepath.FromSlash(u.Path)
+	for _, f := range files {
+		if f.FilePath != path {
+			continue
+		}
+		switch policy.ModeFor(path) {
+		case policy.ModeExclude:
+			return nil, jsonrpc.Errorf(jsonrpc.InvalidParams, "resource %s is not in the index", uri)
+		case policy.ModeMetadata:
+			return nil, jsonrpc.Errorf(jsonrpc.InvalidParams, "resource %s is metadata-only by policy, its content is not shared", uri)
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{
+			"contents": []resourceContent{{URI: uri, MimeType: "text/plain", Text: string(data)}},
+		}, nil
+	}
+	return nil, jsonrpc.Errorf(jsonrpc.InvalidParams, "resource %s is not in the index", uri)
+}
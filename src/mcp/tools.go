@@ -0,0 +1,220 @@
+package mcp
+
+import (
+	"code_assistant/src/assistant"
+	"code_assistant/src/index"
+	"code_assistant/src/jsonrpc"
+	"code_assistant/src/policy"
+	"code_assistant/src/search"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+type tool struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description"`
+	InputSchema map[string]interface{} `json:"inputSchema"`
+}
+
+type textContent struct {
+	Type string `json:"type"`
+	Text string `json:"text"`
+}
+
+type toolResult struct {
+	Content []textContent `json:"content"`
+	IsError bool          `json:"isError"`
+}
+
+// toolArguments is the union of the arguments accepted by all tools
+type toolArguments struct {
+	FunctionId int    `json:"function_id"`
+	Name       string `json:"name"`
+	FilePath   string `json:"file_path"`
+	Query      string `json:"query"`
+	Limit      int    `json:"limit"`
+}
+
+func schema(properties map[string]interface{}, required ...string) map[string]interface{} {
+	s := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		s["required"] = required
+	}
+	return s
+}
+
+var functionRef = map[string]interface{}{
+	"function_id": map[string]interface{}{"type": "integer", "description": "Id of the function in the index"},
+	"name":        map[string]interface{}{"type": "string", "description": "Function name, used when function_id is not given"},
+}
+
+var tools = []tool{
+	{
+		Name:        "list_files",
+		Description: "List the files in the code index.",
+		InputSchema: schema(map[string]interface{}{}),
+	},
+	{
+		Name:        "list_functions",
+		Description: "List indexed functions with signature, description, arguments, return value and line range.",
+		InputSchema: schema(map[string]interface{}{
+			"file_path": map[string]interface{}{"type": "string", "description": "Only list functions of this file"},
+			"name":      map[string]interface{}{"type": "string", "description": "Only list functions whose name contains this text"},
+		}),
+	},
+	{
+		Name:        "get_function_source",
+		Description: "Get the source code of an indexed function.",
+		InputSchema: schema(functionRef),
+	},
+	{
+		Name:        "semantic_search",
+		Description: "Search indexed functions by meaning using embeddings of their analysis.",
+		InputSchema: schema(map[string]interface{}{
+			"query": map[string]interface{}{"type": "string"},
+			"limit": map[string]interface{}{"type": "integer", "default": 10},
+		}, "query"),
+	},
+	{
+		Name:        "callers",
+		Description: "List the indexed functions calling a function.",
+		InputSchema: schema(functionRef),
+	},
+	{
+		Name:        "explain_function",
+		Description: "Explain what an indexed function does using the chat model.",
+		InputSchema: schema(functionRef),
+	},
+}
+
+// callTool runs a tool. Tool failures are reported in the result so the model can see them.
+func callTool(name string, rawArgs json.RawMessage) (interface{}, error) {
+	var args toolArguments
+	if len(rawArgs) > 0 {
+		if err := json.Unmarshal(rawArgs, &args); err != nil {
+			return nil, jsonrpc.Errorf(jsonrpc.InvalidParams, "invalid arguments: %v", err)
+		}
+	}
+
+	var result interface{}
+	var err error
+	switch name {
+	case "list_files":
+		var files []index.File
+		if files, err = index.ListFiles(); err == nil {
+			result = index.VisibleFiles(files)
+		}
+	case "list_functions":
+		result, err = listFunctions(args)
+	case "get_function_source":
+		result, err = functionSource(args)
+	case "semantic_search":
+		if args.Limit <= 0 {
+			args.Limit = 10
+		}
+		result, err = search.Search(args.Query, args.Limit)
+	case "callers":
+		result, err = callers(args)
+	case "explain_function":
+		var fn *index.Function
+		if fn, err = resolveFunction(args); err == nil {
+			result, err = assistant.ExplainFunction(*fn)
+		}
+	default:
+		return nil, jsonrpc.Errorf(jsonrpc.InvalidParams, "unknown tool %s", name)
+	}
+
+	if err != nil {
+		return toolResult{Content: []textContent{{Type: "text", Text: err.Error()}}, IsError: true}, nil
+	}
+	if text, ok := result.(string); ok {
+		return toolResult{Content: []textContent{{Type: "text", Text: text}}}, nil
+	}
+	data, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return toolResult{Content: []textContent{{Type: "text", Text: string(data)}}}, nil
+}
+
+func listFunctions(args toolArguments) ([]index.Function, error) {
+	functions, err := index.ListFunctions()
+	if err != nil {
+		return nil, err
+	}
+	filtered := []index.Function{}
+	for _, fn := range index.VisibleFunctions(functions) {
+		if args.FilePath != "" && fn.FilePath != args.FilePath {
+			continue
+		}
+		if args.Name != "" && !strings.Contains(strings.ToLower(fn.FunctionName), strings.ToLower(args.Name)) {
+			continue
+		}
+		filtered = append(filtered, fn)
+	}
+	return filtered, nil
+}
+
+// resolveFunction finds the function referenced by id or by name, functions of excluded files are not found
+func resolveFunction(args toolArguments) (*index.Function, error) {
+	if args.FunctionId > 0 {
+		fn, err := index.GetFunction(args.FunctionId)
+		if err != nil {
+			return nil, err
+		}
+		if policy.ModeFor(fn.FilePath) == policy.ModeExclude {
+			return nil, fmt.Errorf("function %d not found", args.FunctionId)
+		}
+		return fn, nil
+	}
+	if args.Name == "" {
+		return nil, fmt.Errorf("function_id or name is required")
+	}
+	functions, err := index.FindFunctions(args.Name)
+	if err != nil {
+		return nil, err
+	}
+	functions = index.VisibleFunctions(functions)
+	if len(functions) == 0 {
+		return nil, fmt.Errorf("function %s not found", args.Name)
+	}
+	return &functions[0], nil
+}
+
+func functionSource(args toolArguments) (string, error) {
+	fn, err := resolveFunction(args)
+	if err != nil {
+		return "", err
+	}
+	// the agent is a model too, only files it may see in full have their source returned
+	if err := policy.Current().Check([]string{fn.FilePath}, nil); err != nil {
+		return "", err
+	}
+	lines, err := index.FunctionSource(*fn)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s:%d-%d\n%s", fn.FilePath, fn.LineStart, fn.LineEnd, strings.Join(lines, "\n")), nil
+}
+
+func callers(args toolArguments) ([]index.Function, error) {
+	fn, err := resolveFunction(args)
+	if err != nil {
+		return nil, err
+	}
+	functions, err := index.ListFunctions()
+	if err != nil {
+		return nil, err
+	}
+	byId := map[int]index.Function{}
+	for _, f := range functions {
+		byId[f.Id] = f
+	}
+
+	result := []index.Function{}
+	for _, callerId := range index.BuildCallGraph(functions).Callers[fn.Id] {
+		result = append(result, byId[callerId])
+	}
+	return index.VisibleFunctions(result), nil
+}
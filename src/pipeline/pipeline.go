@@ -0,0 +1,186 @@
+package pipeline
+
+import (
+	"code_assistant/src/chunker"
+	"code_assistant/src/http_client"
+	"code_assistant/src/llm_prompt"
+	"code_assistant/src/util"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Step is one prompt of a pipeline and how its answer is used
+type Step struct {
+	Name string `json:"name"`
+	// Prompt is the name of a prompt template, see llm_prompt.TemplateNames
+	Prompt string `json:"prompt"`
+	// Schema is the name of the JSON object expected as answer, see SchemaNames. Empty for a free text answer.
+	Schema string `json:"schema"`
+	// Model and Options override the ones of the pipeline run
+	Model   string               `json:"model"`
+	Options *http_client.Options `json:"options"`
+	// KeepHistory keeps the prompt and the answer in the chat for the following steps
+	KeepHistory bool `json:"keep_history"`
+}
+
+// Pipeline is a sequence of prompts sent in one chat
+type Pipeline struct {
+	Steps []Step `json:"steps"`
+}
+
+// Input is what the prompts of a step are built from
+type Input struct {
+	FunctionName string
+	Language     string
+	Lines        []string
+	// window of the file shown to the model, 0-based with an exclusive end
+	LineStart int
+	LineEnd   int
+}
+
+// Result collects the fields of the structured answers of all steps, a later step overwrites the fields it returns
+type Result struct {
+	StartLine int    `json:"start_line"`
+	EndLine   int    `json:"end_line"`
+	Complete  bool   `json:"result"`
+	Purpose   string `json:"purpose"`
+	Signature string `json:"signature"`
+	Arguments string `json:"arguments"`
+	Return    string `json:"return"`
+
+	// PromptVersion is the version of the prompt templates of the steps
+	PromptVersion string `json:"-"`
+}
+
+// Analysis returns the description fields of the result
+func (r Result) Analysis() *llm_prompt.AnalyzeFunctionResponse {
+	return &llm_prompt.AnalyzeFunctionResponse{Purpose: r.Purpose, Signature: r.Signature, Arguments: r.Arguments, Return: r.Return, PromptVersion: r.PromptVersion}
+}
+
+// schemas lists the fields an answer must have, by schema name
+var schemas = map[string][]string{
+	"location": {"start_line", "end_line"},
+	"boolean":  {"result"},
+	"analysis": {"purpose", "signature", "arguments", "return"},
+	"function": {"start_line", "end_line", "result", "purpose", "signature", "arguments", "return"},
+}
+
+// SchemaNames returns the names of the response schemas
+func SchemaNames() []string {
+	var names []string
+	for name := range schemas {
+		names = append(names, name)
+	}
+	return sortedStrings(names)
+}
+
+func sortedStrings(values []string) []string {
+	sort.Strings(values)
+	return values
+}
+
+// Validate checks that every step refers to a prompt template and a registered schema
+func (p Pipeline) Validate() error {
+	for idx, step := range p.Steps {
+		if !llm_prompt.HasTemplate(step.Prompt) {
+			return fmt.Errorf("step %d %q: unknown prompt %q, expected one of %s or a template of the prompt directory", idx+1, step.Name, step.Prompt, strings.Join(llm_prompt.TemplateNames(), ", "))
+		}
+		if _, ok := schemas[step.Schema]; step.Schema != "" && !ok {
+			return fmt.Errorf("step %d %q: unknown schema %q, expected one of %s", idx+1, step.Name, step.Schema, strings.Join(SchemaNames(), ", "))
+		}
+	}
+	return nil
+}
+
+// Fits reports whether the chat of the pipeline about the window of in fits the context budgets of the models.
+//
+// model is the model of the run, a step may override it. The chat grows with the steps that keep their history,
+// every answer is assumed to take answerTokens. A step whose prompt cannot be rendered is left to Run to report.
+func (p Pipeline) Fits(in Input, model string, system string, answerTokens int) bool {
+	data := llm_prompt.FunctionData(in.FunctionName, in.Language, in.Lines, in.LineStart, in.LineEnd)
+	history := 0
+	for _, step := range p.Steps {
+		stepModel := model
+		if step.Model != "" {
+			stepModel = step.Model
+		}
+		prompt, err := llm_prompt.Render(step.Prompt, in.Language, stepModel, data)
+		if err != nil {
+			continue
+		}
+		promptTokens := chunker.EstimateTokens(stepModel, prompt)
+		if chunker.EstimateTokens(stepModel, system)+history+promptTokens+answerTokens > chunker.ContextTokens(stepModel) {
+			return false
+		}
+		if step.KeepHistory {
+			history += promptTokens + answerTokens
+		}
+	}
+	return true
+}
+
+// Run sends the steps of the pipeline to the chat model in order and merges their structured answers.
+//
+// req carries the model, options and policy paths of the run, a step may override model and options.
+// A step sees the prompts and answers of the earlier steps that keep their history.
+func (p Pipeline) Run(in Input, req http_client.ChatRequest) (Result, error) {
+	var result Result
+	var history []http_client.Chat
+	var versions []string
+	for _, step := range p.Steps {
+		stepReq := req
+		if step.Model != "" {
+			stepReq.Model = step.Model
+		}
+		if step.Options != nil {
+			stepReq.Options = step.Options
+		}
+		stepReq.Trace.FunctionName = in.FunctionName
+		stepReq.Trace.Step = step.Name
+
+		data := llm_prompt.FunctionData(in.FunctionName, in.Language, in.Lines, in.LineStart, in.LineEnd)
+		prompt, err := llm_prompt.Render(step.Prompt, in.Language, stepReq.Model, data)
+		if err != nil {
+			return result, fmt.Errorf("step %s: %v", step.Name, err)
+		}
+		versions = append(versions, llm_prompt.Version(in.Language, stepReq.Model, step.Prompt))
+		fmt.Printf("%s\n%s\n\n", step.Name, prompt) //DEBUG
+		message := http_client.Chat{Role: "user", Content: prompt}
+		stepReq.Messages = append(append([]http_client.Chat{}, history...), message)
+
+		// Call ChatGenerateRemote function
+		resp, err := http_client.ChatGenerateRemote(stepReq)
+		if err != nil {
+			return result, fmt.Errorf("step %s: %v", step.Name, err)
+		}
+		fmt.Println("Role:", resp.Result.Role)
+		fmt.Println("Content:", resp.Result.Content)
+
+		if step.Schema != "" {
+			if err := parseAnswer(&result, step.Schema, resp.Result.Content); err != nil {
+				return result, fmt.Errorf("step %s: %v", step.Name, err)
+			}
+		}
+		if step.KeepHistory {
+			history = append(history, message, resp.Result)
+		}
+	}
+	result.PromptVersion = llm_prompt.CombineVersions(versions...)
+	return result, nil
+}
+
+// parseAnswer checks that an answer has the fields of its schema and merges them into result
+func parseAnswer(result *Result, schema string, content string) error {
+	fields, err := util.ParseJsonObject[map[string]json.RawMessage](content)
+	if err != nil {
+		return err
+	}
+	for _, field := range schemas[schema] {
+		if _, ok := fields[field]; !ok {
+			return fmt.Errorf("answer has no %q field", field)
+		}
+	}
+	return json.Unmarshal([]byte(content), result)
+}
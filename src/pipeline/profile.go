@@ -0,0 +1,142 @@
+package pipeline
+
+import (
+	"code_assistant/src/config"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+)
+
+// Profile holds the pipelines a model locates and analyzes functions with
+type Profile struct {
+	// Identify locates a function and checks that it is entirely shown in the window
+	Identify Pipeline `json:"identify"`
+	// Analyze describes the function, without steps the analysis of the identify pipeline is kept
+	Analyze Pipeline `json:"analyze"`
+}
+
+// Built-in profiles
+var builtinProfiles = map[string]Profile{
+	// one question per prompt in a long chat, for small models
+	"stepwise": {
+		Identify: Pipeline{Steps: []Step{
+			{Name: "LocateFunctionDefition", Prompt: "locate_function", KeepHistory: true},
+			{Name: "LocateFunctionDefitionFinal", Prompt: "locate_function_final", Schema: "location", KeepHistory: true},
+			{Name: "CheckFunctionDefition", Prompt: "check_function", KeepHistory: true},
+			{Name: "CheckFunctionDefitionFinal", Prompt: "check_function_final", Schema: "boolean"},
+		}},
+		Analyze: Pipeline{Steps: []Step{
+			{Name: "AnalyzeFunction", Prompt: "analyze_function", KeepHistory: true},
+			{Name: "AnalyzeFunctionFinal", Prompt: "analyze_function_final", Schema: "analysis"},
+		}},
+	},
+	// everything in one structured answer, for models that follow the format reliably
+	"single": {
+		Identify: Pipeline{Steps: []Step{
+			{Name: "IdentifyAndAnalyzeFunction", Prompt: "identify_and_analyze_function", Schema: "function"},
+		}},
+	},
+}
+
+// pipelineFile is the format of the pipeline file
+type pipelineFile struct {
+	Profiles map[string]Profile `json:"profiles"`
+}
+
+// LoadFile reads the profiles defined in a pipeline file and checks their steps
+func LoadFile(path string) (map[string]Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f pipelineFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	for name, profile := range f.Profiles {
+		if err := profile.Identify.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: profile %s, identify %v", path, name, err)
+		}
+		if len(profile.Identify.Steps) == 0 {
+			return nil, fmt.Errorf("%s: profile %s, identify has no steps", path, name)
+		}
+		if err := profile.Analyze.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: profile %s, analyze %v", path, name, err)
+		}
+	}
+	return f.Profiles, nil
+}
+
+var (
+	profiles     map[string]Profile
+	profilesOnce sync.Once
+	// unknownProfiles remembers the unknown profile names already reported
+	unknownProfiles sync.Map
+)
+
+// loadProfiles returns the built-in profiles and the ones of the configured pipeline file, which take precedence
+func loadProfiles() map[string]Profile {
+	profilesOnce.Do(func() {
+		profiles = map[string]Profile{}
+		for name, profile := range builtinProfiles {
+			profiles[name] = profile
+		}
+		if path := config.AppConfig.Pipeline.File; path != "" {
+			custom, err := LoadFile(path)
+			if err != nil {
+				log.Printf("Failed to load pipeline file, using the built-in profiles: %v", err)
+				return
+			}
+			for name, profile := range custom {
+				profiles[name] = profile
+			}
+		}
+	})
+	return profiles
+}
+
+// ProfileNames returns the names of the built-in profiles and the ones of the pipeline file
+func ProfileNames() []string {
+	var names []string
+	for name := range loadProfiles() {
+		names = append(names, name)
+	}
+	return sortedStrings(names)
+}
+
+// ProfileFor returns the profile configured for model, or for its name without a tag,
+// otherwise the default profile. An unknown profile falls back to "stepwise".
+func ProfileFor(model string) Profile {
+	name := config.AppConfig.Pipeline.Profile
+	modelProfiles := parseModelProfiles(config.AppConfig.Pipeline.ModelProfiles)
+	if p, ok := modelProfiles[model]; ok {
+		name = p
+	} else if base, _, found := strings.Cut(model, ":"); found {
+		if p, ok := modelProfiles[base]; ok {
+			name = p
+		}
+	}
+
+	if profile, ok := loadProfiles()[name]; ok {
+		return profile
+	}
+	if _, reported := unknownProfiles.LoadOrStore(name, true); !reported {
+		log.Printf("Unknown pipeline profile %q for model %s, using \"stepwise\", known profiles are %s", name, model, strings.Join(ProfileNames(), ", "))
+	}
+	return builtinProfiles["stepwise"]
+}
+
+// parseModelProfiles parses "model=profile" pairs separated by commas
+func parseModelProfiles(value string) map[string]string {
+	result := map[string]string{}
+	for _, pair := range strings.Split(value, ",") {
+		model, profile, found := strings.Cut(strings.TrimSpace(pair), "=")
+		if found && strings.TrimSpace(profile) != "" {
+			result[strings.TrimSpace(model)] = strings.TrimSpace(profile)
+		}
+	}
+	return result
+}
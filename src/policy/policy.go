@@ -0,0 +1,172 @@
+package policy
+
+import (
+	"bufio"
+	"code_assistant/src/config"
+	"code_assistant/src/fileutil"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// DefaultFileName is looked up in the working directory when no policy file is configured
+const DefaultFileName = ".codeassistant-policy"
+
+// Mode decides what of a file may be sent to a model
+type Mode string
+
+const (
+	// ModeFull sends source code to the model
+	ModeFull Mode = "full"
+	// ModeMetadata indexes names and signatures with local parsers, source code is never sent
+	ModeMetadata Mode = "metadata"
+	// ModeExclude keeps the file out of the index, nothing about it is sent
+	ModeExclude Mode = "exclude"
+)
+
+// Rule applies a mode to the files matching a glob relative to the policy root
+type Rule struct {
+	Mode    Mode
+	Pattern string
+	Line    int
+}
+
+// Policy is an ordered list of rules, the last matching rule wins
+type Policy struct {
+	Path  string
+	Root  string
+	Rules []Rule
+
+	// loadErr is set if a configured policy could not be read, everything is denied then
+	loadErr error
+}
+
+// Load reads a policy file with one "<mode> <glob>" rule per line.
+// Empty lines and lines starting with # are ignored. Globs are relative to the directory of the file.
+func Load(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	absPath, _ := filepath.Abs(path)
+	p := &Policy{Path: absPath, Root: filepath.Dir(absPath)}
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected \"<mode> <glob>\"", path, lineNo)
+		}
+		mode := Mode(strings.ToLower(fields[0]))
+		if mode != ModeFull && mode != ModeMetadata && mode != ModeExclude {
+			return nil, fmt.Errorf("%s:%d: unknown mode %s, use full, metadata or exclude", path, lineNo, fields[0])
+		}
+		p.Rules = append(p.Rules, Rule{Mode: mode, Pattern: fields[1], Line: lineNo})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+var (
+	current     *Policy
+	currentOnce sync.Once
+)
+
+// Current returns the policy of the configured policy file, or of the default file in the working directory.
+// Without a policy file every file is sent in full.
+func Current() *Policy {
+	currentOnce.Do(func() {
+		path := config.AppConfig.PolicyFile
+		if path == "" {
+			path = filepath.Join(config.AppConfig.WorkingDir, DefaultFileName)
+			if !fileutil.FileExists(path) {
+				current = &Policy{}
+				return
+			}
+		}
+		p, err := Load(path)
+		if err != nil {
+			log.Printf("Failed to load policy, nothing will be sent to the model: %v", err)
+			p = &Policy{Path: path, loadErr: err}
+		}
+		current = p
+	})
+	return current
+}
+
+// Match returns the mode of a file and the rule that decided it, nil if no rule matches.
+func (p *Policy) Match(path string) (Mode, *Rule) {
+	if p.loadErr != nil {
+		return ModeExclude, nil
+	}
+	if p.Root == "" {
+		return ModeFull, nil
+	}
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return ModeFull, nil
+	}
+	rel, err := filepath.Rel(p.Root, absPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ModeFull, nil
+	}
+	rel = filepath.ToSlash(rel)
+
+	var match *Rule
+	for i := range p.Rules {
+		if fileutil.MatchGlob(p.Rules[i].Pattern, rel) {
+			match = &p.Rules[i]
+		}
+	}
+	if match == nil {
+		return ModeFull, nil
+	}
+	return match.Mode, match
+}
+
+// ModeFor returns the mode of a file under the current policy.
+func ModeFor(path string) Mode {
+	mode, _ := Current().Match(path)
+	return mode
+}
+
+// AllowsSource reports whether the source code of a file may be sent to a model.
+func AllowsSource(path string) bool {
+	return ModeFor(path) == ModeFull
+}
+
+// Check returns an error if the source of one of sourcePaths, or anything about one of metadataPaths,
+// may not be sent to a model.
+func (p *Policy) Check(sourcePaths []string, metadataPaths []string) error {
+	for _, path := range sourcePaths {
+		if mode, rule := p.Match(path); mode != ModeFull {
+			return p.denied(path, mode, rule)
+		}
+	}
+	for _, path := range metadataPaths {
+		if mode, rule := p.Match(path); mode == ModeExclude {
+			return p.denied(path, mode, rule)
+		}
+	}
+	return nil
+}
+
+func (p *Policy) denied(path string, mode Mode, rule *Rule) error {
+	if rule == nil {
+		return fmt.Errorf("policy: %s may not be sent to a model, policy %s could not be loaded: %v", path, p.Path, p.loadErr)
+	}
+	return fmt.Errorf("policy: %s may not be sent to a model, it is %s by rule \"%s %s\" (%s:%d)",
+		path, mode, rule.Mode, rule.Pattern, p.Path, rule.Line)
+}
@@ -0,0 +1,172 @@
+package redact
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// placeholderPrefix starts every placeholder, values that already are placeholders are not redacted again
+const placeholderPrefix = "REDACTED_"
+
+var placeholderPattern = regexp.MustCompile(placeholderPrefix + `[A-Z0-9_]+_[0-9a-f]{8}\b`)
+
+// Pattern detects one kind of sensitive value. If the expression has a group named "secret",
+// only that group is replaced, otherwise the whole match is.
+type Pattern struct {
+	Kind   string
+	Regexp *regexp.Regexp
+}
+
+// Match counts the occurrences of a redacted value in a text
+type Match struct {
+	Kind        string
+	Placeholder string
+	Count       int
+}
+
+// DefaultPatterns returns the built-in detectors for keys, tokens, passwords and email addresses.
+func DefaultPatterns() []Pattern {
+	return []Pattern{
+		{Kind: "private-key", Regexp: regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----[\s\S]*?-----END [A-Z ]*PRIVATE KEY-----`)},
+		{Kind: "aws-access-key", Regexp: regexp.MustCompile(`\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`)},
+		{Kind: "github-token", Regexp: regexp.MustCompile(`\bgh[pousr]_[A-Za-z0-9]{36,}\b`)},
+		{Kind: "slack-token", Regexp: regexp.MustCompile(`\bxox[abprs]-[A-Za-z0-9-]{10,}`)},
+		{Kind: "api-key", Regexp: regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{20,}`)},
+		{Kind: "jwt", Regexp: regexp.MustCompile(`\beyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`)},
+		{Kind: "bearer-token", Regexp: regexp.MustCompile(`(?i)\bbearer\s+(?P<secret>[A-Za-z0-9._~+/=-]{16,})`)},
+		// only quoted literals, "password = readPassword()" is code, not a secret
+		{Kind: "password", Regexp: regexp.MustCompile(`(?i)(?:password|passwd|pwd|secret|api[_-]?key|access[_-]?token|auth[_-]?token|client[_-]?secret)["']?\s*(?::=|[:=])\s*["'` + "`" + `](?P<secret>[^"'` + "`" + `\s]{4,})["'` + "`" + `]`)},
+		{Kind: "email", Regexp: regexp.MustCompile(`\b[A-Za-z0-9._%+-]+@[A-Za-z0-9-]+(?:\.[A-Za-z0-9-]+)*\.[A-Za-z]{2,}\b`)},
+	}
+}
+
+// LoadPatterns reads custom patterns from a file with one "kind: regexp" per line.
+// Empty lines and lines starting with # are ignored.
+func LoadPatterns(path string) ([]Pattern, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var patterns []Pattern
+	scanner := bufio.NewScanner(f)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kind, expr, found := strings.Cut(line, ":")
+		if !found || strings.TrimSpace(kind) == "" {
+			return nil, fmt.Errorf("%s:%d: expected \"kind: regexp\"", path, lineNo)
+		}
+		re, err := regexp.Compile(strings.TrimSpace(expr))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, lineNo, err)
+		}
+		patterns = append(patterns, Pattern{Kind: strings.TrimSpace(kind), Regexp: re})
+	}
+	return patterns, scanner.Err()
+}
+
+// Redactor replaces sensitive values with placeholders and restores them in answers.
+//
+// Placeholders are derived from a hash of the value, so the same value always gets the same
+// placeholder, across requests and across runs.
+type Redactor struct {
+	patterns []Pattern
+
+	mu     sync.Mutex
+	values map[string]string // placeholder -> original value
+}
+
+// New creates a Redactor applying patterns in order.
+func New(patterns []Pattern) *Redactor {
+	return &Redactor{patterns: patterns, values: map[string]string{}}
+}
+
+// Placeholder returns the placeholder of a value of the given kind.
+func Placeholder(kind string, value string) string {
+	hash := sha256.Sum256([]byte(value))
+	kind = strings.ToUpper(strings.NewReplacer("-", "_", " ", "_").Replace(kind))
+	return placeholderPrefix + kind + "_" + hex.EncodeToString(hash[:4])
+}
+
+// Redact replaces all sensitive values in text and reports what was replaced.
+func (r *Redactor) Redact(text string) (string, []Match) {
+	var matches []Match
+	counts := map[string]int{}
+
+	for _, p := range r.patterns {
+		secretGroup := p.Regexp.SubexpIndex("secret")
+		locations := p.Regexp.FindAllStringSubmatchIndex(text, -1)
+		if len(locations) == 0 {
+			continue
+		}
+
+		var b strings.Builder
+		last := 0
+		for _, loc := range locations {
+			start, end := loc[0], loc[1]
+			if secretGroup > 0 && loc[2*secretGroup] >= 0 {
+				start, end = loc[2*secretGroup], loc[2*secretGroup+1]
+			}
+			value := text[start:end]
+			if strings.HasPrefix(value, placeholderPrefix) {
+				continue
+			}
+			placeholder := Placeholder(p.Kind, value)
+
+			r.mu.Lock()
+			r.values[placeholder] = value
+			r.mu.Unlock()
+
+			if counts[placeholder] == 0 {
+				matches = append(matches, Match{Kind: p.Kind, Placeholder: placeholder})
+			}
+			counts[placeholder]++
+
+			b.WriteString(text[last:start])
+			b.WriteString(placeholder)
+			// keep the line count, prompts refer to code by line number
+			b.WriteString(strings.Repeat("\n", strings.Count(value, "\n")))
+			last = end
+		}
+		b.WriteString(text[last:])
+		text = b.String()
+	}
+
+	for i := range matches {
+		matches[i].Count = counts[matches[i].Placeholder]
+	}
+	return text, matches
+}
+
+// Restore replaces the placeholders known to the Redactor with their original values.
+// If text is JSON, the values are escaped so the document stays valid.
+func (r *Redactor) Restore(text string) string {
+	isJson := json.Valid([]byte(text))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return placeholderPattern.ReplaceAllStringFunc(text, func(placeholder string) string {
+		value, ok := r.values[placeholder]
+		if !ok {
+			return placeholder
+		}
+		if isJson {
+			quoted, _ := json.Marshal(value)
+			return string(quoted[1 : len(quoted)-1])
+		}
+		return value
+	})
+}
@@ -0,0 +1,307 @@
+package review
+
+import (
+	"code_assistant/src/diffutil"
+	"code_assistant/src/fileutil"
+	"code_assistant/src/findings"
+	"code_assistant/src/gitutil"
+	"code_assistant/src/http_client"
+	"code_assistant/src/index"
+	"code_assistant/src/lang"
+	"code_assistant/src/llm_prompt"
+	"code_assistant/src/policy"
+	"code_assistant/src/util"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// number of callers and callees passed to the model as context
+	relatedContextSize = 5
+	// lines around a hunk that is not inside an indexed function
+	hunkContextLines = 10
+)
+
+// Rules lists the rule ids of review findings, one per category
+var Rules = []findings.Rule{
+	{Id: "review/bug", Name: "Bug", Description: "The change likely introduces incorrect behavior."},
+	{Id: "review/error-handling", Name: "ErrorHandling", Description: "Errors are ignored, lost or handled inconsistently."},
+	{Id: "review/concurrency", Name: "Concurrency", Description: "Shared state or goroutines are used unsafely."},
+	{Id: "review/naming", Name: "Naming", Description: "A name is misleading or inconsistent with the code base."},
+	{Id: "review/other", Name: "Other", Description: "Other review comment."},
+}
+
+// target is a function, or a block of top level code, touched by one or more hunks
+type target struct {
+	function  *index.Function
+	lineStart int // 1-based inclusive
+	lineEnd   int
+	hunks     []diffutil.Hunk
+}
+
+func (t *target) name() string {
+	if t.function != nil {
+		return t.function.FunctionName
+	}
+	return fmt.Sprintf("lines %d-%d", t.lineStart, t.lineEnd)
+}
+
+// LoadDiff returns the diff to review.
+//
+// spec is either the path of a unified diff file or a range "git diff" accepts. Relative paths
+// inside a diff file are resolved against the root of the repository containing repoDir.
+func LoadDiff(repoDir string, spec string) ([]diffutil.FileDiff, error) {
+	if !fileutil.FileExists(spec) {
+		return gitutil.Diff(repoDir, spec)
+	}
+
+	content, err := os.ReadFile(spec)
+	if err != nil {
+		return nil, err
+	}
+	files, err := diffutil.Parse(string(content))
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := gitutil.RepoRoot(repoDir)
+	if err != nil {
+		root, _ = filepath.Abs(repoDir)
+	}
+	for i := range files {
+		if files[i].OldPath != "" && !filepath.IsAbs(files[i].OldPath) {
+			files[i].OldPath = filepath.Join(root, filepath.FromSlash(files[i].OldPath))
+		}
+		if files[i].NewPath != "" && !filepath.IsAbs(files[i].NewPath) {
+			files[i].NewPath = filepath.Join(root, filepath.FromSlash(files[i].NewPath))
+		}
+	}
+	return files, nil
+}
+
+// Review asks the chat model to review the changed code of files.
+//
+// Hunks are mapped to the indexed functions they touch, so the model sees each changed function
+// as a whole together with its callers and callees. Hunks outside of any indexed function are
+// reviewed with a few surrounding lines. The working tree copy of each file is read, the diff
+// is expected to be applied already.
+func Review(files []diffutil.FileDiff) ([]findings.Finding, error) {
+	functions, err := index.ListFunctions()
+	if err != nil {
+		return nil, err
+	}
+	graph := index.BuildCallGraph(functions)
+	byId := map[int]index.Function{}
+	for _, fn := range functions {
+		byId[fn.Id] = fn
+	}
+
+	results := []findings.Finding{}
+	for _, f := range files {
+		if f.NewPath == "" {
+			continue
+		}
+		l, ok := lang.ForFile(f.NewPath)
+		if !ok {
+			continue
+		}
+		if !policy.AllowsSource(f.NewPath) {
+			fmt.Printf("Skipping %s, its source may not be sent to the model\n", f.NewPath)
+			continue
+		}
+		lines, err := fileutil.ReadFileLines(f.NewPath)
+		if err != nil {
+			log.Printf("Skipping %s: %v", f.NewPath, err)
+			continue
+		}
+
+		fmt.Printf("Reviewing %s\n", f.NewPath)
+		for _, t := range mapHunks(f, functions, len(lines)) {
+			res, err := reviewTarget(t, f.NewPath, l.Name, lines, graph, byId)
+			if err != nil {
+				log.Printf("Failed to review %s in %s: %v", t.name(), f.NewPath, err)
+				continue
+			}
+			results = append(results, res...)
+		}
+	}
+
+	findings.Sort(results)
+	return results, nil
+}
+
+// mapHunks groups the hunks of a file by the indexed function they overlap
+func mapHunks(f diffutil.FileDiff, functions []index.Function, lineCount int) []*target {
+	var targets []*target
+	byFunction := map[int]*target{}
+
+	for _, h := range f.Hunks {
+		start, end := changedRange(h)
+
+		var owner *index.Function
+		for i := range functions {
+			fn := &functions[i]
+			if fn.FilePath == f.NewPath && fn.LineStart <= end && start <= fn.LineEnd {
+				owner = fn
+				break
+			}
+		}
+
+		if owner == nil {
+			targets = append(targets, &target{lineStart: max(start-hunkContextLines, 1), lineEnd: min(end+hunkContextLines, lineCount),
+				hunks: []diffutil.Hunk{h}})
+			continue
+		}
+		if t, ok := byFunction[owner.Id]; ok {
+			t.hunks = append(t.hunks, h)
+			continue
+		}
+		t := &target{function: owner, lineStart: owner.LineStart, lineEnd: owner.LineEnd, hunks: []diffutil.Hunk{h}}
+		byFunction[owner.Id] = t
+		targets = append(targets, t)
+	}
+	return targets
+}
+
+// changedRange returns the 1-based lines of the new file touched by a hunk, ignoring its context lines.
+// A deletion is reported at the line following it.
+func changedRange(h diffutil.Hunk) (int, int) {
+	start, end := 0, 0
+	line := h.NewStart
+	if h.NewCount == 0 {
+		// a hunk without new lines starts before the deletion point
+		line++
+	}
+	for _, l := range h.Lines {
+		switch l[0] {
+		case ' ':
+			line++
+		case '+', '-':
+			if start == 0 {
+				start = line
+			}
+			end = line
+			if l[0] == '+' {
+				line++
+			}
+		}
+	}
+	if start == 0 {
+		start, end = max(h.NewStart, 1), max(h.NewStart, 1)
+	}
+	return start, end
+}
+
+// relatedContext describes the callers and callees of fn for the prompt
+func relatedContext(fn *index.Function, graph *index.CallGraph, byId map[int]index.Function) string {
+	if fn == nil {
+		return ""
+	}
+	var b strings.Builder
+	describe := func(title string, ids []int) {
+		if len(ids) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "%s:\n", title)
+		for i, id := range ids {
+			if i == relatedContextSize {
+				fmt.Fprintf(&b, "- and %d more\n", len(ids)-i)
+				break
+			}
+			related := byId[id]
+			fmt.Fprintf(&b, "- %s (%s:%d) %s: %s\n", related.FunctionName, related.FilePath, related.LineStart, related.Signature, related.Description)
+		}
+	}
+	describe("It is called by", graph.Callers[fn.Id])
+	describe("It calls", graph.Callees[fn.Id])
+	return b.String()
+}
+
+// reviewTarget runs the review conversation for a single changed function
+func reviewTarget(t *target, filePath string, language string, lines []string, graph *index.CallGraph, byId map[int]index.Function) ([]findings.Finding, error) {
+	var diff strings.Builder
+	for _, h := range t.hunks {
+		diff.WriteString(h.String())
+	}
+
+	lineStart := max(t.lineStart-1, 0)
+	lineEnd := min(t.lineEnd, len(lines))
+	if lineEnd < lineStart {
+		lineEnd = len(lines)
+	}
+
+	chatReq := http_client.NewChatRequest()
+	chatReq.SourcePaths = []string{filePath}
+	chatReq.Trace = http_client.Trace{FunctionName: t.name(), Step: "ReviewChange"}
+
+	{
+		prompt := llm_prompt.ReviewChange(t.name(), language, strings.TrimRight(diff.String(), "\n"), lines, lineStart, lineEnd,
+			relatedContext(t.function, graph, byId))
+		fmt.Printf("ReviewChange\n%s\n\n", prompt) //DEBUG
+
+		chatReq.Messages = append(chatReq.Messages, http_client.Chat{Role: "user", Content: prompt})
+
+		resp, err := http_client.ChatGenerateRemote(chatReq)
+		if err != nil {
+			return nil, fmt.Errorf("error calling ChatGenerateRemote: %v", err)
+		}
+		fmt.Println("Role:", resp.Result.Role)
+		fmt.Println("Content:", resp.Result.Content)
+
+		chatReq.Messages = append(chatReq.Messages, resp.Result)
+	}
+
+	prompt := llm_prompt.ReviewChangeFinal(t.name(), language)
+	fmt.Printf("ReviewChangeFinal\n%s\n\n", prompt) //DEBUG
+	chatReq.Trace.Step = "ReviewChangeFinal"
+
+	chatReq.Messages = append(chatReq.Messages, http_client.Chat{Role: "user", Content: prompt})
+
+	resp, err := http_client.ChatGenerateRemote(chatReq)
+	if err != nil {
+		return nil, fmt.Errorf("error calling ChatGenerateRemote: %v", err)
+	}
+	fmt.Println("Role:", resp.Result.Role)
+	fmt.Println("Content:", resp.Result.Content)
+
+	items, err := util.ParseJsonArray[llm_prompt.ReviewFindingItem](resp.Result.Content)
+	if err != nil {
+		return nil, fmt.Errorf("error ReviewChangeFinal ParseJsonArray: %v", err)
+	}
+
+	functionName := ""
+	if t.function != nil {
+		functionName = t.function.FunctionName
+	}
+	var results []findings.Finding
+	for _, item := range items {
+		if strings.TrimSpace(item.Message) == "" {
+			continue
+		}
+		line := item.Line
+		if line < t.lineStart || line > t.lineEnd {
+			// the model lost track of the numbering, point at the first change
+			line, _ = changedRange(t.hunks[0])
+		}
+		severity := findings.ParseSeverity(strings.ToLower(strings.TrimSpace(item.Severity)), findings.SeverityMedium)
+		if severity == findings.SeverityNone {
+			severity = findings.SeverityLow
+		}
+		results = append(results, findings.Finding{RuleId: "review/" + normalizeCategory(item.Category), FilePath: filePath,
+			LineStart: line, LineEnd: line, Function: functionName, Severity: severity, Message: item.Message, Suggestion: item.Suggestion})
+	}
+	return results, nil
+}
+
+func normalizeCategory(category string) string {
+	category = strings.ToLower(strings.TrimSpace(category))
+	category = strings.ReplaceAll(category, " ", "-")
+	switch category {
+	case "bug", "error-handling", "concurrency", "naming":
+		return category
+	}
+	return "other"
+}
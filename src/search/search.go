@@ -0,0 +1,201 @@
+package search
+
+import (
+	"code_assistant/src/config"
+	"code_assistant/src/db"
+	"code_assistant/src/http_client"
+	"code_assistant/src/index"
+	"code_assistant/src/policy"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Result is a function matching a search query together with its score
+type Result struct {
+	Function index.Function `json:"function"`
+	Score    float64        `json:"score"`
+}
+
+// Search runs a semantic search and falls back to a keyword search if the embedding model is unavailable.
+func Search(query string, limit int) ([]Result, error) {
+	results, err := Semantic(query, limit)
+	if err == nil {
+		return results, nil
+	}
+	log.Printf("Semantic search failed, falling back to keyword search: %v", err)
+	return Keyword(query, limit)
+}
+
+// Keyword ranks functions by how many query terms appear in their name, signature and description.
+func Keyword(query string, limit int) ([]Result, error) {
+	functions, err := index.ListFunctions()
+	if err != nil {
+		return nil, err
+	}
+	terms := strings.Fields(strings.ToLower(query))
+
+	var results []Result
+	for _, fn := range index.VisibleFunctions(functions) {
+		name := strings.ToLower(fn.FunctionName)
+		text := strings.ToLower(fn.Signature + " " + fn.Description + " " + fn.FilePath)
+		score := 0.0
+		for _, term := range terms {
+			if strings.Contains(name, term) {
+				score += 2
+			} else if strings.Contains(text, term) {
+				score += 1
+			}
+		}
+		if score > 0 {
+			results = append(results, Result{Function: fn, Score: score / float64(2*len(terms))})
+		}
+	}
+	return topResults(results, limit), nil
+}
+
+// Semantic ranks functions by cosine similarity between the query embedding and the function embeddings.
+//
+// Function embeddings are computed on demand and stored in function_embeddings,
+// they are recomputed when the embedding model or the indexed text changes.
+func Semantic(query string, limit int) ([]Result, error) {
+	functions, err := index.ListFunctions()
+	if err != nil {
+		return nil, err
+	}
+
+	req := http_client.NewEmbeddingRequest()
+	req.Prompt = query
+	req.NoFiles = true
+	req.Trace = http_client.Trace{Step: "SearchQuery"}
+	resp, err := http_client.EmbeddingGenerateRemote(req)
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Result) == 0 {
+		return nil, fmt.Errorf("embedding model %s returned an empty vector", req.Model)
+	}
+
+	var results []Result
+	for _, fn := range functions {
+		if policy.ModeFor(fn.FilePath) == policy.ModeExclude {
+			continue
+		}
+		vector, err := FunctionEmbedding(fn)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, Result{Function: fn, Score: cosine(resp.Result, vector)})
+	}
+	return topResults(results, limit), nil
+}
+
+// embeddingText is the text that represents a function in the embedding space
+func embeddingText(fn index.Function) string {
+	return fmt.Sprintf("%s\n%s\n%s\nArguments: %s\nReturn: %s", fn.FunctionName, fn.Signature, fn.Description, fn.Arguments, fn.Return)
+}
+
+// embeddingTextHash identifies the text an embedding was computed from, a stored embedding is stale once it changes
+func embeddingTextHash(text string) string {
+	hash := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(hash[:])
+}
+
+// MissingEmbeddings returns the functions semantic search ranks whose embedding is missing or stale,
+// Semantic would compute them before it answers.
+func MissingEmbeddings() ([]index.Function, error) {
+	functions, err := index.ListFunctions()
+	if err != nil {
+		return nil, err
+	}
+	rows, err := db.GetDatabase().Query("SELECT function_id, text_sha256 FROM function_embeddings WHERE model = ?", config.AppConfig.Ollama.EmbeddingModel)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stored := map[int]string{}
+	for rows.Next() {
+		var id int
+		var textHash string
+		if err := rows.Scan(&id, &textHash); err != nil {
+			return nil, err
+		}
+		stored[id] = textHash
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	var missing []index.Function
+	for _, fn := range functions {
+		if policy.ModeFor(fn.FilePath) == policy.ModeExclude {
+			continue
+		}
+		if stored[fn.Id] != embeddingTextHash(embeddingText(fn)) {
+			missing = append(missing, fn)
+		}
+	}
+	return missing, nil
+}
+
+// FunctionEmbedding returns the stored embedding of fn, computing it if it is missing or stale.
+func FunctionEmbedding(fn index.Function) ([]float32, error) {
+	text := embeddingText(fn)
+	textHash := embeddingTextHash(text)
+	model := config.AppConfig.Ollama.EmbeddingModel
+
+	var stored string
+	err := db.GetDatabase().QueryRow("SELECT vector FROM function_embeddings WHERE function_id = ? AND model = ? AND text_sha256 = ?",
+		fn.Id, model, textHash).Scan(&stored)
+	if err == nil {
+		var vector []float32
+		if err := json.Unmarshal([]byte(stored), &vector); err == nil {
+			return vector, nil
+		}
+	}
+
+	req := http_client.NewEmbeddingRequest()
+	req.Prompt = text
+	req.MetadataPaths = []string{fn.FilePath}
+	req.Trace = http_client.Trace{FilePath: fn.FilePath, FunctionName: fn.FunctionName, Step: "FunctionEmbedding"}
+	resp, err := http_client.EmbeddingGenerateRemote(req)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded, _ := json.Marshal(resp.Result)
+	db.GetDatabase().Execute("DELETE FROM function_embeddings WHERE function_id = ?", fn.Id)
+	db.GetDatabase().Execute("INSERT INTO function_embeddings (function_id, model, text_sha256, vector, last_update_datetime) VALUES (?, ?, ?, ?, ?)",
+		fn.Id, model, textHash, string(encoded), time.Now())
+	return resp.Result, nil
+}
+
+func cosine(a []float32, b []float32) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+func topResults(results []Result, limit int) []Result {
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
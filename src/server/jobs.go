@@ -0,0 +1,125 @@
+package server
+
+import (
+	"code_assistant/src/code_analyzer"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	JobQueued    = "queued"
+	JobRunning   = "running"
+	JobCompleted = "completed"
+	JobFailed    = "failed"
+)
+
+// ScanJob is a directory scan running in the background
+type ScanJob struct {
+	Id          int        `json:"id"`
+	Directory   string     `json:"directory"`
+	Status      string     `json:"status"`
+	FilesDone   int        `json:"files_done"`
+	FilesTotal  int        `json:"files_total"`
+	CurrentFile string     `json:"current_file,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+}
+
+// jobManager runs scan jobs one at a time, since the analyzer is not safe for concurrent scans
+type jobManager struct {
+	mu     sync.Mutex
+	jobs   map[int]*ScanJob
+	nextId int
+	queue  chan *ScanJob
+}
+
+func newJobManager() *jobManager {
+	m := &jobManager{jobs: map[int]*ScanJob{}, nextId: 1, queue: make(chan *ScanJob, 100)}
+	go m.run()
+	return m
+}
+
+// Submit queues a scan of directory and returns the job
+func (m *jobManager) Submit(directory string) (*ScanJob, error) {
+	m.mu.Lock()
+	job := &ScanJob{Id: m.nextId, Directory: directory, Status: JobQueued, CreatedAt: time.Now()}
+	m.nextId++
+	m.jobs[job.Id] = job
+	snapshot := *job
+	m.mu.Unlock()
+
+	select {
+	case m.queue <- job:
+		return &snapshot, nil
+	default:
+		m.update(job, func(j *ScanJob) { j.Status = JobFailed; j.Error = "too many queued scans" })
+		return nil, fmt.Errorf("too many queued scans")
+	}
+}
+
+// Get returns a copy of the job with the given id
+func (m *jobManager) Get(id int) (*ScanJob, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	job, ok := m.jobs[id]
+	if !ok {
+		return nil, false
+	}
+	snapshot := *job
+	return &snapshot, true
+}
+
+// List returns copies of all jobs ordered by id
+func (m *jobManager) List() []ScanJob {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	jobs := make([]ScanJob, 0, len(m.jobs))
+	for _, job := range m.jobs {
+		jobs = append(jobs, *job)
+	}
+	sort.Slice(jobs, func(i, j int) bool { return jobs[i].Id < jobs[j].Id })
+	return jobs
+}
+
+func (m *jobManager) update(job *ScanJob, fn func(j *ScanJob)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	fn(job)
+}
+
+func (m *jobManager) run() {
+	for job := range m.queue {
+		m.execute(job)
+	}
+}
+
+func (m *jobManager) execute(job *ScanJob) {
+	now := time.Now()
+	m.update(job, func(j *ScanJob) { j.Status = JobRunning; j.StartedAt = &now })
+
+	var scanErr error
+	defer func() {
+		finished := time.Now()
+		if r := recover(); r != nil {
+			scanErr = fmt.Errorf("%v", r)
+		}
+		if scanErr != nil {
+			m.update(job, func(j *ScanJob) {
+				j.Status = JobFailed
+				j.Error = scanErr.Error()
+				j.CurrentFile = ""
+				j.FinishedAt = &finished
+			})
+			return
+		}
+		m.update(job, func(j *ScanJob) { j.Status = JobCompleted; j.CurrentFile = ""; j.FinishedAt = &finished })
+	}()
+
+	scanErr = code_analyzer.AnalyzeDirectoryWithProgress(job.Directory, func(done int, total int, filePath string) {
+		m.update(job, func(j *ScanJob) { j.FilesDone = done; j.FilesTotal = total; j.CurrentFile = filePath })
+	})
+}
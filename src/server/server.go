@@ -0,0 +1,419 @@
+package server
+
+import (
+	"code_assistant/src/assistant"
+	"code_assistant/src/fileutil"
+	"code_assistant/src/index"
+	"code_assistant/src/policy"
+	"code_assistant/src/search"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 500
+)
+
+// Page is the envelope of paginated list responses
+type Page[T any] struct {
+	Items    []T `json:"items"`
+	Page     int `json:"page"`
+	PageSize int `json:"page_size"`
+	Total    int `json:"total"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+// Server exposes the index over a local HTTP/JSON API
+type Server struct {
+	jobs *jobManager
+	mux  *http.ServeMux
+}
+
+// NewServer creates a Server with all routes registered.
+func NewServer() *Server {
+	s := &Server{jobs: newJobManager(), mux: http.NewServeMux()}
+	s.mux.HandleFunc("/api/files", s.handleFiles)
+	s.mux.HandleFunc("/api/files/", s.handleFile)
+	s.mux.HandleFunc("/api/functions", s.handleFunctions)
+	s.mux.HandleFunc("/api/functions/", s.handleFunction)
+	s.mux.HandleFunc("/api/types", s.handleTypes)
+	s.mux.HandleFunc("/api/search", s.handleSearch)
+	s.mux.HandleFunc("/api/ask", s.handleAsk)
+	s.mux.HandleFunc("/api/explain", s.handleExplain)
+	s.mux.HandleFunc("/api/scans", s.handleScans)
+	s.mux.HandleFunc("/api/scans/", s.handleScan)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// ListenAndServe starts the API server on addr and blocks until it fails.
+func ListenAndServe(addr string) error {
+	log.Printf("Serving code assistant API on http://%s/api", addr)
+	return http.ListenAndServe(addr, NewServer())
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	writeJSON(w, status, errorResponse{Error: fmt.Sprintf(format, args...)})
+}
+
+func allowMethod(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		return false
+	}
+	return true
+}
+
+// pathId parses the numeric id following prefix in the request path
+func pathId(r *http.Request, prefix string) (int, error) {
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, prefix), "/")
+	id, err := strconv.Atoi(rest)
+	if err != nil {
+		return 0, fmt.Errorf("invalid id %q", rest)
+	}
+	return id, nil
+}
+
+func queryInt(r *http.Request, key string, defaultValue int) int {
+	if value, err := strconv.Atoi(r.URL.Query().Get(key)); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+// paginate slices items according to the page and page_size query parameters
+func paginate[T any](r *http.Request, items []T) Page[T] {
+	page := queryInt(r, "page", 1)
+	if page < 1 {
+		page = 1
+	}
+	pageSize := queryInt(r, "page_size", defaultPageSize)
+	if pageSize < 1 || pageSize > maxPageSize {
+		pageSize = defaultPageSize
+	}
+
+	start := (page - 1) * pageSize
+	if start > len(items) {
+		start = len(items)
+	}
+	end := start + pageSize
+	if end > len(items) {
+		end = len(items)
+	}
+	result := Page[T]{Items: items[start:end], Page: page, PageSize: pageSize, Total: len(items)}
+	if result.Items == nil {
+		result.Items = []T{}
+	}
+	return result
+}
+
+// GET /api/files
+func (s *Server) handleFiles(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	files, err := index.ListFiles()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, paginate(r, index.VisibleFiles(files)))
+}
+
+// GET /api/files/{id}
+func (s *Server) handleFile(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	id, err := pathId(r, "/api/files/")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	file, err := index.GetFile(id)
+	if err == nil && policy.ModeFor(file.FilePath) == policy.ModeExclude {
+		err = fmt.Errorf("file %d not found", id)
+	}
+	if err != nil {
+		writeError(w, http.StatusNotFound, "%v", err)
+		return
+	}
+	functions, err := index.FunctionsByFile(id)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	if functions == nil {
+		functions = []index.Function{}
+	}
+	writeJSON(w, http.StatusOK, struct {
+		*index.File
+		Functions []index.Function `json:"functions"`
+	}{file, functions})
+}
+
+// GET /api/functions?file_id=&name=
+func (s *Server) handleFunctions(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	var functions []index.Function
+	var err error
+	if fileId := queryInt(r, "file_id", 0); fileId > 0 {
+		functions, err = index.FunctionsByFile(fileId)
+	} else {
+		functions, err = index.ListFunctions()
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+
+	functions = index.VisibleFunctions(functions)
+	if name := strings.ToLower(r.URL.Query().Get("name")); name != "" {
+		filtered := []index.Function{}
+		for _, fn := range functions {
+			if strings.Contains(strings.ToLower(fn.FunctionName), name) {
+				filtered = append(filtered, fn)
+			}
+		}
+		functions = filtered
+	}
+	writeJSON(w, http.StatusOK, paginate(r, functions))
+}
+
+type functionDetail struct {
+	index.Function
+	Source  []string         `json:"source"`
+	Callers []index.Function `json:"callers"`
+	Callees []index.Function `json:"callees"`
+}
+
+// GET /api/functions/{id}
+func (s *Server) handleFunction(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	id, err := pathId(r, "/api/functions/")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	fn, err := index.GetFunction(id)
+	if err == nil && policy.ModeFor(fn.FilePath) == policy.ModeExclude {
+		err = fmt.Errorf("function %d not found", id)
+	}
+	if err != nil {
+		writeError(w, http.StatusNotFound, "%v", err)
+		return
+	}
+
+	// the source is left out for metadata-only files, clients may pass it on to a model
+	detail := functionDetail{Function: *fn, Source: []string{}, Callers: []index.Function{}, Callees: []index.Function{}}
+	if policy.AllowsSource(fn.FilePath) {
+		if lines, err := fileutil.ReadFileLines(fn.FilePath); err == nil {
+			detail.Source = index.SliceLines(lines, fn.LineStart, fn.LineEnd)
+		}
+	}
+
+	functions, err := index.ListFunctions()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	byId := map[int]index.Function{}
+	for _, f := range functions {
+		byId[f.Id] = f
+	}
+	graph := index.BuildCallGraph(functions)
+	for _, callerId := range graph.Callers[id] {
+		detail.Callers = append(detail.Callers, byId[callerId])
+	}
+	for _, calleeId := range graph.Callees[id] {
+		detail.Callees = append(detail.Callees, byId[calleeId])
+	}
+	detail.Callers = index.VisibleFunctions(detail.Callers)
+	detail.Callees = index.VisibleFunctions(detail.Callees)
+	writeJSON(w, http.StatusOK, detail)
+}
+
+// GET /api/types?file_id=&name=&kind=
+func (s *Server) handleTypes(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	var files []index.File
+	if fileId := queryInt(r, "file_id", 0); fileId > 0 {
+		file, err := index.GetFile(fileId)
+		if err == nil && policy.ModeFor(file.FilePath) == policy.ModeExclude {
+			err = fmt.Errorf("file %d not found", fileId)
+		}
+		if err != nil {
+			writeError(w, http.StatusNotFound, "%v", err)
+			return
+		}
+		files = []index.File{*file}
+	} else {
+		var err error
+		if files, err = index.ListFiles(); err != nil {
+			writeError(w, http.StatusInternalServerError, "%v", err)
+			return
+		}
+	}
+
+	// declarations are metadata, they are listed for metadata-only files too
+	name := strings.ToLower(r.URL.Query().Get("name"))
+	kind := r.URL.Query().Get("kind")
+	types := []index.Type{}
+	for _, file := range index.VisibleFiles(files) {
+		fileTypes, err := index.FileTypes(file)
+		if err != nil {
+			log.Printf("Failed to read types of %s: %v", file.FilePath, err)
+			continue
+		}
+		for _, t := range fileTypes {
+			if (name == "" || strings.Contains(strings.ToLower(t.Name), name)) && (kind == "" || t.Kind == kind) {
+				types = append(types, t)
+			}
+		}
+	}
+	writeJSON(w, http.StatusOK, paginate(r, types))
+}
+
+// GET /api/search?q=&limit=
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	query := r.URL.Query().Get("q")
+	if query == "" {
+		writeError(w, http.StatusBadRequest, "missing query parameter q")
+		return
+	}
+	results, err := search.Search(query, queryInt(r, "limit", 10))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	if results == nil {
+		results = []search.Result{}
+	}
+	writeJSON(w, http.StatusOK, results)
+}
+
+// POST /api/ask {"question": string}
+func (s *Server) handleAsk(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	var body struct {
+		Question string `json:"question"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: %v", err)
+		return
+	}
+	answer, err := assistant.Ask(body.Question)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, answer)
+}
+
+// POST /api/explain {"function_id": int}
+func (s *Server) handleExplain(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodPost) {
+		return
+	}
+	var body struct {
+		FunctionId int `json:"function_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid request body: %v", err)
+		return
+	}
+	fn, err := index.GetFunction(body.FunctionId)
+	if err != nil {
+		writeError(w, http.StatusNotFound, "%v", err)
+		return
+	}
+	explanation, err := assistant.ExplainFunction(*fn)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "%v", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"function": fn, "explanation": explanation})
+}
+
+// GET /api/scans, POST /api/scans {"directory": string}
+func (s *Server) handleScans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, paginate(r, s.jobs.List()))
+
+	case http.MethodPost:
+		var body struct {
+			Directory string `json:"directory"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid request body: %v", err)
+			return
+		}
+		if info, err := os.Stat(body.Directory); err != nil || !info.IsDir() {
+			writeError(w, http.StatusBadRequest, "directory %q does not exist", body.Directory)
+			return
+		}
+		job, err := s.jobs.Submit(body.Directory)
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, "%v", err)
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/api/scans/%d", job.Id))
+		writeJSON(w, http.StatusAccepted, job)
+
+	default:
+		w.Header().Set("Allow", "GET, POST")
+		writeError(w, http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+	}
+}
+
+// GET /api/scans/{id}
+func (s *Server) handleScan(w http.ResponseWriter, r *http.Request) {
+	if !allowMethod(w, r, http.MethodGet) {
+		return
+	}
+	id, err := pathId(r, "/api/scans/")
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "%v", err)
+		return
+	}
+	job, ok := s.jobs.Get(id)
+	if !ok {
+		writeError(w, http.StatusNotFound, "scan %d not found", id)
+		return
+	}
+	writeJSON(w, http.StatusOK, job)
+}
@@ -0,0 +1,48 @@
+package watcher
+
+import (
+	"code_assistant/src/fileutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Watcher reports paths of files that were written, created, moved or removed below a directory
+type Watcher interface {
+	Events() <-chan string
+	Errors() <-chan error
+	Close() error
+}
+
+// New creates a Watcher for directory and all of its sub directories.
+func New(directory string) (Watcher, error) {
+	abs, err := filepath.Abs(directory)
+	if err != nil {
+		return nil, err
+	}
+	return newWatcher(abs)
+}
+
+// skipDir reports whether a directory should not be watched: hidden directories and the built-in
+// exclusions like node_modules. Files of other ignored directories are filtered by the consumer.
+func skipDir(path string) bool {
+	name := filepath.Base(path)
+	return (strings.HasPrefix(name, ".") && name != ".") || fileutil.IsDefaultExcludedDir(name)
+}
+
+// walkDirs calls fn for every directory below root that is not skipped
+func walkDirs(root string, fn func(path string) error) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			// the directory may have been removed in the meantime
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+		if path != root && skipDir(path) {
+			return filepath.SkipDir
+		}
+		return fn(path)
+	})
+}
@@ -0,0 +1,86 @@
+//go:build !linux
+
+package watcher
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const pollInterval = 2 * time.Second
+
+// pollWatcher compares modification times periodically where inotify is not available
+type pollWatcher struct {
+	root   string
+	events chan string
+	errors chan error
+	done   chan struct{}
+	once   sync.Once
+}
+
+func newWatcher(root string) (Watcher, error) {
+	w := &pollWatcher{root: root, events: make(chan string, 256), errors: make(chan error, 16), done: make(chan struct{})}
+	state := w.snapshot()
+	go w.poll(state)
+	return w, nil
+}
+
+func (w *pollWatcher) snapshot() map[string]time.Time {
+	state := map[string]time.Time{}
+	walkDirs(w.root, func(dir string) error {
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			if info, err := e.Info(); err == nil {
+				state[filepath.Join(dir, e.Name())] = info.ModTime()
+			}
+		}
+		return nil
+	})
+	return state
+}
+
+func (w *pollWatcher) poll(state map[string]time.Time) {
+	defer close(w.events)
+	defer close(w.errors)
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.done:
+			return
+		case <-ticker.C:
+		}
+
+		current := w.snapshot()
+		for path, modTime := range current {
+			if previous, ok := state[path]; !ok || !previous.Equal(modTime) {
+				w.events <- path
+			}
+		}
+		for path := range state {
+			if _, ok := current[path]; !ok {
+				w.events <- path
+			}
+		}
+		state = current
+	}
+}
+
+func (w *pollWatcher) Events() <-chan string {
+	return w.events
+}
+
+func (w *pollWatcher) Errors() <-chan error {
+	return w.errors
+}
+
+func (w *pollWatcher) Close() error {
+	w.once.Do(func() { close(w.done) })
+	return nil
+}